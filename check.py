@@ -203,7 +203,8 @@ def main(prefix):
             numsamplesC = chromosome["numsamples"]
 
             blockC = chromosome["block"]
-            blocksC = chromosome["blocks"]
+            blocksAll = chromosome["blocks"]
+            blocksC = [b for b in blocksAll if not b.get("masked", False)] # masked blocks are not part of the summary
             blocks.append(blockC)
             
             blocknames = chromosome["blocknames"]
@@ -214,7 +215,7 @@ def main(prefix):
             # blockMatrix[dataKey] = [blockMatrix[dataKey][p] - blockMatrixC[dataKey][p] for p in range(len(blockMatrix[dataKey]))]
             
             numsnps -= numsnpsC
-            numblocks -= len(blocknames)
+            numblocks -= len(blocksAll)
 
             minpositionCCalc = min([c["minposition"] for c in blocksC])
             assert minpositionC == minpositionCCalc, " minposition mismatch: {} != {}".format(minpositionC, minpositionCCalc)
@@ -224,7 +225,7 @@ def main(prefix):
             assert maxpositionC == maxpositionCCalc, " maxposition mismatch: {} != {}".format(maxpositionC, maxpositionCCalc)
             print(" max position OK", maxpositionC)
 
-            numblocksCCalc = len(blocknames)
+            numblocksCCalc = len(blocksAll) # merged blocks share the same entry in blocknames
             assert numblocksC == numblocksCCalc, " numblocks mismatch: {} != {}".format(numblocksC, numblocksCCalc)
             print(" num blocks OK", numblocksC)
 
@@ -0,0 +1,226 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+//
+//
+// Available formats
+//
+//
+
+var Formats = map[string]ExportFormat{
+	"parquet": ExportFormat{
+		Extension: "parquet",
+		Exporter:  parquetExporter,
+	},
+	"arrow": ExportFormat{
+		Extension: "arrow",
+		Exporter:  arrowExporter,
+	},
+	"npz": ExportFormat{
+		Extension: "npz",
+		Exporter:  npzExporter,
+	},
+}
+
+var FormatNames = []string{"parquet", "arrow", "npz"}
+var DefaultFormat = "parquet"
+
+//
+//
+// Format Types
+//
+//
+
+type Exporter func(ib *IBrowser, opts ExportOptions) error
+
+type ExportFormat struct {
+	Extension string
+	Exporter  Exporter
+}
+
+type ExportOptions struct {
+	DatabaseName string
+	OutFile      string
+	Level        string // level name or window size. empty for the base blocks
+}
+
+// MatrixRow is a pair of samples of a block in long format
+type MatrixRow struct {
+	Database    string
+	Chromosome  string
+	Block       uint64
+	MinPosition uint64
+	MaxPosition uint64
+	SampleA     string
+	SampleB     string
+	Counter     uint64
+}
+
+//
+//
+// Export
+//
+//
+
+func Export(ib *IBrowser, format string, opts ExportOptions) error {
+	exportFormat, ok := Formats[format]
+
+	if !ok {
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+
+	return exportFormat.Exporter(ib, opts)
+}
+
+func GenFilename(dbPrefix string, format string) string {
+	return dbPrefix + "." + Formats[format].Extension
+}
+
+func GenDatabaseName(dbPrefix string) string {
+	return filepath.Base(dbPrefix)
+}
+
+// GetMetadata returns the database description stored along the exported tables
+func GetMetadata(ib *IBrowser, opts ExportOptions) (metadata map[string]string, err error) {
+	samples, err := json.Marshal(ib.Samples)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parameters, err := json.Marshal(ib.Parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	level := opts.Level
+
+	if level == "" {
+		level = "base"
+	}
+
+	metadata = map[string]string{
+		"ibrowser.database":    opts.DatabaseName,
+		"ibrowser.level":       level,
+		"ibrowser.blockMode":   ib.BlockMode,
+		"ibrowser.blockSize":   fmt.Sprint(ib.BlockSize),
+		"ibrowser.numSamples":  fmt.Sprint(ib.NumSamples),
+		"ibrowser.numSNPS":     fmt.Sprint(ib.NumSNPS),
+		"ibrowser.counterBits": fmt.Sprint(ib.CounterBits),
+		"ibrowser.samples":     string(samples),
+		"ibrowser.parameters":  string(parameters),
+	}
+
+	return metadata, nil
+}
+
+func SortedKeys(metadata map[string]string) (keys []string) {
+	keys = make([]string, 0, len(metadata))
+
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// GetLevelBlocks returns the blocks of the requested level of a chromosome
+func GetLevelBlocks(chromosome *IBChromosome, levelName string) ([]*IBBlock, error) {
+	level, ok := chromosome.GetResolution(levelName)
+
+	if !ok {
+		return nil, fmt.Errorf("chromosome %s has no level %s", chromosome.ChromosomeName, levelName)
+	}
+
+	return level.Blocks, nil
+}
+
+// EachChromosome calls callback with the blocks of each chromosome, in order
+func EachChromosome(ib *IBrowser, levelName string, callback func(chromosome *IBChromosome, blocks []*IBBlock) error) error {
+	for _, chromosome := range ib.GetChromosomes() {
+		blocks, err := GetLevelBlocks(chromosome, levelName)
+
+		if err != nil {
+			return err
+		}
+
+		if err := callback(chromosome, blocks); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EachRow calls callback for every pair of samples of every non empty block.
+// The row is reused between calls.
+func EachRow(ib *IBrowser, databaseName string, chromosome *IBChromosome, blocks []*IBBlock, callback func(row *MatrixRow) error) error {
+	row := MatrixRow{
+		Database:   databaseName,
+		Chromosome: chromosome.ChromosomeName,
+	}
+
+	for _, block := range blocks {
+		if block.NumSNPS == 0 {
+			continue
+		}
+
+		if err := EachBlockRow(ib, &row, block, block.Matrix, callback); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EachBlockRow calls callback for every pair of samples of matrix, which
+// does not need to be loaded in the block. Database and Chromosome are
+// taken from row.
+func EachBlockRow(ib *IBrowser, row *MatrixRow, block *IBBlock, matrix *IBMatrix, callback func(row *MatrixRow) error) error {
+	if matrix == nil {
+		return fmt.Errorf("chromosome %s block %d has no matrix", row.Chromosome, block.BlockNumber)
+	}
+
+	table, ok := matrix.GetTable()
+
+	if !ok {
+		return fmt.Errorf("chromosome %s block %d has no matrix", row.Chromosome, block.BlockNumber)
+	}
+
+	dimension := matrix.Dimension
+
+	if uint64(len(*table)) != dimension*(dimension-1)/2 || dimension > uint64(len(ib.Samples)) {
+		return fmt.Errorf("chromosome %s block %d has a matrix of wrong size", row.Chromosome, block.BlockNumber)
+	}
+
+	row.Block = block.BlockNumber
+	row.MinPosition = block.MinPosition
+	row.MaxPosition = block.MaxPosition
+
+	k := 0
+
+	for i := uint64(0); i < dimension; i++ {
+		row.SampleA = ib.Samples[i]
+
+		for j := i + 1; j < dimension; j++ {
+			row.SampleB = ib.Samples[j]
+			row.Counter = (*table)[k]
+			k++
+
+			if err := callback(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+import (
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+)
+
+//
+// Arrow
+//
+
+const ARROW_BATCH_ROWS = 1 << 20
+
+var arrowMatrixFields = []arrow.Field{
+	{Name: "database", Type: arrow.BinaryTypes.String},
+	{Name: "chromosome", Type: arrow.BinaryTypes.String},
+	{Name: "block", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "min_position", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "max_position", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "sample_a", Type: arrow.BinaryTypes.String},
+	{Name: "sample_b", Type: arrow.BinaryTypes.String},
+	{Name: "counter", Type: arrow.PrimitiveTypes.Uint64},
+}
+
+var arrowBlockFields = []arrow.Field{
+	{Name: "database", Type: arrow.BinaryTypes.String},
+	{Name: "chromosome", Type: arrow.BinaryTypes.String},
+	{Name: "block", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "min_position", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "max_position", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "num_snps", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "dimension", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "masked", Type: arrow.FixedWidthTypes.Boolean},
+}
+
+type arrowRecordWriter interface {
+	Write(rec array.Record) error
+}
+
+func newArrowSchema(fields []arrow.Field, metadata map[string]string) *arrow.Schema {
+	keys := SortedKeys(metadata)
+	values := make([]string, len(keys))
+
+	for i, key := range keys {
+		values[i] = metadata[key]
+	}
+
+	md := arrow.NewMetadata(keys, values)
+
+	return arrow.NewSchema(fields, &md)
+}
+
+//
+// Matrix builder
+//
+
+type arrowMatrixBuilder struct {
+	builder *array.RecordBuilder
+	numRows int
+}
+
+func newArrowMatrixBuilder(mem memory.Allocator, schema *arrow.Schema) *arrowMatrixBuilder {
+	return &arrowMatrixBuilder{
+		builder: array.NewRecordBuilder(mem, schema),
+	}
+}
+
+func (b *arrowMatrixBuilder) Append(row *MatrixRow) {
+	b.builder.Field(0).(*array.StringBuilder).Append(row.Database)
+	b.builder.Field(1).(*array.StringBuilder).Append(row.Chromosome)
+	b.builder.Field(2).(*array.Uint64Builder).Append(row.Block)
+	b.builder.Field(3).(*array.Uint64Builder).Append(row.MinPosition)
+	b.builder.Field(4).(*array.Uint64Builder).Append(row.MaxPosition)
+	b.builder.Field(5).(*array.StringBuilder).Append(row.SampleA)
+	b.builder.Field(6).(*array.StringBuilder).Append(row.SampleB)
+	b.builder.Field(7).(*array.Uint64Builder).Append(row.Counter)
+	b.numRows++
+}
+
+// Write writes the appended rows as a record batch
+func (b *arrowMatrixBuilder) Write(w arrowRecordWriter) error {
+	rec := b.builder.NewRecord()
+	defer rec.Release()
+
+	b.numRows = 0
+
+	return w.Write(rec)
+}
+
+// AppendAndWrite appends a row and writes a record batch every ARROW_BATCH_ROWS rows
+func (b *arrowMatrixBuilder) AppendAndWrite(w arrowRecordWriter, row *MatrixRow) error {
+	b.Append(row)
+
+	if b.numRows >= ARROW_BATCH_ROWS {
+		return b.Write(w)
+	}
+
+	return nil
+}
+
+func (b *arrowMatrixBuilder) Release() {
+	b.builder.Release()
+}
+
+func appendArrowBlock(builder *array.RecordBuilder, databaseName string, block *IBBlock) {
+	dimension := uint64(0)
+
+	if block.Matrix != nil {
+		dimension = block.Matrix.Dimension
+	}
+
+	builder.Field(0).(*array.StringBuilder).Append(databaseName)
+	builder.Field(1).(*array.StringBuilder).Append(block.ChromosomeName)
+	builder.Field(2).(*array.Uint64Builder).Append(block.BlockNumber)
+	builder.Field(3).(*array.Uint64Builder).Append(block.MinPosition)
+	builder.Field(4).(*array.Uint64Builder).Append(block.MaxPosition)
+	builder.Field(5).(*array.Uint64Builder).Append(block.NumSNPS)
+	builder.Field(6).(*array.Uint64Builder).Append(dimension)
+	builder.Field(7).(*array.BooleanBuilder).Append(block.Masked)
+}
+
+//
+// File
+//
+
+func GenBlocksFilename(outFile string) string {
+	ext := filepath.Ext(outFile)
+	return strings.TrimSuffix(outFile, ext) + "_blocks" + ext
+}
+
+// arrowExporter writes the matrices in long format to OutFile and the block
+// metadata to its _blocks file, both as Arrow IPC (Feather v2) files with a
+// record batch per chromosome
+func arrowExporter(ib *IBrowser, opts ExportOptions) (err error) {
+	metadata, err := GetMetadata(ib, opts)
+
+	if err != nil {
+		return err
+	}
+
+	mem := memory.NewGoAllocator()
+
+	matrixSchema := newArrowSchema(arrowMatrixFields, metadata)
+	blockSchema := newArrowSchema(arrowBlockFields, metadata)
+
+	matrixFile, err := os.Create(opts.OutFile)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := matrixFile.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	blockFile, err := os.Create(GenBlocksFilename(opts.OutFile))
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := blockFile.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	matrixWriter, err := ipc.NewFileWriter(matrixFile, ipc.WithSchema(matrixSchema), ipc.WithAllocator(mem))
+
+	if err != nil {
+		return err
+	}
+
+	blockWriter, err := ipc.NewFileWriter(blockFile, ipc.WithSchema(blockSchema), ipc.WithAllocator(mem))
+
+	if err != nil {
+		return err
+	}
+
+	matrixBuilder := newArrowMatrixBuilder(mem, matrixSchema)
+	defer matrixBuilder.Release()
+
+	blockBuilder := array.NewRecordBuilder(mem, blockSchema)
+	defer blockBuilder.Release()
+
+	err = EachChromosome(ib, opts.Level, func(chromosome *IBChromosome, blocks []*IBBlock) error {
+		for _, block := range blocks {
+			appendArrowBlock(blockBuilder, opts.DatabaseName, block)
+		}
+
+		rec := blockBuilder.NewRecord()
+		err := blockWriter.Write(rec)
+		rec.Release()
+
+		if err != nil {
+			return err
+		}
+
+		err = EachRow(ib, opts.DatabaseName, chromosome, blocks, func(row *MatrixRow) error {
+			return matrixBuilder.AppendAndWrite(matrixWriter, row)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if matrixBuilder.numRows > 0 {
+			return matrixBuilder.Write(matrixWriter)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err = matrixWriter.Close(); err != nil {
+		return err
+	}
+
+	return blockWriter.Close()
+}
+
+//
+// Stream
+//
+
+// WriteArrowStream writes the matrix of a single block in long format as an Arrow IPC stream
+func WriteArrowStream(w io.Writer, ib *IBrowser, databaseName string, chromosomeName string, block *IBBlock, matrix *IBMatrix) error {
+	metadata := map[string]string{
+		"ibrowser.database":    databaseName,
+		"ibrowser.chromosome":  chromosomeName,
+		"ibrowser.block":       fmt.Sprint(block.BlockNumber),
+		"ibrowser.minPosition": fmt.Sprint(block.MinPosition),
+		"ibrowser.maxPosition": fmt.Sprint(block.MaxPosition),
+		"ibrowser.numSNPS":     fmt.Sprint(block.NumSNPS),
+	}
+
+	mem := memory.NewGoAllocator()
+	schema := newArrowSchema(arrowMatrixFields, metadata)
+
+	writer := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+
+	builder := newArrowMatrixBuilder(mem, schema)
+	defer builder.Release()
+
+	row := MatrixRow{
+		Database:   databaseName,
+		Chromosome: chromosomeName,
+	}
+
+	err := EachBlockRow(ib, &row, block, matrix, func(row *MatrixRow) error {
+		return builder.AppendAndWrite(writer, row)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if builder.numRows > 0 {
+		if err := builder.Write(writer); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
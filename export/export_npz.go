@@ -0,0 +1,498 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//
+// NumPy npz
+//
+
+// Layout of the legacy python reader (opt/reader.py):
+//   {prefix}_ib/{binWidth}/{basename}.npz                 genome
+//   {prefix}_ib/{binWidth}/RAW/ib_{order:06d}.{name}.npz  chromosomes
+
+const NPZ_METRIC = "RAW"
+const NPZ_DISTANCE_TYPE = "float32"
+const NPZ_DISTANCE_MAX_EXP = 128 // numpy.finfo(numpy.float32).maxexp
+const NPZ_POSITIONS_TYPE = "uint32"
+const NPZ_PAIRWISE_TYPE = "uint32"
+
+var npzCounterTypes = []string{"uint16", "uint32"}
+
+var npzChromosomeInfo = []string{
+	"bin_count", "bin_min", "bin_max", "bin_width",
+	"bin_snps_min", "bin_snps_max",
+	"chromosome_snps", "chromosome_order", "chromosome_first_position", "chromosome_last_position",
+	"matrix_size", "sample_count",
+	"type_matrix_counter_max_val", "type_matrix_distance_max_val", "type_pairwise_counter_max_val", "type_positions_max_val",
+}
+
+var npzGenomeInfo = []string{
+	"bin_width", "chromosome_count", "sample_count", "genome_bins", "genome_snps",
+}
+
+// npzChromosome holds the non empty bins of a chromosome. Bins are numbered
+// as the reader does, position / binWidth, which is the block number.
+type npzChromosome struct {
+	name          string
+	order         int
+	bins          map[uint64]*IBBlock
+	binMin        uint64
+	binMax        uint64 // number of rows, one past the last bin
+	binSnpsMin    uint64
+	binSnpsMax    uint64
+	snps          uint64
+	firstPosition uint64
+}
+
+type npzTypes struct {
+	counter    string
+	pairwise   string
+	counterMax uint64
+}
+
+func GenNpzPrefix(outFile string) string {
+	return strings.TrimSuffix(outFile, ".npz")
+}
+
+func GenNpzDirname(prefix string, binWidth uint64) string {
+	return filepath.Join(prefix+"_ib", fmt.Sprint(binWidth))
+}
+
+func GenNpzGenomeFilename(prefix string, binWidth uint64) string {
+	return filepath.Join(GenNpzDirname(prefix, binWidth), filepath.Base(prefix)+".npz")
+}
+
+func GenNpzChromosomeFilename(prefix string, binWidth uint64, order int, chromosomeName string) string {
+	return filepath.Join(GenNpzDirname(prefix, binWidth), NPZ_METRIC, fmt.Sprintf("ib_%06d.%s.npz", order, chromosomeName))
+}
+
+func uintMax(typeName string) uint64 {
+	switch typeName {
+	case "uint8":
+		return math.MaxUint8
+	case "uint16":
+		return math.MaxUint16
+	case "uint32":
+		return math.MaxUint32
+	}
+	return math.MaxUint64
+}
+
+// npzMatrixRow spreads a block matrix over a row of matrixSize counters.
+// Blocks may have less samples than the database.
+func npzMatrixRow(row []uint64, block *IBBlock, numSamples uint64) error {
+	for k := range row {
+		row[k] = 0
+	}
+
+	if block == nil {
+		return nil
+	}
+
+	if block.Matrix == nil {
+		return fmt.Errorf("chromosome %s block %d has no matrix", block.ChromosomeName, block.BlockNumber)
+	}
+
+	table, ok := block.Matrix.GetTable()
+
+	if !ok {
+		return fmt.Errorf("chromosome %s block %d has no matrix", block.ChromosomeName, block.BlockNumber)
+	}
+
+	dimension := block.Matrix.Dimension
+
+	if uint64(len(*table)) != dimension*(dimension-1)/2 || dimension > numSamples {
+		return fmt.Errorf("chromosome %s block %d has a matrix of wrong size", block.ChromosomeName, block.BlockNumber)
+	}
+
+	k := 0
+
+	for i := uint64(0); i < dimension; i++ {
+		rowStart := i*numSamples - i*(i+1)/2
+
+		for j := i + 1; j < dimension; j++ {
+			row[rowStart+j-i-1] = (*table)[k]
+			k++
+		}
+	}
+
+	return nil
+}
+
+// npzPairwiseRow sums the counters of every sample against all others
+func npzPairwiseRow(pairwise []uint64, row []uint64, numSamples uint64) {
+	for i := range pairwise {
+		pairwise[i] = 0
+	}
+
+	k := 0
+
+	for i := uint64(0); i < numSamples; i++ {
+		for j := i + 1; j < numSamples; j++ {
+			pairwise[i] += row[k]
+			pairwise[j] += row[k]
+			k++
+		}
+	}
+}
+
+func newNpzChromosome(chromosome *IBChromosome, order int, blocks []*IBBlock) *npzChromosome {
+	c := &npzChromosome{
+		name:  chromosome.ChromosomeName,
+		order: order,
+		bins:  make(map[uint64]*IBBlock, len(blocks)),
+	}
+
+	for _, block := range blocks {
+		if block.NumSNPS == 0 {
+			continue
+		}
+
+		if len(c.bins) == 0 || block.BlockNumber < c.binMin {
+			c.binMin = block.BlockNumber
+			c.firstPosition = block.MinPosition
+		}
+
+		if len(c.bins) == 0 || block.NumSNPS < c.binSnpsMin {
+			c.binSnpsMin = block.NumSNPS
+		}
+
+		if block.BlockNumber+1 > c.binMax {
+			c.binMax = block.BlockNumber + 1
+		}
+
+		if block.NumSNPS > c.binSnpsMax {
+			c.binSnpsMax = block.NumSNPS
+		}
+
+		c.bins[block.BlockNumber] = block
+		c.snps += block.NumSNPS
+	}
+
+	return c
+}
+
+// npzChooseTypes picks the smallest counter types holding all values. The
+// reader stores the type maxima as int64, which excludes uint64.
+func npzChooseTypes(chromosomes []*npzChromosome, numSamples uint64) (types npzTypes, err error) {
+	matrixSize := numSamples * (numSamples - 1) / 2
+	row := make([]uint64, matrixSize)
+	pairwise := make([]uint64, numSamples)
+
+	maxCounter := uint64(0)
+	maxPairwise := uint64(0)
+
+	for _, c := range chromosomes {
+		for _, block := range c.bins {
+			if err := npzMatrixRow(row, block, numSamples); err != nil {
+				return types, err
+			}
+
+			npzPairwiseRow(pairwise, row, numSamples)
+
+			for _, v := range row {
+				if v > maxCounter {
+					maxCounter = v
+				}
+			}
+
+			for _, v := range pairwise {
+				if v > maxPairwise {
+					maxPairwise = v
+				}
+			}
+
+			if block.NumSNPS > maxPairwise {
+				maxPairwise = block.NumSNPS
+			}
+		}
+	}
+
+	for _, counter := range npzCounterTypes {
+		if maxCounter <= uintMax(counter) {
+			types.counter = counter
+			break
+		}
+	}
+
+	if types.counter == "" {
+		return types, fmt.Errorf("counter %d does not fit in %s", maxCounter, npzCounterTypes[len(npzCounterTypes)-1])
+	}
+
+	if maxPairwise > uintMax(NPZ_PAIRWISE_TYPE) {
+		return types, fmt.Errorf("pairwise counter %d does not fit in %s", maxPairwise, NPZ_PAIRWISE_TYPE)
+	}
+
+	types.pairwise = NPZ_PAIRWISE_TYPE
+	types.counterMax = uintMax(types.counter)
+
+	return types, nil
+}
+
+func npzMeta(typ npzTypes) (names []string, values []string) {
+	names = []string{"type_matrix_counter_name", "type_matrix_distance_name", "type_pairwise_counter_name", "type_positions_name"}
+	values = []string{typ.counter, NPZ_DISTANCE_TYPE, typ.pairwise, NPZ_POSITIONS_TYPE}
+	return
+}
+
+// npzWriteRecords writes the info and meta name and value arrays
+func npzWriteRecords(npz *NpzWriter, infoNames []string, infoValues []uint64, metaNames []string, metaValues []string) error {
+	if err := npzWriteStrings(npz, "info_names", infoNames); err != nil {
+		return err
+	}
+
+	if err := npzWriteInts(npz, "info_values", "int64", infoValues); err != nil {
+		return err
+	}
+
+	if err := npzWriteStrings(npz, "meta_names", metaNames); err != nil {
+		return err
+	}
+
+	return npzWriteStrings(npz, "meta_values", metaValues)
+}
+
+func npzWriteStrings(npz *NpzWriter, name string, values []string) error {
+	w, err := npz.Create(name)
+
+	if err != nil {
+		return err
+	}
+
+	return WriteNpyStrings(w, values)
+}
+
+func npzWriteInts(npz *NpzWriter, name string, typeName string, values []uint64) error {
+	w, err := npz.Create(name)
+
+	if err != nil {
+		return err
+	}
+
+	return WriteNpyInts(w, typeName, values)
+}
+
+// npzWriteChromosome writes countMatrix (bins x pairs), countTotals (snps per
+// bin) and countPairw (bins x samples) with empty alignments and positions
+func npzWriteChromosome(fileName string, ib *IBrowser, vcfName string, binWidth uint64, c *npzChromosome, typ npzTypes) (err error) {
+	numSamples := uint64(len(ib.Samples))
+	matrixSize := numSamples * (numSamples - 1) / 2
+
+	npz, err := NewNpzWriter(fileName)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := npz.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	counterDescr, _ := NpyDescr(typ.counter)
+	pairwiseDescr, _ := NpyDescr(typ.pairwise)
+
+	row := make([]uint64, matrixSize)
+	pairwise := make([]uint64, numSamples)
+	totals := make([]uint64, c.binMax)
+
+	w, err := npz.Create("countMatrix")
+
+	if err != nil {
+		return err
+	}
+
+	matrixWriter, err := NewNpyRowWriter(w, counterDescr, []uint64{c.binMax, matrixSize})
+
+	if err != nil {
+		return err
+	}
+
+	for binNum := uint64(0); binNum < c.binMax; binNum++ {
+		if err := npzMatrixRow(row, c.bins[binNum], numSamples); err != nil {
+			return err
+		}
+
+		if err := matrixWriter.Write(row); err != nil {
+			return err
+		}
+
+		if block, ok := c.bins[binNum]; ok {
+			totals[binNum] = block.NumSNPS
+		}
+	}
+
+	if err := npzWriteInts(npz, "countTotals", typ.pairwise, totals); err != nil {
+		return err
+	}
+
+	w, err = npz.Create("countPairw")
+
+	if err != nil {
+		return err
+	}
+
+	pairwiseWriter, err := NewNpyRowWriter(w, pairwiseDescr, []uint64{c.binMax, numSamples})
+
+	if err != nil {
+		return err
+	}
+
+	for binNum := uint64(0); binNum < c.binMax; binNum++ {
+		if err := npzMatrixRow(row, c.bins[binNum], numSamples); err != nil {
+			return err
+		}
+
+		npzPairwiseRow(pairwise, row, numSamples)
+
+		if err := pairwiseWriter.Write(pairwise); err != nil {
+			return err
+		}
+	}
+
+	if err := npzWriteStrings(npz, "alignments", []string{}); err != nil {
+		return err
+	}
+
+	if err := npzWriteInts(npz, "positions", NPZ_POSITIONS_TYPE, []uint64{}); err != nil {
+		return err
+	}
+
+	if err := npzWriteStrings(npz, "sample_names", ib.Samples); err != nil {
+		return err
+	}
+
+	// the reader keeps bin_max rows and requires last_position / bin_width == bin_max,
+	// so the last position is reported as the end of the last bin
+	infoValues := []uint64{
+		uint64(len(c.bins)), c.binMin, c.binMax, binWidth,
+		c.binSnpsMin, c.binSnpsMax,
+		c.snps, uint64(c.order), c.firstPosition, c.binMax * binWidth,
+		matrixSize, numSamples,
+		typ.counterMax, NPZ_DISTANCE_MAX_EXP, uintMax(typ.pairwise), uintMax(NPZ_POSITIONS_TYPE),
+	}
+
+	typeNames, typeValues := npzMeta(typ)
+	metaNames := append([]string{"vcf_name", "metric", "chromosome_name"}, typeNames...)
+	metaValues := append([]string{vcfName, NPZ_METRIC, c.name}, typeValues...)
+
+	return npzWriteRecords(npz, npzChromosomeInfo, infoValues, metaNames, metaValues)
+}
+
+func npzWriteGenome(fileName string, ib *IBrowser, vcfName string, binWidth uint64, chromosomes []*npzChromosome, typ npzTypes) (err error) {
+	npz, err := NewNpzWriter(fileName)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := npz.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	chromosomeNames := make([]string, len(chromosomes))
+	genomeBins := uint64(0)
+	genomeSnps := uint64(0)
+
+	for i, c := range chromosomes {
+		chromosomeNames[i] = c.name
+		genomeBins += uint64(len(c.bins))
+		genomeSnps += c.snps
+	}
+
+	if err := npzWriteStrings(npz, "sample_names", ib.Samples); err != nil {
+		return err
+	}
+
+	if err := npzWriteStrings(npz, "chromosome_names", chromosomeNames); err != nil {
+		return err
+	}
+
+	infoValues := []uint64{binWidth, uint64(len(chromosomes)), uint64(len(ib.Samples)), genomeBins, genomeSnps}
+
+	typeNames, typeValues := npzMeta(typ)
+	metaNames := append([]string{"vcf_name", "metric"}, typeNames...)
+	metaValues := append([]string{vcfName, NPZ_METRIC}, typeValues...)
+
+	return npzWriteRecords(npz, npzGenomeInfo, infoValues, metaNames, metaValues)
+}
+
+// npzExporter writes the database in the npz layout of the legacy python
+// reader, with the raw counters of every bin. OutFile, without the .npz
+// extension, takes the place of the vcf name.
+func npzExporter(ib *IBrowser, opts ExportOptions) error {
+	if ib.BlockMode != BLOCK_MODE_BP {
+		return fmt.Errorf("npz export needs blocks in %s, not %s", BLOCK_MODE_BP, ib.BlockMode)
+	}
+
+	prefix := GenNpzPrefix(opts.OutFile)
+	vcfName := filepath.Base(prefix)
+	numSamples := uint64(len(ib.Samples))
+	binWidth := uint64(0)
+
+	chromosomes := make([]*npzChromosome, 0, len(ib.Chromosomes))
+
+	err := EachChromosome(ib, opts.Level, func(chromosome *IBChromosome, blocks []*IBBlock) error {
+		level, _ := chromosome.GetResolution(opts.Level)
+
+		if level.IsSliding() {
+			return fmt.Errorf("npz export needs non overlapping blocks. level %s is sliding", level.Name)
+		}
+
+		if binWidth != 0 && binWidth != level.WindowSize {
+			return fmt.Errorf("chromosome %s has bins of %d, not %d", chromosome.ChromosomeName, level.WindowSize, binWidth)
+		}
+
+		binWidth = level.WindowSize
+
+		c := newNpzChromosome(chromosome, len(chromosomes), blocks)
+
+		if len(c.bins) == 0 {
+			fmt.Println("skipping empty chromosome", chromosome.ChromosomeName)
+			return nil
+		}
+
+		chromosomes = append(chromosomes, c)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	types, err := npzChooseTypes(chromosomes, numSamples)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(GenNpzDirname(prefix, binWidth), NPZ_METRIC), 0755); err != nil {
+		return err
+	}
+
+	for _, c := range chromosomes {
+		fileName := GenNpzChromosomeFilename(prefix, binWidth, c.order, c.name)
+
+		fmt.Println("saving", fileName)
+
+		if err := npzWriteChromosome(fileName, ib, vcfName, binWidth, c, types); err != nil {
+			return err
+		}
+	}
+
+	fileName := GenNpzGenomeFilename(prefix, binWidth)
+
+	fmt.Println("saving", fileName)
+
+	return npzWriteGenome(fileName, ib, vcfName, binWidth, chromosomes, types)
+}
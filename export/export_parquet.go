@@ -0,0 +1,94 @@
+package export
+
+import (
+	"bufio"
+	"os"
+)
+
+import (
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+//
+// Parquet
+//
+
+type parquetRow struct {
+	Database    string `parquet:"name=database, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Chromosome  string `parquet:"name=chromosome, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Block       int64  `parquet:"name=block, type=INT64, convertedtype=UINT_64"`
+	MinPosition int64  `parquet:"name=min_position, type=INT64, convertedtype=UINT_64"`
+	MaxPosition int64  `parquet:"name=max_position, type=INT64, convertedtype=UINT_64"`
+	SampleA     string `parquet:"name=sample_a, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	SampleB     string `parquet:"name=sample_b, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Counter     int64  `parquet:"name=counter, type=INT64, convertedtype=UINT_64"`
+}
+
+// parquetExporter writes one row group per chromosome
+func parquetExporter(ib *IBrowser, opts ExportOptions) (err error) {
+	metadata, err := GetMetadata(ib, opts)
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(opts.OutFile)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	buf := bufio.NewWriter(file)
+
+	pw, err := writer.NewParquetWriterFromWriter(buf, new(parquetRow), 1)
+
+	if err != nil {
+		return err
+	}
+
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	pw.RowGroupSize = 1 << 40 // flushed by chromosome
+
+	for _, key := range SortedKeys(metadata) {
+		value := metadata[key]
+		pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata, &parquet.KeyValue{Key: key, Value: &value})
+	}
+
+	err = EachChromosome(ib, opts.Level, func(chromosome *IBChromosome, blocks []*IBBlock) error {
+		err := EachRow(ib, opts.DatabaseName, chromosome, blocks, func(row *MatrixRow) error {
+			return pw.Write(parquetRow{
+				Database:    row.Database,
+				Chromosome:  row.Chromosome,
+				Block:       int64(row.Block),
+				MinPosition: int64(row.MinPosition),
+				MaxPosition: int64(row.MaxPosition),
+				SampleA:     row.SampleA,
+				SampleB:     row.SampleB,
+				Counter:     int64(row.Counter),
+			})
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return pw.Flush(true)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err = pw.WriteStop(); err != nil {
+		return err
+	}
+
+	return buf.Flush()
+}
@@ -0,0 +1,14 @@
+package export
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/ibrowser"
+)
+
+// ibrowser
+type IBrowser = ibrowser.IBrowser
+type IBChromosome = ibrowser.IBChromosome
+type IBLevel = ibrowser.IBLevel
+type IBBlock = ibrowser.IBBlock
+type IBMatrix = ibrowser.IBDistanceMatrix
+
+const BLOCK_MODE_BP = ibrowser.BLOCK_MODE_BP
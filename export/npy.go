@@ -0,0 +1,225 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+//
+// NumPy npy
+//
+
+// https://numpy.org/doc/stable/reference/generated/numpy.lib.format.html
+
+const NPY_MAGIC = "\x93NUMPY"
+const NPY_ALIGNMENT = 64
+
+// NpyDescr returns the little endian numpy type descriptor of an unsigned type name
+func NpyDescr(typeName string) (string, bool) {
+	switch typeName {
+	case "uint8":
+		return "|u1", true
+	case "uint16":
+		return "<u2", true
+	case "uint32":
+		return "<u4", true
+	case "uint64":
+		return "<u8", true
+	case "int64":
+		return "<i8", true
+	}
+	return "", false
+}
+
+func npyItemSize(descr string) int {
+	switch descr[len(descr)-1] {
+	case '1':
+		return 1
+	case '2':
+		return 2
+	case '4':
+		return 4
+	}
+	return 8
+}
+
+func npyShape(shape []uint64) string {
+	dims := make([]string, len(shape))
+
+	for i, dim := range shape {
+		dims[i] = fmt.Sprint(dim)
+	}
+
+	if len(dims) == 1 {
+		return "(" + dims[0] + ",)"
+	}
+
+	return "(" + strings.Join(dims, ", ") + ")"
+}
+
+// WriteNpyHeader writes a version 1.0 header of a C ordered array
+func WriteNpyHeader(w io.Writer, descr string, shape []uint64) error {
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': %s, }", descr, npyShape(shape))
+
+	prefixLen := len(NPY_MAGIC) + 2 + 2
+	padding := NPY_ALIGNMENT - (prefixLen+len(header)+1)%NPY_ALIGNMENT
+
+	if padding == NPY_ALIGNMENT {
+		padding = 0
+	}
+
+	header += strings.Repeat(" ", padding) + "\n"
+
+	if len(header) > 65535 {
+		return fmt.Errorf("npy header too long: %d", len(header))
+	}
+
+	buf := bytes.NewBufferString(NPY_MAGIC)
+	buf.Write([]byte{1, 0})
+	binary.Write(buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// NpyRowWriter encodes rows of unsigned integers as the items of an array
+type NpyRowWriter struct {
+	w        io.Writer
+	itemSize int
+	buf      []byte
+}
+
+func NewNpyRowWriter(w io.Writer, descr string, shape []uint64) (*NpyRowWriter, error) {
+	if err := WriteNpyHeader(w, descr, shape); err != nil {
+		return nil, err
+	}
+
+	return &NpyRowWriter{w: w, itemSize: npyItemSize(descr)}, nil
+}
+
+func (n *NpyRowWriter) Write(row []uint64) error {
+	size := len(row) * n.itemSize
+
+	if cap(n.buf) < size {
+		n.buf = make([]byte, size)
+	}
+
+	buf := n.buf[:size]
+
+	for i, v := range row {
+		p := buf[i*n.itemSize:]
+		switch n.itemSize {
+		case 1:
+			p[0] = uint8(v)
+		case 2:
+			binary.LittleEndian.PutUint16(p, uint16(v))
+		case 4:
+			binary.LittleEndian.PutUint32(p, uint32(v))
+		default:
+			binary.LittleEndian.PutUint64(p, v)
+		}
+	}
+
+	_, err := n.w.Write(buf)
+
+	return err
+}
+
+// WriteNpyStrings writes a 1D unicode array. numpy stores them as fixed
+// width UTF-32 with the width of the longest string.
+func WriteNpyStrings(w io.Writer, values []string) error {
+	width := 1
+
+	for _, value := range values {
+		if l := utf8.RuneCountInString(value); l > width {
+			width = l
+		}
+	}
+
+	if err := WriteNpyHeader(w, fmt.Sprintf("<U%d", width), []uint64{uint64(len(values))}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, width*4)
+
+	for _, value := range values {
+		for i := range buf {
+			buf[i] = 0
+		}
+
+		p := 0
+		for _, r := range value {
+			binary.LittleEndian.PutUint32(buf[p:], uint32(r))
+			p += 4
+		}
+
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteNpyInts writes a 1D array of integers
+func WriteNpyInts(w io.Writer, typeName string, values []uint64) error {
+	descr, ok := NpyDescr(typeName)
+
+	if !ok {
+		return fmt.Errorf("unknown numpy type: %s", typeName)
+	}
+
+	rw, err := NewNpyRowWriter(w, descr, []uint64{uint64(len(values))})
+
+	if err != nil {
+		return err
+	}
+
+	return rw.Write(values)
+}
+
+//
+// NumPy npz
+//
+
+// NpzWriter writes a compressed npz archive, as numpy.savez_compressed
+type NpzWriter struct {
+	file *os.File
+	zip  *zip.Writer
+}
+
+func NewNpzWriter(fileName string) (*NpzWriter, error) {
+	file, err := os.Create(fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &NpzWriter{file: file, zip: zip.NewWriter(file)}, nil
+}
+
+// Create starts the array name. It must be fully written before the next one is created.
+func (n *NpzWriter) Create(name string) (io.Writer, error) {
+	return n.zip.CreateHeader(&zip.FileHeader{
+		Name:   name + ".npy",
+		Method: zip.Deflate,
+	})
+}
+
+func (n *NpzWriter) Close() error {
+	err := n.zip.Close()
+
+	if cerr := n.file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
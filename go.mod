@@ -3,17 +3,19 @@ module github.com/sauloalgolang/introgressionbrowser
 go 1.15
 
 require (
+	github.com/apache/arrow/go/arrow v0.0.0-20200730104253-651201b0f516
 	github.com/brentp/irelate v0.0.1 // indirect
 	github.com/brentp/vcfgo v0.0.0-20190824021612-654ed2e5945d
-	github.com/golang/snappy v0.0.2
+	github.com/golang/snappy v0.0.3
 	github.com/gorilla/mux v1.8.0
-	github.com/klauspost/compress v1.11.3 // indirect
+	github.com/klauspost/compress v1.13.1
 	github.com/klauspost/pgzip v1.2.5
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/remeh/sizedwaitgroup v1.0.0
 	github.com/sauloalgolang/go-flags v1.4.1
 	github.com/sirupsen/logrus v1.7.0
-	github.com/stretchr/testify v1.6.1 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	github.com/xitongsys/parquet-go v1.6.2
 	golang.org/x/text v0.3.4
 	gopkg.in/check.v1 v1.0.0-20200902074654-038fdea0a05b // indirect
 	gopkg.in/yaml.v2 v2.4.0
@@ -3,7 +3,6 @@ package ibrowser
 import (
 	"fmt"
 	"math"
-	"os"
 )
 
 //
@@ -24,6 +23,14 @@ type IBBlock struct {
 	BlockPosition    uint64
 	BlockNumber      uint64
 	Serial           int64
+	RegisterOffset   uint64
+	RegisterSize     uint64
+	RegisterBits     int64
+	BelowMinSnps     bool
+	AboveMaxSnps     bool
+	Masked           bool
+	NumDroppedSNPS   uint64
+	MergedBlocks     []uint64
 	Matrix           *IBDistanceMatrix
 }
 
@@ -56,6 +63,14 @@ func NewIBBlock(
 		BlockPosition:    blockPosition,
 		BlockNumber:      blockNumber,
 		Serial:           -1,
+		RegisterOffset:   0,
+		RegisterSize:     0,
+		RegisterBits:     0,
+		BelowMinSnps:     false,
+		AboveMaxSnps:     false,
+		Masked:           false,
+		NumDroppedSNPS:   0,
+		MergedBlocks:     make([]uint64, 0, 0),
 		Matrix: NewDistanceMatrix(
 			chromosomeName,
 			blockSize,
@@ -82,6 +97,14 @@ func (ibb *IBBlock) String() string {
 		" BlockPosition:    ", ibb.BlockPosition, "\n",
 		" BlockNumber:      ", ibb.BlockNumber, "\n",
 		" Serial:           ", ibb.Serial, "\n",
+		" RegisterOffset:   ", ibb.RegisterOffset, "\n",
+		" RegisterSize:     ", ibb.RegisterSize, "\n",
+		" RegisterBits:     ", ibb.RegisterBits, "\n",
+		" BelowMinSnps:     ", ibb.BelowMinSnps, "\n",
+		" AboveMaxSnps:     ", ibb.AboveMaxSnps, "\n",
+		" Masked:           ", ibb.Masked, "\n",
+		" NumDroppedSNPS:   ", ibb.NumDroppedSNPS, "\n",
+		" MergedBlocks:     ", ibb.MergedBlocks, "\n",
 	)
 }
 
@@ -91,6 +114,7 @@ func (ibb *IBBlock) AddVcfMatrix(position uint64, distance *VCFDistanceMatrix) {
 	ibb.MinPosition = Min64(ibb.MinPosition, position)
 	ibb.MaxPosition = Max64(ibb.MaxPosition, position)
 	ibb.Matrix.AddVcfMatrix(distance)
+	ibb.SyncCounterBits()
 }
 
 func (ibb *IBBlock) Add(position uint64, distance *IBDistanceMatrix) {
@@ -99,6 +123,7 @@ func (ibb *IBBlock) Add(position uint64, distance *IBDistanceMatrix) {
 	ibb.MinPosition = Min64(ibb.MinPosition, position)
 	ibb.MaxPosition = Max64(ibb.MaxPosition, position)
 	ibb.Matrix.Add(distance)
+	ibb.SyncCounterBits()
 }
 
 func (ibb *IBBlock) GetMatrix() (*IBDistanceMatrix, bool) {
@@ -137,18 +162,68 @@ func (ibb *IBBlock) GetColumn(referenceNumber int) (*IBDistanceTable, bool) {
 	return col, hasCol
 }
 
-func (ibb *IBBlock) Sum(other *IBBlock) {
+// Sum adds other to this block. Blocks whose matrix was released or not
+// loaded return ErrCorrupt.
+func (ibb *IBBlock) Sum(other *IBBlock) error {
+	matrix, hasMatrix := other.GetMatrix()
+
+	if !hasMatrix {
+		return fmt.Errorf("%w: block %s #%d has no matrix", ErrCorrupt, other.ChromosomeName, other.BlockNumber)
+	}
+
 	ibb.NumSNPS += other.NumSNPS
 	ibb.MinPosition = Min64(ibb.MinPosition, other.MinPosition)
 	ibb.MaxPosition = Max64(ibb.MaxPosition, other.MaxPosition)
 
-	matrix, hasMatrix := other.GetMatrix()
+	ibb.Matrix.Add(matrix)
+	ibb.SyncCounterBits()
 
-	if !hasMatrix {
-		panic("no matrix")
+	return nil
+}
+
+// SyncCounterBits updates the block width after the matrix was promoted
+func (ibb *IBBlock) SyncCounterBits() {
+	ibb.CounterBits = ibb.Matrix.CounterBits
+}
+
+// SetCounterBits changes the width of the matrix, keeping it wide enough for its values
+func (ibb *IBBlock) SetCounterBits(numBits int) {
+	ibb.Matrix.SetCounterBits(numBits)
+	ibb.SyncCounterBits()
+}
+
+func (ibb *IBBlock) Merge(other *IBBlock) error {
+	if err := ibb.Sum(other); err != nil {
+		return err
 	}
 
-	ibb.Matrix.Add(matrix)
+	ibb.NumDroppedSNPS += other.NumDroppedSNPS
+	ibb.MergedBlocks = append(ibb.MergedBlocks, other.BlockNumber)
+	ibb.MergedBlocks = append(ibb.MergedBlocks, other.MergedBlocks...)
+
+	return nil
+}
+
+func (ibb *IBBlock) IsAffected() bool {
+	return ibb.BelowMinSnps || ibb.AboveMaxSnps || ibb.Masked || len(ibb.MergedBlocks) > 0
+}
+
+//
+// Samples
+//
+
+func (ibb *IBBlock) SetNumSamples(numSamples uint64) {
+	ibb.NumSamples = numSamples
+	ibb.Matrix.Resize(numSamples)
+}
+
+//
+// Position
+//
+
+func (ibb *IBBlock) SetBlockPosition(blockPosition uint64) {
+	ibb.BlockPosition = blockPosition
+	ibb.Matrix.BlockPosition = blockPosition
 }
 
 func (ibb *IBBlock) IsEqual(other *IBBlock) (res bool) {
@@ -277,33 +352,37 @@ func (ibb *IBBlock) GenFilename(outPrefix string, format string, compression str
 // Save
 //
 
-func (ibb *IBBlock) Save(outPrefix string, format string, compression string) {
-	ibb.saveLoad(true, outPrefix, format, compression)
+func (ibb *IBBlock) Save(outPrefix string, format string, compression string) error {
+	return ibb.saveLoad(true, outPrefix, format, compression)
 }
 
 //
 // Load
 //
 
-func (ibb *IBBlock) Load(outPrefix string, format string, compression string) {
-	ibb.saveLoad(false, outPrefix, format, compression)
+func (ibb *IBBlock) Load(outPrefix string, format string, compression string) error {
+	return ibb.saveLoad(false, outPrefix, format, compression)
 }
 
 //
 // SaveLoad
 //
 
-func (ibb *IBBlock) saveLoad(isSave bool, outPrefix string, format string, compression string) {
+func (ibb *IBBlock) saveLoad(isSave bool, outPrefix string, format string, compression string) error {
 	baseName, _ := ibb.GenFilename(outPrefix, format, compression)
 	saver := NewSaverCompressed(baseName, format, compression)
 
 	if isSave {
 		fmt.Printf("saving block             :  %-70s block num: %d block pos: %d\n", baseName, ibb.BlockNumber, ibb.BlockPosition)
-		saver.Save(ibb)
-		ibb.Matrix.Save(baseName, format, compression)
+		if err := saver.Save(ibb); err != nil {
+			return err
+		}
+		return ibb.Matrix.Save(baseName, format, compression)
 	} else {
 		fmt.Printf("loading block            :  %-70s block num: %d block pos: %d\n", baseName, ibb.BlockNumber, ibb.BlockPosition)
-		saver.Load(ibb)
+		if err := saver.Load(ibb); err != nil {
+			return err
+		}
 
 		ibb.Matrix = NewDistanceMatrix(
 			ibb.ChromosomeName,
@@ -314,7 +393,7 @@ func (ibb *IBBlock) saveLoad(isSave bool, outPrefix string, format string, compr
 			ibb.BlockNumber,
 		)
 
-		ibb.Matrix.Load(baseName, format, compression)
+		return ibb.Matrix.Load(baseName, format, compression)
 	}
 }
 
@@ -322,31 +401,73 @@ func (ibb *IBBlock) saveLoad(isSave bool, outPrefix string, format string, compr
 // Dump
 //
 
-func (ibb *IBBlock) Dump(dumper *MultiArrayFile, isSave bool) {
+// ReadMatrix reads a copy of the block matrix from a memory mapped dump.
+// Databases saved before registers had their own size use the fixed registerSize.
+func (ibb *IBBlock) ReadMatrix(mm *MmapArrayFile, registerSize uint64) (*IBDistanceMatrix, error) {
+	offset := ibb.RegisterOffset
+
+	if ibb.RegisterSize == 0 {
+		offset = registerSize * uint64(ibb.Serial)
+	}
+
+	table := make(IBDistanceTable, 0, 0)
+
+	serial, _, err := mm.ReadAt(offset, &table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if serial != ibb.Serial {
+		return nil, fmt.Errorf("%w: block %s #%d serial %d != %d in %s", ErrCorrupt, ibb.ChromosomeName, ibb.BlockNumber, ibb.Serial, serial, mm.GetFileName())
+	}
+
+	matrix := *ibb.Matrix
+
+	if err := matrix.SetTable(&table); err != nil {
+		return nil, err
+	}
+
+	return &matrix, nil
+}
+
+// Dump writes or reads the block matrix. Errors of the dumper are returned.
+func (ibb *IBBlock) Dump(dumper *MultiArrayFile, isSave bool) error {
 	serial := int64(0)
 	hasData := false
 	matrix, hasMatrix := ibb.GetMatrix()
 
 	if !hasMatrix {
-		fmt.Println("failed getting matrix")
-		os.Exit(1)
+		return fmt.Errorf("block %s #%d: failed getting matrix", ibb.ChromosomeName, ibb.BlockNumber)
 	}
 
 	if isSave {
+		ibb.SyncCounterBits()
 		serial = matrix.Dump(dumper)
 		ibb.SetSerial(serial)
+		ibb.RegisterOffset, ibb.RegisterSize, ibb.RegisterBits = dumper.GetLastRegister()
 
 	} else {
-		hasData, serial = matrix.UnDump(dumper)
+		var err error
+
+		hasData, serial, err = matrix.UnDump(dumper)
+
+		if derr := dumper.Err(); derr != nil {
+			return derr
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w in %s", err, dumper.GetFileName())
+		}
 
 		if !hasData {
-			fmt.Println("Tried to read beyond the file")
-			os.Exit(1)
+			return fmt.Errorf("%w: block %s #%d: tried to read beyond the file %s", ErrCorrupt, ibb.ChromosomeName, ibb.BlockNumber, dumper.GetFileName())
 		}
 
 		if !ibb.CheckSerial(serial) {
-			fmt.Println("Mismatch in order of files")
-			os.Exit(1)
+			return fmt.Errorf("%w: block %s #%d: mismatch in order of files %s", ErrCorrupt, ibb.ChromosomeName, ibb.BlockNumber, dumper.GetFileName())
 		}
 	}
+
+	return dumper.Err()
 }
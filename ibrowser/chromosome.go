@@ -3,7 +3,8 @@ package ibrowser
 import (
 	"fmt"
 	"math"
-	"os"
+	"sort"
+	"strconv"
 )
 
 import "runtime/debug"
@@ -17,6 +18,7 @@ import "runtime/debug"
 type IBChromosome struct {
 	ChromosomeName   string
 	ChromosomeNumber int
+	BlockMode        string
 	BlockSize        uint64
 	CounterBits      int
 	NumSamples       uint64
@@ -25,15 +27,28 @@ type IBChromosome struct {
 	NumBlocks        uint64
 	NumSNPS          uint64
 	KeepEmptyBlock   bool
+	MinSnpPerBlock   uint64
+	MaxSnpPerBlock   uint64
+	MinSnpPolicy     string
+	MaxSnpPolicy     string
 	BlockNames       map[uint64]uint64
 	Block            *IBBlock
 	Blocks           []*IBBlock
+	Levels           []*IBLevel
+	seenSNPS         uint64
+	blockSNPS        map[uint64]uint64
+	extensionSNPS    uint64
+	extensionSeen    map[uint64]uint64
+	extensionCounts  map[uint64]uint64
+	released         bool
+	passedCheck      bool
 }
 
 func (ibc *IBChromosome) String() string {
 	return fmt.Sprint("Block :: ",
 		" ChromosomeName:   ", ibc.ChromosomeName, "\n",
 		" ChromosomeNumber: ", ibc.ChromosomeNumber, "\n",
+		" BlockMode:        ", ibc.BlockMode, "\n",
 		" BlockSize:        ", ibc.BlockSize, "\n",
 		" CounterBits:      ", ibc.CounterBits, "\n",
 		" NumSamples:       ", ibc.NumSamples, "\n",
@@ -42,13 +57,18 @@ func (ibc *IBChromosome) String() string {
 		" NumBlocks:        ", ibc.NumBlocks, "\n",
 		" NumSNPS:          ", ibc.NumSNPS, "\n",
 		" KeepEmptyBlock:   ", ibc.KeepEmptyBlock, "\n",
+		" MinSnpPerBlock:   ", ibc.MinSnpPerBlock, "\n",
+		" MaxSnpPerBlock:   ", ibc.MaxSnpPerBlock, "\n",
+		" MinSnpPolicy:     ", ibc.MinSnpPolicy, "\n",
+		" MaxSnpPolicy:     ", ibc.MaxSnpPolicy, "\n",
 		" NumSBlockNames:   ", ibc.BlockNames, "\n",
 	)
 }
 
-func NewIBChromosome(chromosomeName string, chromosomeNumber int, blockSize uint64, counterBits int, numSamples uint64, keepEmptyBlock bool) *IBChromosome {
+func NewIBChromosome(chromosomeName string, chromosomeNumber int, blockMode string, blockSize uint64, counterBits int, numSamples uint64, keepEmptyBlock bool) *IBChromosome {
 	fmt.Println("  NewIBChromosome :: chromosomeName: ", chromosomeName,
 		" chromosomeNumber: ", chromosomeNumber,
+		" blockMode: ", blockMode,
 		" blockSize: ", blockSize,
 		" counterBits: ", counterBits,
 		" numSamples: ", numSamples,
@@ -57,6 +77,7 @@ func NewIBChromosome(chromosomeName string, chromosomeNumber int, blockSize uint
 	ibc := IBChromosome{
 		ChromosomeName:   chromosomeName,
 		ChromosomeNumber: chromosomeNumber,
+		BlockMode:        blockMode,
 		BlockSize:        blockSize,
 		CounterBits:      counterBits,
 		NumSamples:       numSamples,
@@ -65,20 +86,31 @@ func NewIBChromosome(chromosomeName string, chromosomeNumber int, blockSize uint
 		NumBlocks:        0,
 		NumSNPS:          0,
 		KeepEmptyBlock:   keepEmptyBlock,
+		MinSnpPerBlock:   0,
+		MaxSnpPerBlock:   math.MaxUint64,
+		MinSnpPolicy:     SNP_POLICY_NONE,
+		MaxSnpPolicy:     SNP_POLICY_NONE,
 		BlockNames:       make(map[uint64]uint64, 100),
 		Block:            NewIBBlock("_"+chromosomeName+"_block", chromosomeNumber, blockSize, counterBits, numSamples, 0, 0),
 		Blocks:           make([]*IBBlock, 0, 100),
+		Levels:           make([]*IBLevel, 0, 0),
 	}
 
 	return &ibc
 }
 
-func (ibc *IBChromosome) AppendBlock(blockNum uint64) (block *IBBlock) {
+func (ibc *IBChromosome) SetSnpLimits(minSnpPerBlock uint64, maxSnpPerBlock uint64, minSnpPolicy string, maxSnpPolicy string) {
+	ibc.MinSnpPerBlock = minSnpPerBlock
+	ibc.MaxSnpPerBlock = maxSnpPerBlock
+	ibc.MinSnpPolicy = minSnpPolicy
+	ibc.MaxSnpPolicy = maxSnpPolicy
+}
+
+func (ibc *IBChromosome) AppendBlock(blockNum uint64) (block *IBBlock, err error) {
 	// fmt.Println("IBChromosome :: AppendBlock :: blockNum: ", blockNum)
 
 	if ibc.HasBlock(blockNum) {
-		fmt.Println("tried to append existing blockNum:", blockNum)
-		os.Exit(1)
+		return nil, fmt.Errorf("%w: tried to append existing blockNum: %d to chromosome %s", ErrInvalid, blockNum, ibc.ChromosomeName)
 	}
 
 	blockPos := uint64(len(ibc.Blocks))
@@ -99,7 +131,7 @@ func (ibc *IBChromosome) AppendBlock(blockNum uint64) (block *IBBlock) {
 
 	ibc.NumBlocks = uint64(len(ibc.BlockNames))
 
-	return block
+	return block, nil
 }
 
 func (ibc *IBChromosome) HasBlock(blockNum uint64) bool {
@@ -129,7 +161,7 @@ func (ibc *IBChromosome) GetBlock(blockNum uint64) (*IBBlock, bool) {
 			fmt.Println(&ibc, "BlockNames", ibc.BlockNames)
 			fmt.Println(&ibc, "Blocks", ibc.Blocks)
 			debug.PrintStack()
-			os.Exit(1)
+			return nil, false
 		}
 
 		return ibc.Blocks[blockPos], ok
@@ -150,7 +182,7 @@ func (ibc *IBChromosome) GetColumn(referenceNumber int) (*[]*IBDistanceTable, bo
 	return &cols, false
 }
 
-func (ibc *IBChromosome) normalizeBlocks(blockNum uint64) (*IBBlock, bool, uint64) {
+func (ibc *IBChromosome) normalizeBlocks(blockNum uint64) (*IBBlock, bool, uint64, error) {
 	// fmt.Println("IBChromosome :: normalizeBlocks :: blockNum: ", blockNum)
 
 	block, hasBlock := ibc.GetBlock(blockNum)
@@ -174,31 +206,56 @@ func (ibc *IBChromosome) normalizeBlocks(blockNum uint64) (*IBBlock, bool, uint6
 
 			for currBlockPos := lastBlockPos; currBlockPos < blockNum; currBlockPos++ {
 				fmt.Println("IBChromosome :: normalizeBlocks :: blockNum: ", blockNum, " NEW. adding intermediate: ", currBlockPos)
-				ibc.AppendBlock(currBlockPos)
+				if _, err := ibc.AppendBlock(currBlockPos); err != nil {
+					return nil, isNew, numBlocksAdded, err
+				}
 				numBlocksAdded++
 			}
 		}
 
-		block := ibc.AppendBlock(blockNum)
+		block, err := ibc.AppendBlock(blockNum)
+
+		if err != nil {
+			return nil, isNew, numBlocksAdded, err
+		}
 
 		numBlocksAdded++
 
-		return block, isNew, numBlocksAdded
+		return block, isNew, numBlocksAdded, nil
 
 	} else {
 		isNew = false
-		return block, isNew, numBlocksAdded
+		return block, isNew, numBlocksAdded, nil
+	}
+}
+
+func (ibc *IBChromosome) GetBlockNumber(position uint64) uint64 {
+	if ibc.BlockMode == BLOCK_MODE_SNPS {
+		// fixed number of SNPs per block. relies on the VCF being sorted by position.
+		// dropped SNPs are counted, so downsampled blocks still advance
+		return ibc.seenSNPS / ibc.BlockSize
 	}
 
-	return &IBBlock{}, false, numBlocksAdded
+	return position / ibc.BlockSize
 }
 
-func (ibc *IBChromosome) Add(reg *VCFRegister) (uint64, bool, uint64) {
+func (ibc *IBChromosome) Add(reg *VCFRegister) (uint64, bool, uint64, bool, error) {
 	position := reg.Position
 	distance := reg.Distance
-	blockNum := position / ibc.BlockSize
+	blockNum := ibc.GetBlockNumber(position)
+
+	block, isNew, numBlocksAdded, err := ibc.normalizeBlocks(blockNum)
+
+	if err != nil {
+		return blockNum, isNew, numBlocksAdded, false, err
+	}
 
-	block, isNew, numBlocksAdded := ibc.normalizeBlocks(blockNum)
+	ibc.seenSNPS++
+
+	if ibc.MaxSnpPolicy == SNP_POLICY_DOWNSAMPLE && !isSampled(block.NumSNPS+block.NumDroppedSNPS, ibc.blockSNPS[blockNum], ibc.MaxSnpPerBlock) {
+		block.NumDroppedSNPS++
+		return blockNum, isNew, numBlocksAdded, false, nil
+	}
 
 	block.AddVcfMatrix(position, distance)
 	ibc.Block.AddVcfMatrix(position, distance)
@@ -206,7 +263,509 @@ func (ibc *IBChromosome) Add(reg *VCFRegister) (uint64, bool, uint64) {
 	ibc.MinPosition = Min64(ibc.MinPosition, block.MinPosition)
 	ibc.MaxPosition = Max64(ibc.MaxPosition, block.MaxPosition)
 
-	return blockNum, isNew, numBlocksAdded
+	return blockNum, isNew, numBlocksAdded, true, nil
+}
+
+//
+// Limits
+//
+
+// SetBlockSNPS sets the number of SNPs of each block, counted before reading
+func (ibc *IBChromosome) SetBlockSNPS(blockSNPS map[uint64]uint64) {
+	ibc.blockSNPS = blockSNPS
+}
+
+// isSampled tells whether the SNP at index, of numSNPS in its block, is one of
+// the maxSNPS kept by downsampling. Kept SNPs are evenly spread over the block.
+// Blocks not counted beforehand (numSNPS 0) keep their first SNPs.
+func isSampled(index uint64, numSNPS uint64, maxSNPS uint64) bool {
+	if numSNPS <= maxSNPS {
+		return index < maxSNPS
+	}
+
+	return (index+1)*maxSNPS/numSNPS > index*maxSNPS/numSNPS
+}
+
+// ApplySnpLimits flags, masks or merges blocks according to the snp policies.
+// Returns true if the summary block has to be rebuilt.
+func (ibc *IBChromosome) ApplySnpLimits() (needsRebuild bool, err error) {
+	needsRebuild = false
+
+	if ibc.MinSnpPolicy == SNP_POLICY_MERGE {
+		if err := ibc.mergeBlocks(); err != nil {
+			return needsRebuild, err
+		}
+	}
+
+	for _, block := range ibc.Blocks {
+		block.BelowMinSnps = false
+		block.AboveMaxSnps = false
+
+		if ibc.MinSnpPolicy != SNP_POLICY_NONE && block.NumSNPS < ibc.MinSnpPerBlock {
+			block.BelowMinSnps = true
+
+			if ibc.MinSnpPolicy == SNP_POLICY_MASK && !block.Masked {
+				block.Masked = true
+				needsRebuild = true
+			}
+		}
+
+		if ibc.MaxSnpPolicy != SNP_POLICY_NONE && (block.NumSNPS > ibc.MaxSnpPerBlock || block.NumDroppedSNPS > 0) {
+			block.AboveMaxSnps = true
+		}
+
+		if block.IsAffected() {
+			fmt.Printf("chromosome %s block num: %d block pos: %d snps: %d dropped: %d below min: %#v above max: %#v masked: %#v merged: %v\n",
+				ibc.ChromosomeName,
+				block.BlockNumber,
+				block.BlockPosition,
+				block.NumSNPS,
+				block.NumDroppedSNPS,
+				block.BelowMinSnps,
+				block.AboveMaxSnps,
+				block.Masked,
+				block.MergedBlocks,
+			)
+		}
+	}
+
+	return needsRebuild, nil
+}
+
+// mergeBlocks merges every block with less than MinSnpPerBlock SNPs into the
+// following block. The last block, if still too small, is merged into the
+// previous one. Merged block numbers keep pointing to the surviving block.
+func (ibc *IBChromosome) mergeBlocks() error {
+	blocks := make([]*IBBlock, 0, len(ibc.Blocks))
+
+	for _, block := range ibc.Blocks {
+		numBlocks := len(blocks)
+
+		if numBlocks > 0 && blocks[numBlocks-1].NumSNPS < ibc.MinSnpPerBlock {
+			if err := blocks[numBlocks-1].Merge(block); err != nil {
+				return err
+			}
+		} else {
+			blocks = append(blocks, block)
+		}
+	}
+
+	numBlocks := len(blocks)
+
+	if numBlocks > 1 && blocks[numBlocks-1].NumSNPS < ibc.MinSnpPerBlock {
+		if err := blocks[numBlocks-2].Merge(blocks[numBlocks-1]); err != nil {
+			return err
+		}
+		blocks = blocks[:numBlocks-1]
+	}
+
+	ibc.BlockNames = make(map[uint64]uint64, len(blocks))
+
+	for blockPos, block := range blocks {
+		block.SetBlockPosition(uint64(blockPos))
+
+		ibc.BlockNames[block.BlockNumber] = uint64(blockPos)
+
+		for _, mergedNum := range block.MergedBlocks {
+			ibc.BlockNames[mergedNum] = uint64(blockPos)
+		}
+	}
+
+	fmt.Println("chromosome", ibc.ChromosomeName, "merged", len(ibc.Blocks), "blocks into", len(blocks))
+
+	ibc.Blocks = blocks
+	ibc.NumBlocks = uint64(len(blocks))
+
+	return nil
+}
+
+//
+// Merge
+//
+
+// Merge sums the blocks of other into this chromosome. Blocks sharing a block
+// number are summed, the remaining ones are inserted in block number order.
+// Levels are dropped and have to be rebuilt by the caller.
+func (ibc *IBChromosome) Merge(other *IBChromosome) error {
+	fmt.Println("  IBChromosome :: Merge :: ", ibc.ChromosomeName, " blocks: ", len(ibc.Blocks), " + ", len(other.Blocks))
+
+	blocks := make(map[uint64]*IBBlock, len(ibc.Blocks)+len(other.Blocks))
+	blockNums := make([]uint64, 0, len(ibc.Blocks)+len(other.Blocks))
+
+	for _, block := range ibc.Blocks {
+		blocks[block.BlockNumber] = block
+		blockNums = append(blockNums, block.BlockNumber)
+	}
+
+	for _, block := range other.Blocks {
+		if current, hasBlock := blocks[block.BlockNumber]; hasBlock {
+			if err := current.Sum(block); err != nil {
+				return err
+			}
+			current.NumDroppedSNPS += block.NumDroppedSNPS
+			// the snp limits are evaluated again over the summed block
+			current.Masked = false
+		} else {
+			block.ChromosomeNumber = ibc.ChromosomeNumber
+			blocks[block.BlockNumber] = block
+			blockNums = append(blockNums, block.BlockNumber)
+		}
+	}
+
+	sort.Slice(blockNums, func(i, j int) bool { return blockNums[i] < blockNums[j] })
+
+	ibc.Blocks = make([]*IBBlock, 0, len(blockNums))
+	ibc.BlockNames = make(map[uint64]uint64, len(blockNums))
+
+	for blockPos, blockNum := range blockNums {
+		block := blocks[blockNum]
+
+		block.SetBlockPosition(uint64(blockPos))
+
+		ibc.Blocks = append(ibc.Blocks, block)
+		ibc.BlockNames[blockNum] = uint64(blockPos)
+	}
+
+	ibc.NumBlocks = uint64(len(ibc.Blocks))
+	ibc.Levels = make([]*IBLevel, 0, 0)
+
+	return ibc.RebuildSummary()
+}
+
+// SetChromosomeNumber renumbers the chromosome and all its blocks
+func (ibc *IBChromosome) SetChromosomeNumber(chromosomeNumber int) {
+	ibc.ChromosomeNumber = chromosomeNumber
+	ibc.Block.ChromosomeNumber = chromosomeNumber
+
+	for _, block := range ibc.Blocks {
+		block.ChromosomeNumber = chromosomeNumber
+	}
+
+	for _, level := range ibc.Levels {
+		level.ChromosomeNumber = chromosomeNumber
+
+		for _, block := range level.Blocks {
+			block.ChromosomeNumber = chromosomeNumber
+		}
+	}
+}
+
+// SetCounterBits changes the width of the matrices of all blocks and levels
+func (ibc *IBChromosome) SetCounterBits(numBits int) {
+	ibc.CounterBits = numBits
+	ibc.Block.SetCounterBits(numBits)
+
+	for _, block := range ibc.Blocks {
+		block.SetCounterBits(numBits)
+	}
+
+	for _, level := range ibc.Levels {
+		for _, block := range level.Blocks {
+			block.SetCounterBits(numBits)
+		}
+	}
+}
+
+//
+// Extension
+//
+
+// ExtendSamples enlarges the matrices of all blocks to numSamples.
+// The summary and levels have to be rebuilt once the extension is added.
+func (ibc *IBChromosome) ExtendSamples(numSamples uint64) {
+	fmt.Println("  IBChromosome :: ExtendSamples :: ", ibc.ChromosomeName, " samples: ", ibc.NumSamples, " -> ", numSamples)
+
+	ibc.NumSamples = numSamples
+	ibc.extensionSNPS = 0
+	ibc.extensionCounts = make(map[uint64]uint64, len(ibc.Blocks))
+	ibc.extensionSeen = make(map[uint64]uint64, len(ibc.Blocks))
+
+	for _, block := range ibc.Blocks {
+		block.SetNumSamples(numSamples)
+	}
+}
+
+// AddExtension adds the distances of the new samples of an already registered SNP.
+// Returns false if the SNP does not belong to any block.
+func (ibc *IBChromosome) AddExtension(reg *VCFRegister) bool {
+	blockNum := reg.Position / ibc.BlockSize
+
+	if ibc.BlockMode == BLOCK_MODE_SNPS {
+		blockNum = ibc.extensionSNPS / ibc.BlockSize
+	}
+
+	// counts dropped SNPs, same as Add
+	ibc.extensionSNPS++
+
+	block, hasBlock := ibc.GetBlock(blockNum)
+
+	if !hasBlock {
+		return false
+	}
+
+	index := ibc.extensionSeen[blockNum]
+	ibc.extensionSeen[blockNum]++
+
+	// same SNPs as dropped by Add
+	if ibc.MaxSnpPolicy == SNP_POLICY_DOWNSAMPLE && !isSampled(index, ibc.blockSNPS[blockNum], ibc.MaxSnpPerBlock) {
+		return true
+	}
+
+	block.Matrix.AddVcfMatrix(reg.Distance)
+	block.SyncCounterBits()
+
+	ibc.extensionCounts[blockNum]++
+
+	return true
+}
+
+// CheckExtension checks that every block received as many SNPs as it had
+func (ibc *IBChromosome) CheckExtension() (res bool) {
+	res = true
+
+	for _, block := range ibc.Blocks {
+		numSNPS := ibc.extensionCounts[block.BlockNumber]
+
+		for _, mergedNum := range block.MergedBlocks {
+			numSNPS += ibc.extensionCounts[mergedNum]
+		}
+
+		res = res && (numSNPS == block.NumSNPS)
+
+		if !res {
+			fmt.Printf("Failed chromosome %s extension check - block %d NumSNPS: %d != %d\n", ibc.ChromosomeName, block.BlockNumber, block.NumSNPS, numSNPS)
+			return res
+		}
+	}
+
+	return res
+}
+
+func (ibc *IBChromosome) HasMergedBlocks() bool {
+	for _, block := range ibc.Blocks {
+		if len(block.MergedBlocks) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (ibc *IBChromosome) RebuildSummary() error {
+	sumBlock, err := ibc.GetSumBlocks()
+
+	if err != nil {
+		return err
+	}
+
+	ibc.Block = NewIBBlock("_"+ibc.ChromosomeName+"_block", ibc.ChromosomeNumber, ibc.BlockSize, ibc.CounterBits, ibc.NumSamples, 0, 0)
+
+	if err := ibc.Block.Sum(sumBlock); err != nil {
+		return err
+	}
+
+	ibc.NumSNPS = ibc.Block.NumSNPS
+	ibc.MinPosition = ibc.Block.MinPosition
+	ibc.MaxPosition = ibc.Block.MaxPosition
+
+	return nil
+}
+
+//
+// Release
+//
+
+// Release frees the matrices of the blocks and levels once saved, keeping the
+// summary block. passedCheck, the result of checking the chromosome before
+// releasing it, is returned by Check afterwards.
+func (ibc *IBChromosome) Release(passedCheck bool) {
+	for _, block := range ibc.Blocks {
+		block.Matrix.Release()
+	}
+
+	for _, level := range ibc.Levels {
+		for _, block := range level.Blocks {
+			block.Matrix.Release()
+		}
+	}
+
+	ibc.released = true
+	ibc.passedCheck = passedCheck
+}
+
+func (ibc *IBChromosome) IsReleased() bool {
+	return ibc.released
+}
+
+//
+// Levels
+//
+
+func (ibc *IBChromosome) AddLevel(name string, windowSize uint64, stepSize uint64) (*IBLevel, error) {
+	if _, hasLevel := ibc.GetLevel(name); hasLevel {
+		return nil, fmt.Errorf("%w: tried to add existing level: %s to chromosome %s", ErrInvalid, name, ibc.ChromosomeName)
+	}
+
+	level := NewIBLevel(name, ibc.ChromosomeName, ibc.ChromosomeNumber, windowSize, stepSize)
+
+	source := ibc.getLevelSource(windowSize, stepSize)
+
+	fmt.Println("  IBChromosome :: AddLevel :: ", ibc.ChromosomeName, " level: ", name, " source: ", source.Name)
+
+	if err := level.Aggregate(source.Blocks, source.WindowSize, ibc.CounterBits, ibc.NumSamples, ibc.KeepEmptyBlock); err != nil {
+		return nil, err
+	}
+
+	ibc.Levels = append(ibc.Levels, level)
+
+	return level, nil
+}
+
+// getLevelSource returns the coarsest non sliding level which can be summed
+// into windows of windowSize and stepSize. Falls back to the base blocks.
+func (ibc *IBChromosome) getLevelSource(windowSize uint64, stepSize uint64) *IBLevel {
+	source := ibc.GetBaseLevel()
+
+	for _, level := range ibc.Levels {
+		if level.IsSliding() {
+			continue
+		}
+
+		if windowSize%level.WindowSize != 0 || stepSize%level.WindowSize != 0 {
+			continue
+		}
+
+		if level.WindowSize > source.WindowSize {
+			source = level
+		}
+	}
+
+	return source
+}
+
+// GetBaseLevel returns the base blocks wrapped as a level
+func (ibc *IBChromosome) GetBaseLevel() *IBLevel {
+	return &IBLevel{
+		Name:             BASE_LEVEL_NAME,
+		ChromosomeName:   ibc.ChromosomeName,
+		ChromosomeNumber: ibc.ChromosomeNumber,
+		WindowSize:       ibc.BlockSize,
+		StepSize:         ibc.BlockSize,
+		NumBlocks:        ibc.NumBlocks,
+		NumSNPS:          ibc.NumSNPS,
+		BlockNames:       ibc.BlockNames,
+		Blocks:           ibc.Blocks,
+	}
+}
+
+// GetResolutionLevels returns the base level and all non sliding levels
+// sorted from the finest to the coarsest.
+func (ibc *IBChromosome) GetResolutionLevels() []*IBLevel {
+	levels := make([]*IBLevel, 0, len(ibc.Levels)+1)
+
+	levels = append(levels, ibc.GetBaseLevel())
+
+	for _, level := range ibc.Levels {
+		if !level.IsSliding() {
+			levels = append(levels, level)
+		}
+	}
+
+	sort.SliceStable(levels, func(i, j int) bool { return levels[i].WindowSize < levels[j].WindowSize })
+
+	return levels
+}
+
+// GetResolution accepts either a level name or a window size.
+// An empty resolution returns the base level.
+func (ibc *IBChromosome) GetResolution(resolution string) (*IBLevel, bool) {
+	if resolution == "" || resolution == BASE_LEVEL_NAME {
+		return ibc.GetBaseLevel(), true
+	}
+
+	if level, hasLevel := ibc.GetLevel(resolution); hasLevel {
+		return level, true
+	}
+
+	windowSize, err := strconv.ParseUint(resolution, 10, 64)
+
+	if err != nil {
+		return nil, false
+	}
+
+	for _, level := range ibc.GetResolutionLevels() {
+		if level.WindowSize == windowSize {
+			return level, true
+		}
+	}
+
+	return nil, false
+}
+
+// GetBestResolution returns the finest level with at most maxBlocks blocks
+// between start and end. If none fits, returns the coarsest one.
+func (ibc *IBChromosome) GetBestResolution(start uint64, end uint64, maxBlocks uint64) (*IBLevel, []*IBBlock) {
+	levels := ibc.GetResolutionLevels()
+
+	for _, level := range levels {
+		blocks := ibc.GetLevelBlocksInRange(level, start, end)
+
+		if uint64(len(blocks)) <= maxBlocks {
+			return level, blocks
+		}
+	}
+
+	level := levels[len(levels)-1]
+
+	return level, ibc.GetLevelBlocksInRange(level, start, end)
+}
+
+func (ibc *IBChromosome) GetLevelBlocksInRange(level *IBLevel, start uint64, end uint64) []*IBBlock {
+	blocks := make([]*IBBlock, 0, 0)
+
+	for _, block := range level.Blocks {
+		if ibc.BlockMode == BLOCK_MODE_SNPS || level.IsSliding() {
+			// no fixed coordinates. use the positions of the SNPs
+			if block.NumSNPS == 0 || block.MaxPosition < start || block.MinPosition > end {
+				continue
+			}
+		} else {
+			blockStart := block.BlockNumber * level.StepSize
+			blockEnd := blockStart + level.WindowSize - 1
+
+			if blockEnd < start || blockStart > end {
+				continue
+			}
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+func (ibc *IBChromosome) GetLevels() ([]*IBLevel, bool) {
+	return ibc.Levels, true
+}
+
+func (ibc *IBChromosome) GetLevel(name string) (*IBLevel, bool) {
+	for _, level := range ibc.Levels {
+		if level.Name == name {
+			return level, true
+		}
+	}
+	return nil, false
+}
+
+func (ibc *IBChromosome) GetAffectedBlocks() []*IBBlock {
+	blocks := make([]*IBBlock, 0, 0)
+
+	for _, block := range ibc.Blocks {
+		if block.IsAffected() {
+			blocks = append(blocks, block)
+		}
+	}
+
+	return blocks
 }
 
 //
@@ -214,6 +773,10 @@ func (ibc *IBChromosome) Add(reg *VCFRegister) (uint64, bool, uint64) {
 //
 
 func (ibc *IBChromosome) Check() (res bool) {
+	if ibc.released {
+		return ibc.passedCheck
+	}
+
 	res = true
 
 	res = res && ibc.selfCheck()
@@ -236,6 +799,73 @@ func (ibc *IBChromosome) Check() (res bool) {
 		}
 	}
 
+	for _, level := range ibc.Levels {
+		res = res && level.Check()
+
+		if !res {
+			fmt.Printf("Failed chromosome %s - level check - level %s\n", ibc.ChromosomeName, level.Name)
+			return res
+		}
+	}
+
+	return res
+}
+
+// IsEqual compares the blocks of the chromosome and of its levels
+func (ibc *IBChromosome) IsEqual(other *IBChromosome) (res bool) {
+	res = true
+
+	res = res && ibc.Block.IsEqual(other.Block)
+
+	if !res {
+		fmt.Printf("IsEqual :: Failed chromosome %s - summary block\n", ibc.ChromosomeName)
+		return res
+	}
+
+	res = res && isEqualBlocks(ibc.Blocks, other.Blocks)
+
+	if !res {
+		fmt.Printf("IsEqual :: Failed chromosome %s - blocks\n", ibc.ChromosomeName)
+		return res
+	}
+
+	res = res && (len(ibc.Levels) == len(other.Levels))
+
+	if !res {
+		fmt.Printf("IsEqual :: Failed chromosome %s - number of levels %d != %d\n", ibc.ChromosomeName, len(ibc.Levels), len(other.Levels))
+		return res
+	}
+
+	for levelPos, level := range ibc.Levels {
+		otherLevel := other.Levels[levelPos]
+
+		res = res && (level.Name == otherLevel.Name) && isEqualBlocks(level.Blocks, otherLevel.Blocks)
+
+		if !res {
+			fmt.Printf("IsEqual :: Failed chromosome %s - level %s != %s\n", ibc.ChromosomeName, level.Name, otherLevel.Name)
+			return res
+		}
+	}
+
+	return res
+}
+
+func isEqualBlocks(blocks []*IBBlock, others []*IBBlock) (res bool) {
+	res = len(blocks) == len(others)
+
+	if !res {
+		fmt.Printf("IsEqual :: Failed number of blocks %d != %d\n", len(blocks), len(others))
+		return res
+	}
+
+	for blockPos, block := range blocks {
+		res = res && block.IsEqual(others[blockPos])
+
+		if !res {
+			return res
+		}
+	}
+
 	return res
 }
 
@@ -249,7 +879,12 @@ func (ibc *IBChromosome) selfCheck() (res bool) {
 		return res
 	}
 
-	sumBlock := ibc.GetSumBlocks()
+	sumBlock, err := ibc.GetSumBlocks()
+
+	if err != nil {
+		fmt.Printf("Failed chromosome %s self check - %s\n", ibc.ChromosomeName, err)
+		return false
+	}
 
 	{
 		res = res && (ibc.Block.NumSNPS == sumBlock.NumSNPS)
@@ -308,7 +943,7 @@ func (ibc *IBChromosome) selfCheck() (res bool) {
 	return res
 }
 
-func (ibc *IBChromosome) GetSumBlocks() (sumBlock *IBBlock) {
+func (ibc *IBChromosome) GetSumBlocks() (sumBlock *IBBlock, err error) {
 	sumBlock = NewIBBlock(
 		ibc.ChromosomeName,
 		ibc.ChromosomeNumber,
@@ -320,10 +955,16 @@ func (ibc *IBChromosome) GetSumBlocks() (sumBlock *IBBlock) {
 	)
 
 	for _, block := range ibc.Blocks {
-		sumBlock.Sum(block)
+		if block.Masked {
+			continue
+		}
+
+		if err := sumBlock.Sum(block); err != nil {
+			return nil, err
+		}
 	}
 
-	return sumBlock
+	return sumBlock, nil
 }
 
 //
@@ -344,44 +985,52 @@ func (ibc *IBChromosome) GenFilename(outPrefix string, format string, compressio
 // Save
 //
 
-func (ibc *IBChromosome) Save(outPrefix string, format string, compression string) {
-	ibc.saveLoad(true, outPrefix, format, compression)
+func (ibc *IBChromosome) Save(outPrefix string, format string, compression string) error {
+	return ibc.saveLoad(true, outPrefix, format, compression)
 }
 
 //
 // Load
 //
-func (ibc *IBChromosome) Load(outPrefix string, format string, compression string) {
-	ibc.saveLoad(false, outPrefix, format, compression)
+func (ibc *IBChromosome) Load(outPrefix string, format string, compression string) error {
+	return ibc.saveLoad(false, outPrefix, format, compression)
 }
 
 //
 // SaveLoad
 //
 
-func (ibc *IBChromosome) saveLoad(isSave bool, outPrefix string, format string, compression string) {
+func (ibc *IBChromosome) saveLoad(isSave bool, outPrefix string, format string, compression string) error {
 	baseName, _ := ibc.GenFilename(outPrefix, format, compression)
 	saver := NewSaverCompressed(baseName, format, compression)
 
+	var err error
+
 	if isSave {
 		fmt.Println("saving chromosome        : ", baseName)
-		saver.Save(ibc)
+		err = saver.Save(ibc)
 	} else {
 		fmt.Println("loading chromosome       : ", baseName)
-		saver.Load(ibc)
+		err = saver.Load(ibc)
 	}
 
-	ibc.saveLoadBlock(isSave, baseName, format, compression)
-	ibc.saveLoadBlocks(isSave, baseName, format, compression)
+	if err != nil {
+		return err
+	}
 
+	if err := ibc.saveLoadBlock(isSave, baseName, format, compression); err != nil {
+		return err
+	}
+
+	return ibc.saveLoadBlocks(isSave, baseName, format, compression)
 }
 
-func (ibc *IBChromosome) saveLoadBlock(isSave bool, outPrefix string, format string, compression string) {
+func (ibc *IBChromosome) saveLoadBlock(isSave bool, outPrefix string, format string, compression string) error {
 	newPrefix := outPrefix + "_block"
 
 	if isSave {
 		fmt.Println("saving chromosome block  : ", newPrefix)
-		ibc.Block.Save(newPrefix, format, compression)
+		return ibc.Block.Save(newPrefix, format, compression)
 	} else {
 		fmt.Println("loading chromosome block : ", newPrefix)
 		ibc.Block = NewIBBlock(
@@ -393,11 +1042,11 @@ func (ibc *IBChromosome) saveLoadBlock(isSave bool, outPrefix string, format str
 			0,
 			0,
 		)
-		ibc.Block.Load(newPrefix, format, compression)
+		return ibc.Block.Load(newPrefix, format, compression)
 	}
 }
 
-func (ibc *IBChromosome) saveLoadBlocks(isSave bool, outPrefix string, format string, compression string) {
+func (ibc *IBChromosome) saveLoadBlocks(isSave bool, outPrefix string, format string, compression string) error {
 	newPrefix := outPrefix + "_blocks"
 	// fmt.Println("saving blocks", ibc.BlockNames)
 
@@ -407,7 +1056,9 @@ func (ibc *IBChromosome) saveLoadBlocks(isSave bool, outPrefix string, format st
 		if isSave {
 			block := ibc.Blocks[blockPos]
 			fmt.Printf("saving chromosome blocks :  %-70s block num: %d block pos: %d\n", newPrefix, blockNum, blockPos)
-			block.Save(newPrefix, format, compression)
+			if err := block.Save(newPrefix, format, compression); err != nil {
+				return err
+			}
 
 		} else {
 			fmt.Printf("loading chromosome blocks:  %-70s block num: %d block pos: %d\n", newPrefix, blockNum, blockPos)
@@ -424,7 +1075,11 @@ func (ibc *IBChromosome) saveLoadBlocks(isSave bool, outPrefix string, format st
 
 			ibc.Blocks = append(ibc.Blocks, block)
 
-			block.Load(newPrefix, format, compression)
+			if err := block.Load(newPrefix, format, compression); err != nil {
+				return err
+			}
 		}
 	}
+
+	return nil
 }
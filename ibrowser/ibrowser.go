@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -15,6 +16,20 @@ import (
 
 var mutex = &sync.Mutex{}
 
+const BLOCK_MODE_BP = "bp"
+const BLOCK_MODE_SNPS = "snps"
+
+var BlockModes = []string{BLOCK_MODE_BP, BLOCK_MODE_SNPS}
+
+const SNP_POLICY_NONE = "none"
+const SNP_POLICY_FLAG = "flag"
+const SNP_POLICY_MASK = "mask"
+const SNP_POLICY_MERGE = "merge"
+const SNP_POLICY_DOWNSAMPLE = "downsample"
+
+var MinSnpPolicies = []string{SNP_POLICY_NONE, SNP_POLICY_FLAG, SNP_POLICY_MASK, SNP_POLICY_MERGE}
+var MaxSnpPolicies = []string{SNP_POLICY_NONE, SNP_POLICY_FLAG, SNP_POLICY_DOWNSAMPLE}
+
 //
 //
 // IBROWSER SECTION
@@ -24,6 +39,7 @@ var mutex = &sync.Mutex{}
 type IBrowser struct {
 	Samples        VCFSamples
 	NumSamples     uint64
+	BlockMode      string
 	BlockSize      uint64
 	KeepEmptyBlock bool
 	NumRegisters   uint64
@@ -32,6 +48,7 @@ type IBrowser struct {
 	CounterBits    int
 	RegisterSize   uint64
 	Parameters     Parameters
+	LevelNames     []string
 	//
 	ChromosomesNames NamePosPairList
 	Chromosomes      map[string]*IBChromosome
@@ -40,12 +57,23 @@ type IBrowser struct {
 	lastChrom    string
 	lastPosition uint64
 	//
+	extensionDistance *VCFDistanceMatrix
+	//
+	dumpCounterBits int
+	//
+	snpCounts map[string]*ibSnpCounts
+	//
+	container *save.Container
+	//
+	stream *ibStream
+	//
 	// Header string
 	//
 	// TODO: per sample stats
 }
 
 func NewIBrowser(parameters Parameters) *IBrowser {
+	blockMode := parameters.BlockMode
 	blockSize := parameters.BlockSize
 	counterBits := parameters.CounterBits
 	keepEmptyBlock := parameters.KeepEmptyBlock
@@ -55,10 +83,38 @@ func NewIBrowser(parameters Parameters) *IBrowser {
 		os.Exit(1)
 	}
 
+	if blockMode == "" {
+		blockMode = BLOCK_MODE_BP
+	}
+
+	if _, hasMode := SliceIndex(len(BlockModes), func(i int) bool { return BlockModes[i] == blockMode }); !hasMode {
+		fmt.Println("invalid block mode", blockMode, ". valid modes are:", BlockModes)
+		os.Exit(1)
+	}
+
+	if parameters.MinSnpPolicy == "" {
+		parameters.MinSnpPolicy = SNP_POLICY_NONE
+	}
+
+	if _, hasPolicy := SliceIndex(len(MinSnpPolicies), func(i int) bool { return MinSnpPolicies[i] == parameters.MinSnpPolicy }); !hasPolicy {
+		fmt.Println("invalid min snp policy", parameters.MinSnpPolicy, ". valid policies are:", MinSnpPolicies)
+		os.Exit(1)
+	}
+
+	if parameters.MaxSnpPolicy == "" {
+		parameters.MaxSnpPolicy = SNP_POLICY_NONE
+	}
+
+	if _, hasPolicy := SliceIndex(len(MaxSnpPolicies), func(i int) bool { return MaxSnpPolicies[i] == parameters.MaxSnpPolicy }); !hasPolicy {
+		fmt.Println("invalid max snp policy", parameters.MaxSnpPolicy, ". valid policies are:", MaxSnpPolicies)
+		os.Exit(1)
+	}
+
 	ib := IBrowser{
 		Samples:    make(VCFSamples, 0, 100),
 		NumSamples: 0,
 		//
+		BlockMode:      blockMode,
 		BlockSize:      blockSize,
 		KeepEmptyBlock: keepEmptyBlock,
 		//
@@ -68,6 +124,7 @@ func NewIBrowser(parameters Parameters) *IBrowser {
 		CounterBits:  counterBits,
 		RegisterSize: 0,
 		Parameters:   parameters,
+		LevelNames:   make([]string, 0, 0),
 		//
 		lastChrom:    "",
 		lastPosition: 0,
@@ -93,46 +150,66 @@ func (ib *IBrowser) SetSamples(samples *VCFSamples) {
 	}
 }
 
-func (ib *IBrowser) GetOrCreateChromosome(chromosomeName string, chromosomeNumber int) *IBChromosome {
+func (ib *IBrowser) GetOrCreateChromosome(chromosomeName string, chromosomeNumber int) (*IBChromosome, error) {
 	if chromosome, ok := ib.GetChromosome(chromosomeName); ok {
 		// fmt.Println("GetOrCreateChromosome", chromosomeName, "exists", &chromosome)
-		return chromosome
+		return chromosome, nil
 	} else {
 		// fmt.Println("GetOrCreateChromosome", chromosomeName, "creating")
 		return ib.AddChromosome(chromosomeName, chromosomeNumber)
 	}
 }
 
-func (ib *IBrowser) AddChromosome(chromosomeName string, chromosomeNumber int) *IBChromosome {
-	if chromosome, hasChromosome := ib.GetChromosome(chromosomeName); hasChromosome {
-		fmt.Println("Failed to add chromosome", chromosomeName, ". Already exists", &chromosome)
-		os.Exit(1)
+func (ib *IBrowser) AddChromosome(chromosomeName string, chromosomeNumber int) (*IBChromosome, error) {
+	if _, hasChromosome := ib.GetChromosome(chromosomeName); hasChromosome {
+		return nil, fmt.Errorf("%w: failed to add chromosome %s. already exists", ErrInvalid, chromosomeName)
 	}
 
-	ib.Chromosomes[chromosomeName] = NewIBChromosome(chromosomeName, chromosomeNumber, ib.BlockSize, ib.CounterBits, ib.NumSamples, ib.KeepEmptyBlock)
+	ib.Chromosomes[chromosomeName] = NewIBChromosome(chromosomeName, chromosomeNumber, ib.BlockMode, ib.BlockSize, ib.CounterBits, ib.NumSamples, ib.KeepEmptyBlock)
+
+	ib.Chromosomes[chromosomeName].SetSnpLimits(
+		ib.Parameters.MinSnpPerBlock,
+		ib.Parameters.MaxSnpPerBlock,
+		ib.Parameters.MinSnpPolicy,
+		ib.Parameters.MaxSnpPolicy,
+	)
+
+	if counts, ok := ib.snpCounts[chromosomeName]; ok {
+		ib.Chromosomes[chromosomeName].SetBlockSNPS(counts.blocks)
+	}
 
 	ib.ChromosomesNames = append(ib.ChromosomesNames, NamePosPair{chromosomeName, chromosomeNumber})
 
 	sort.Sort(ib.ChromosomesNames)
 
-	return ib.Chromosomes[chromosomeName]
+	return ib.Chromosomes[chromosomeName], nil
 }
 
-func (ib *IBrowser) RegisterCallBack(samples *VCFSamples, reg *VCFRegister) {
+func (ib *IBrowser) RegisterCallBack(samples *VCFSamples, reg *VCFRegister) error {
 	if atomic.LoadUint64(&ib.NumSamples) == 0 {
 		ib.SetSamples(samples)
 
 	} else {
 		if len(ib.Samples) != len(*samples) {
-			fmt.Println("Sample mismatch")
-			fmt.Println(len(ib.Samples), "!=", len(*samples))
-			os.Exit(1)
+			return fmt.Errorf("%w: sample mismatch: %d != %d", ErrCorrupt, len(ib.Samples), len(*samples))
 		}
 	}
 
-	chromosome := ib.GetOrCreateChromosome(reg.Chromosome, reg.ChromosomeNumber)
+	chromosome, err := ib.GetOrCreateChromosome(reg.Chromosome, reg.ChromosomeNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if chromosome.IsReleased() {
+		return fmt.Errorf("%w: chromosome %s was already saved. the VCF file must be sorted by chromosome to be streamed", ErrInvalid, reg.Chromosome)
+	}
 
-	_, isNew, numBlocksAdded := chromosome.Add(reg)
+	_, isNew, numBlocksAdded, isAdded, err := chromosome.Add(reg)
+
+	if err != nil {
+		return err
+	}
 
 	mutex.Lock()
 	{
@@ -142,11 +219,492 @@ func (ib *IBrowser) RegisterCallBack(samples *VCFSamples, reg *VCFRegister) {
 
 		ib.NumRegisters++
 
-		ib.NumSNPS++
+		if ib.stream != nil {
+			ib.stream.registers[reg.Chromosome]++
+		}
+
+		if isAdded {
+			ib.NumSNPS++
 
-		ib.Block.AddVcfMatrix(0, reg.Distance)
+			ib.Block.AddVcfMatrix(0, reg.Distance)
+		}
 	}
 	mutex.Unlock()
+
+	return nil
+}
+
+//
+// Limits
+//
+
+// over populated blocks are downsampled, not split. split blocks would not
+// line up with the windows of the levels
+
+type ibSnpCounts struct {
+	numSNPS uint64
+	blocks  map[uint64]uint64
+}
+
+// NeedsSnpCounts tells whether the SNPs of each block have to be counted with
+// CountCallBack before reading
+func (ib *IBrowser) NeedsSnpCounts() bool {
+	return ib.Parameters.MaxSnpPolicy == SNP_POLICY_DOWNSAMPLE
+}
+
+// CountCallBack counts the SNPs of each block, so downsampling can spread the
+// kept SNPs evenly over the block. Registers have no distance.
+func (ib *IBrowser) CountCallBack(samples *VCFSamples, reg *VCFRegister) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if ib.snpCounts == nil {
+		ib.snpCounts = make(map[string]*ibSnpCounts)
+	}
+
+	counts, ok := ib.snpCounts[reg.Chromosome]
+
+	if !ok {
+		counts = &ibSnpCounts{blocks: make(map[uint64]uint64)}
+		ib.snpCounts[reg.Chromosome] = counts
+	}
+
+	// same as IBChromosome.GetBlockNumber
+	blockNum := reg.Position / ib.BlockSize
+
+	if ib.BlockMode == BLOCK_MODE_SNPS {
+		blockNum = counts.numSNPS / ib.BlockSize
+	}
+
+	counts.blocks[blockNum]++
+	counts.numSNPS++
+
+	return nil
+}
+
+func (ib *IBrowser) ApplySnpLimits() error {
+	fmt.Println("applying snp limits",
+		" min: ", ib.Parameters.MinSnpPerBlock, " policy: ", ib.Parameters.MinSnpPolicy,
+		" max: ", ib.Parameters.MaxSnpPerBlock, " policy: ", ib.Parameters.MaxSnpPolicy,
+	)
+
+	needsRebuild := false
+	ib.NumBlocks = 0
+
+	for _, chromosome := range ib.GetChromosomes() {
+		chromosomeNeedsRebuild, err := chromosome.ApplySnpLimits()
+
+		if err != nil {
+			return err
+		}
+
+		needsRebuild = chromosomeNeedsRebuild || needsRebuild
+		ib.NumBlocks += chromosome.NumBlocks
+	}
+
+	if needsRebuild {
+		return ib.RebuildSummary()
+	}
+
+	return nil
+}
+
+//
+// Levels
+//
+
+func (ib *IBrowser) AddSlidingWindow(windowSize uint64, stepSize uint64) error {
+	if windowSize == 0 {
+		return nil
+	}
+
+	if stepSize == 0 {
+		stepSize = ib.BlockSize
+	}
+
+	if windowSize%ib.BlockSize != 0 || stepSize%ib.BlockSize != 0 {
+		return fmt.Errorf("%w: sliding window size %d and step %d must be multiples of the block size %d", ErrInvalid, windowSize, stepSize, ib.BlockSize)
+	}
+
+	name := fmt.Sprintf("sliding_%d_%d", windowSize, stepSize)
+
+	return ib.AddLevel(name, windowSize, stepSize)
+}
+
+func (ib *IBrowser) AddResolutions(resolutions []uint64) error {
+	sorted := make([]uint64, len(resolutions), len(resolutions))
+	copy(sorted, resolutions)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for _, resolution := range sorted {
+		if resolution == ib.BlockSize {
+			continue
+		}
+
+		if resolution%ib.BlockSize != 0 {
+			return fmt.Errorf("%w: resolution %d must be a multiple of the block size %d", ErrInvalid, resolution, ib.BlockSize)
+		}
+
+		name := fmt.Sprintf("resolution_%d", resolution)
+
+		if err := ib.AddLevel(name, resolution, resolution); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ib *IBrowser) AddLevel(name string, windowSize uint64, stepSize uint64) error {
+	fmt.Println("adding level", name, "window size", windowSize, "step size", stepSize)
+
+	if ib.HasLevel(name) {
+		return fmt.Errorf("%w: tried to add existing level: %s", ErrInvalid, name)
+	}
+
+	for _, chromosome := range ib.GetChromosomes() {
+		if _, err := chromosome.AddLevel(name, windowSize, stepSize); err != nil {
+			return err
+		}
+	}
+
+	// chromosomes still being read get the level once finished
+	if ib.stream != nil {
+		ib.stream.levels = append(ib.stream.levels, &IBLevel{Name: name, WindowSize: windowSize, StepSize: stepSize})
+	}
+
+	ib.LevelNames = append(ib.LevelNames, name)
+
+	return nil
+}
+
+func (ib *IBrowser) HasLevel(name string) bool {
+	_, ok := SliceIndex(len(ib.LevelNames), func(i int) bool { return ib.LevelNames[i] == name })
+	return ok
+}
+
+//
+// Merge
+//
+
+// Merge adds all chromosomes of other into this database. Both databases must
+// have been created from the same samples with the same block parameters.
+// Chromosomes present in both have their blocks summed. Summaries, snp limits
+// and levels are rebuilt afterwards. Nothing is changed if the databases can
+// not be merged.
+func (ib *IBrowser) Merge(other *IBrowser) error {
+	fmt.Println("merging database", other.Parameters.Description, "into", ib.Parameters.Description)
+
+	if err := ib.CheckMergeable(other); err != nil {
+		return err
+	}
+
+	levels := ib.getLevelTemplates(other)
+
+	for _, otherChromosome := range other.GetChromosomes() {
+		chromosomeName := otherChromosome.ChromosomeName
+
+		if chromosome, hasChromosome := ib.GetChromosome(chromosomeName); hasChromosome {
+			if err := chromosome.Merge(otherChromosome); err != nil {
+				return err
+			}
+
+		} else {
+			chromosomeNumber := otherChromosome.ChromosomeNumber
+
+			if ib.hasChromosomeNumber(chromosomeNumber) {
+				chromosomeNumber = ib.nextChromosomeNumber()
+				fmt.Println("chromosome", chromosomeName, "renumbered from", otherChromosome.ChromosomeNumber, "to", chromosomeNumber)
+				otherChromosome.SetChromosomeNumber(chromosomeNumber)
+			}
+
+			ib.Chromosomes[chromosomeName] = otherChromosome
+			ib.ChromosomesNames = append(ib.ChromosomesNames, NamePosPair{chromosomeName, chromosomeNumber})
+
+			sort.Sort(ib.ChromosomesNames)
+		}
+	}
+
+	ib.NumRegisters += other.NumRegisters
+
+	if other.Parameters.SourceFile != "" {
+		ib.Parameters.SourceFile += "," + other.Parameters.SourceFile
+	}
+
+	if err := ib.ApplySnpLimits(); err != nil {
+		return err
+	}
+
+	if err := ib.RebuildSummary(); err != nil {
+		return err
+	}
+
+	return ib.rebuildLevels(levels)
+}
+
+// CheckMergeable checks whether other was created with the same samples and
+// block parameters and whether their shared chromosomes can be summed
+func (ib *IBrowser) CheckMergeable(other *IBrowser) error {
+	if ib.NumSamples != other.NumSamples {
+		return fmt.Errorf("%w: can not merge - NumSamples %d != %d", ErrInvalid, ib.NumSamples, other.NumSamples)
+	}
+
+	for samplePos, sampleName := range ib.Samples {
+		if sampleName != other.Samples[samplePos] {
+			return fmt.Errorf("%w: can not merge - sample %d %s != %s", ErrInvalid, samplePos, sampleName, other.Samples[samplePos])
+		}
+	}
+
+	if ib.BlockMode != other.BlockMode {
+		return fmt.Errorf("%w: can not merge - BlockMode %s != %s", ErrInvalid, ib.BlockMode, other.BlockMode)
+	}
+
+	if ib.BlockSize != other.BlockSize {
+		return fmt.Errorf("%w: can not merge - BlockSize %d != %d", ErrInvalid, ib.BlockSize, other.BlockSize)
+	}
+
+	if ib.CounterBits != other.CounterBits {
+		return fmt.Errorf("%w: can not merge - CounterBits %d != %d", ErrInvalid, ib.CounterBits, other.CounterBits)
+	}
+
+	if ib.KeepEmptyBlock != other.KeepEmptyBlock {
+		return fmt.Errorf("%w: can not merge - KeepEmptyBlock %#v != %#v", ErrInvalid, ib.KeepEmptyBlock, other.KeepEmptyBlock)
+	}
+
+	if ib.Parameters.MinSnpPerBlock != other.Parameters.MinSnpPerBlock || ib.Parameters.MinSnpPolicy != other.Parameters.MinSnpPolicy {
+		return fmt.Errorf("%w: can not merge - MinSnpPerBlock %d (%s) != %d (%s)", ErrInvalid, ib.Parameters.MinSnpPerBlock, ib.Parameters.MinSnpPolicy, other.Parameters.MinSnpPerBlock, other.Parameters.MinSnpPolicy)
+	}
+
+	if ib.Parameters.MaxSnpPerBlock != other.Parameters.MaxSnpPerBlock || ib.Parameters.MaxSnpPolicy != other.Parameters.MaxSnpPolicy {
+		return fmt.Errorf("%w: can not merge - MaxSnpPerBlock %d (%s) != %d (%s)", ErrInvalid, ib.Parameters.MaxSnpPerBlock, ib.Parameters.MaxSnpPolicy, other.Parameters.MaxSnpPerBlock, other.Parameters.MaxSnpPolicy)
+	}
+
+	for _, otherChromosome := range other.GetChromosomes() {
+		chromosomeName := otherChromosome.ChromosomeName
+
+		chromosome, hasChromosome := ib.GetChromosome(chromosomeName)
+
+		if !hasChromosome {
+			continue
+		}
+
+		if ib.BlockMode == BLOCK_MODE_SNPS {
+			return fmt.Errorf("%w: can not merge chromosome %s present in both databases in %s block mode", ErrInvalid, chromosomeName, BLOCK_MODE_SNPS)
+		}
+
+		if chromosome.HasMergedBlocks() || otherChromosome.HasMergedBlocks() {
+			return fmt.Errorf("%w: can not merge chromosome %s present in both databases with merged blocks", ErrInvalid, chromosomeName)
+		}
+	}
+
+	return nil
+}
+
+// getLevelTemplates returns the union of the levels of all databases
+func (ib *IBrowser) getLevelTemplates(others ...*IBrowser) (levels []*IBLevel) {
+	levels = make([]*IBLevel, 0, len(ib.LevelNames))
+	seen := make(map[string]bool, len(ib.LevelNames))
+
+	for _, db := range append([]*IBrowser{ib}, others...) {
+		for _, levelName := range db.LevelNames {
+			if seen[levelName] {
+				continue
+			}
+
+			for _, chromosome := range db.GetChromosomes() {
+				if level, hasLevel := chromosome.GetLevel(levelName); hasLevel {
+					levels = append(levels, level)
+					seen[levelName] = true
+					break
+				}
+			}
+		}
+	}
+
+	return levels
+}
+
+// rebuildLevels drops all levels and aggregates them again from the base blocks
+func (ib *IBrowser) rebuildLevels(levels []*IBLevel) error {
+	for _, chromosome := range ib.GetChromosomes() {
+		chromosome.Levels = make([]*IBLevel, 0, len(levels))
+	}
+
+	ib.LevelNames = make([]string, 0, len(levels))
+
+	for _, level := range levels {
+		if err := ib.AddLevel(level.Name, level.WindowSize, level.StepSize); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetCounterBits changes the width of the matrices of all blocks and
+// the narrowest width of the registers dumped afterwards.
+// Counters are kept wider if their values need it.
+func (ib *IBrowser) SetCounterBits(numBits int) {
+	fmt.Println("setting counter bits", ib.CounterBits, "->", numBits)
+
+	ib.CounterBits = numBits
+	ib.dumpCounterBits = numBits
+	ib.Parameters.CounterBits = numBits
+	ib.Block.SetCounterBits(numBits)
+
+	for _, chromosome := range ib.GetChromosomes() {
+		chromosome.SetCounterBits(numBits)
+	}
+}
+
+func (ib *IBrowser) hasChromosomeNumber(chromosomeNumber int) bool {
+	_, ok := SliceIndex(len(ib.ChromosomesNames), func(i int) bool { return ib.ChromosomesNames[i].Pos == chromosomeNumber })
+	return ok
+}
+
+func (ib *IBrowser) nextChromosomeNumber() (chromosomeNumber int) {
+	chromosomeNumber = 0
+
+	for _, chromNamePosPair := range ib.ChromosomesNames {
+		if chromNamePosPair.Pos >= chromosomeNumber {
+			chromosomeNumber = chromNamePosPair.Pos + 1
+		}
+	}
+
+	return
+}
+
+//
+// Extend
+//
+
+// ExtendSamples adds new samples to the database. samples must be the samples
+// already in the database. All matrices are enlarged keeping the old pairs.
+func (ib *IBrowser) ExtendSamples(samples *VCFSamples, newSamples *VCFSamples) error {
+	fmt.Println("extending database with", len(*newSamples), "new samples")
+
+	if uint64(len(*samples)) != ib.NumSamples {
+		return fmt.Errorf("%w: sample mismatch: %d != %d", ErrInvalid, ib.NumSamples, len(*samples))
+	}
+
+	for samplePos, sampleName := range *samples {
+		if ib.Samples[samplePos] != sampleName {
+			return fmt.Errorf("%w: sample mismatch at position %d: %s != %s", ErrInvalid, samplePos, ib.Samples[samplePos], sampleName)
+		}
+	}
+
+	for _, sampleName := range *newSamples {
+		if ib.HasSample(sampleName) {
+			return fmt.Errorf("%w: sample %s already in database", ErrInvalid, sampleName)
+		}
+	}
+
+	ib.Samples = append(ib.Samples, *newSamples...)
+	ib.NumSamples = uint64(len(ib.Samples))
+
+	for _, chromosome := range ib.GetChromosomes() {
+		chromosome.ExtendSamples(ib.NumSamples)
+
+		if counts, ok := ib.snpCounts[chromosome.ChromosomeName]; ok {
+			chromosome.SetBlockSNPS(counts.blocks)
+		}
+	}
+
+	ib.extensionDistance = NewVCFDistanceMatrix(ib.NumSamples)
+
+	return nil
+}
+
+// ExtendCallBack receives the same site from the VCF used to create the
+// database and from the VCF of the new samples
+func (ib *IBrowser) ExtendCallBack(samples *VCFSamples, reg *VCFRegister, newSamples *VCFSamples, newReg *VCFRegister) error {
+	if ib.extensionDistance == nil {
+		if err := ib.ExtendSamples(samples, newSamples); err != nil {
+			return err
+		}
+	}
+
+	chromosome, hasChromosome := ib.GetChromosome(reg.Chromosome)
+
+	if !hasChromosome {
+		return fmt.Errorf("%w: chromosome %s not in database", ErrInvalid, reg.Chromosome)
+	}
+
+	distance, err := CalculateDistanceExtension(reg.Samples, newReg.Samples, ib.extensionDistance)
+
+	if err != nil {
+		return fmt.Errorf("chromosome %s position %d: %w", reg.Chromosome, reg.Position, err)
+	}
+
+	reg.Distance = distance
+
+	if !chromosome.AddExtension(reg) {
+		return fmt.Errorf("%w: chromosome %s position %d does not belong to any block in database", ErrInvalid, reg.Chromosome, reg.Position)
+	}
+
+	return nil
+}
+
+// FinishExtension checks that all SNPs were seen and rebuilds summaries and levels
+func (ib *IBrowser) FinishExtension() error {
+	if ib.extensionDistance == nil {
+		return fmt.Errorf("%w: no SNPs found to extend the database", ErrInvalid)
+	}
+
+	for _, chromosome := range ib.GetChromosomes() {
+		if !chromosome.CheckExtension() {
+			return fmt.Errorf("%w: the VCF files do not have the same SNPs as the database", ErrInvalid)
+		}
+	}
+
+	levels := ib.getLevelTemplates()
+
+	if err := ib.RebuildSummary(); err != nil {
+		return err
+	}
+
+	if err := ib.rebuildLevels(levels); err != nil {
+		return err
+	}
+
+	ib.extensionDistance = nil
+
+	return nil
+}
+
+func (ib *IBrowser) RebuildSummary() error {
+	fmt.Println("rebuilding global ibrowser summary")
+
+	for _, chromosome := range ib.GetChromosomes() {
+		if err := chromosome.RebuildSummary(); err != nil {
+			return err
+		}
+	}
+
+	return ib.sumChromosomes()
+}
+
+// sumChromosomes rebuilds the whole genome block from the chromosome summaries
+func (ib *IBrowser) sumChromosomes() error {
+	ib.Block = NewIBBlock("_whole_genome", 0, ib.BlockSize, ib.CounterBits, ib.NumSamples, 0, 0)
+	ib.NumBlocks = 0
+
+	for _, chromosome := range ib.GetChromosomes() {
+		if err := ib.Block.Sum(chromosome.Block); err != nil {
+			return err
+		}
+		ib.NumBlocks += chromosome.NumBlocks
+	}
+
+	// the whole genome block has no coordinates. same as RegisterCallBack
+	if ib.Block.NumSNPS > 0 {
+		ib.Block.MinPosition = 0
+		ib.Block.MaxPosition = 0
+	}
+
+	ib.NumSNPS = ib.Block.NumSNPS
+
+	return nil
 }
 
 func (ib *IBrowser) Check() (res bool) {
@@ -176,6 +734,56 @@ func (ib *IBrowser) Check() (res bool) {
 	return res
 }
 
+// IsEqual compares the samples, chromosomes and blocks of two databases
+func (ib *IBrowser) IsEqual(other *IBrowser) (res bool) {
+	fmt.Println("Starting equality check")
+
+	res = true
+
+	res = res && (ib.NumSamples == other.NumSamples) && (len(ib.Samples) == len(other.Samples))
+
+	if !res {
+		fmt.Printf("IsEqual :: Failed ibrowser - NumSamples %d != %d\n", ib.NumSamples, other.NumSamples)
+		return res
+	}
+
+	for samplePos, sampleName := range ib.Samples {
+		res = res && (sampleName == other.Samples[samplePos])
+
+		if !res {
+			fmt.Printf("IsEqual :: Failed ibrowser - sample %d %s != %s\n", samplePos, sampleName, other.Samples[samplePos])
+			return res
+		}
+	}
+
+	res = res && ib.Block.IsEqual(other.Block)
+
+	if !res {
+		fmt.Printf("IsEqual :: Failed ibrowser - summary block\n")
+		return res
+	}
+
+	res = res && (len(ib.ChromosomesNames) == len(other.ChromosomesNames))
+
+	if !res {
+		fmt.Printf("IsEqual :: Failed ibrowser - number of chromosomes %d != %d\n", len(ib.ChromosomesNames), len(other.ChromosomesNames))
+		return res
+	}
+
+	for _, chromosomeName := range ib.ChromosomesNames {
+		otherChromosome, hasChromosome := other.GetChromosome(chromosomeName.Name)
+
+		res = res && hasChromosome && ib.Chromosomes[chromosomeName.Name].IsEqual(otherChromosome)
+
+		if !res {
+			fmt.Printf("IsEqual :: Failed ibrowser - chromosome %s\n", chromosomeName.Name)
+			return res
+		}
+	}
+
+	return res
+}
+
 func (ib *IBrowser) selfCheck() (res bool) {
 	res = true
 
@@ -194,10 +802,11 @@ func (ib *IBrowser) selfCheck() (res bool) {
 			return res
 		}
 
-		res = res && (ib.CounterBits == ib.Block.CounterBits)
+		// counters are promoted, never narrowed
+		res = res && (ib.CounterBits <= ib.Block.CounterBits)
 
 		if !res {
-			fmt.Printf("Failed ibrowser self check - CounterBits %d != %d\n", ib.CounterBits, ib.Block.CounterBits)
+			fmt.Printf("Failed ibrowser self check - CounterBits %d > %d\n", ib.CounterBits, ib.Block.CounterBits)
 			return res
 		}
 	}
@@ -439,74 +1048,214 @@ func (ib *IBrowser) GenFilename(outPrefix string, format string, compression str
 	return baseName, fileName
 }
 
+// DatabaseFiles returns the files of the database saved to outPrefix:
+// the database file, its checksum and completion marker and the matrix dumps
+func (ib *IBrowser) DatabaseFiles(outPrefix string, format string, compression string) (fileNames []string) {
+	_, fileName := ib.GenFilename(outPrefix, format, compression)
+
+	fileNames = append(fileNames, fileName, save.ChecksumFileName(fileName), save.CompleteFileName(fileName))
+	fileNames = append(fileNames, ib.GenMatrixDumpFileName(outPrefix, "", true, false))
+
+	for _, chromosomeName := range ib.ChromosomesNames {
+		chromosome := ib.Chromosomes[chromosomeName.Name]
+
+		fileNames = append(fileNames, ib.GenMatrixDumpFileName(outPrefix, chromosome.ChromosomeName, false, false))
+
+		for _, level := range chromosome.Levels {
+			fileNames = append(fileNames, ib.GenLevelMatrixDumpFileName(outPrefix, chromosome.ChromosomeName, level.Name))
+		}
+	}
+
+	return fileNames
+}
+
 //
 // Save
 //
-func (ib *IBrowser) Save(outPrefix string, format string, compression string) {
-	ib.saveLoad(true, outPrefix, format, compression, false)
+
+// Save writes the database to a temporary prefix and moves it into place once
+// complete, so an interrupted save never leaves a half written database.
+func (ib *IBrowser) Save(outPrefix string, format string, compression string) error {
+	tempPrefix, err := ib.newTempPrefix(outPrefix, compression)
+
+	if err != nil {
+		return err
+	}
+
+	// the stream ends with this save
+	defer func() { ib.stream = nil }()
+
+	if err := ib.saveLoad(true, tempPrefix, format, compression, false); err != nil {
+		ib.removeTempPrefix(outPrefix)
+		return err
+	}
+
+	_, fileName := ib.GenFilename(outPrefix, format, compression)
+
+	oldFiles := savedDatabaseFiles(outPrefix)
+
+	fmt.Println("moving database into place:", fileName)
+
+	return save.CommitTempPrefix(outPrefix, fileName, ib.DatabaseFiles(tempPrefix, format, compression), oldFiles)
+}
+
+// savedDatabaseFiles returns the files of the database already saved to
+// outPrefix, which a new save replaces
+func savedDatabaseFiles(outPrefix string) []string {
+	found, format, compression, _ := save.GuessPrefixFormat(outPrefix)
+
+	if !found {
+		return nil
+	}
+
+	saved := NewIBrowser(Parameters{})
+
+	if err := saved.Load(outPrefix, format, compression, true); err != nil {
+		fmt.Println("not removing the files of the database being replaced:", err)
+		return nil
+	}
+
+	return saved.DatabaseFiles(outPrefix, format, compression)
+}
+
+// SaveContainer writes the database to a temporary prefix and packs it into
+// a single container file
+func (ib *IBrowser) SaveContainer(outPrefix string, format string, compression string) error {
+	tempPrefix, err := ib.newTempPrefix(outPrefix, compression)
+
+	if err != nil {
+		return err
+	}
+
+	// the stream ends with this save
+	defer func() { ib.stream = nil }()
+
+	if err := ib.saveLoad(true, tempPrefix, format, compression, false); err != nil {
+		ib.removeTempPrefix(outPrefix)
+		return err
+	}
+
+	_, fileName := ib.GenFilename(tempPrefix, format, compression)
+
+	fmt.Println("packing database into:", save.ContainerFileName(outPrefix))
+
+	if err := save.CommitTempContainer(outPrefix, fileName, ib.DatabaseFiles(tempPrefix, format, compression)); err != nil {
+		ib.removeTempPrefix(outPrefix)
+		return err
+	}
+
+	return nil
 }
 
 //
 // Load
 //
 
-func (ib *IBrowser) EasyLoadPrefix(outPrefix string, soft bool) {
+// EasyLoadPrefix also loads containers, given either by prefix or file name
+func (ib *IBrowser) EasyLoadPrefix(outPrefix string, soft bool) error {
+	if save.IsContainer(outPrefix) {
+		return ib.LoadContainer(outPrefix, soft)
+	}
+
 	found, format, compression, _ := save.GuessPrefixFormat(outPrefix)
 
+	if !found && isContainerPrefix(outPrefix) {
+		return ib.LoadContainer(save.ContainerFileName(outPrefix), soft)
+	}
+
 	if !found {
-		fmt.Println("could not easy load prefix: ", outPrefix)
-		os.Exit(1)
+		return fmt.Errorf("%w: could not easy load prefix: %s", ErrNotFound, outPrefix)
 	}
 
-	ib.saveLoad(false, outPrefix, format, compression, soft)
+	return ib.saveLoad(false, outPrefix, format, compression, soft)
 }
 
-func (ib *IBrowser) EasyLoadFile(outFile string, soft bool) {
+func (ib *IBrowser) EasyLoadFile(outFile string, soft bool) error {
+	if save.IsContainer(outFile) {
+		return ib.LoadContainer(outFile, soft)
+	}
+
 	found, format, compression, outPrefix := save.GuessFormat(outFile)
 
 	if !found {
-		fmt.Println("could not easy load file:", outFile)
-		os.Exit(1)
+		return fmt.Errorf("%w: could not easy load file: %s", ErrNotFound, outFile)
+	}
+
+	return ib.saveLoad(false, outPrefix, format, compression, soft)
+}
+
+func (ib *IBrowser) Load(outPrefix string, format string, compression string, soft bool) error {
+	return ib.saveLoad(false, outPrefix, format, compression, soft)
+}
+
+// LoadContainer loads the database packed in a container file
+func (ib *IBrowser) LoadContainer(containerFileName string, soft bool) error {
+	container, err := save.OpenContainer(containerFileName)
+
+	if err != nil {
+		return err
+	}
+
+	defer container.Close()
+
+	outPrefix := save.ContainerPrefix(filepath.Base(containerFileName))
+	found, format, compression, _ := container.GuessPrefixFormat(outPrefix)
+
+	if !found {
+		return fmt.Errorf("%w: no database %s in %s", ErrNotFound, outPrefix, containerFileName)
 	}
 
-	ib.saveLoad(false, outPrefix, format, compression, soft)
+	ib.container = container
+	defer func() { ib.container = nil }()
+
+	return ib.saveLoad(false, outPrefix, format, compression, soft)
 }
 
-func (ib *IBrowser) Load(outPrefix string, format string, compression string, soft bool) {
-	ib.saveLoad(false, outPrefix, format, compression, soft)
+func isContainerPrefix(outPrefix string) bool {
+	_, err := os.Stat(save.ContainerFileName(outPrefix))
+	return err == nil
 }
 
 //
 // SaveLoad
 //
 
-func (ib *IBrowser) saveLoad(isSave bool, outPrefix string, format string, compression string, soft bool) {
+func (ib *IBrowser) saveLoad(isSave bool, outPrefix string, format string, compression string, soft bool) error {
 	baseName, _ := ib.GenFilename(outPrefix, format, compression)
 	saver := NewSaverCompressed(baseName, format, compression)
 
 	if isSave {
 		fmt.Println("saving global ibrowser status")
-		ib.dumper(isSave, outPrefix)
-		saver.Save(ib)
+		if err := ib.dumper(isSave, outPrefix, compression); err != nil {
+			return err
+		}
+		return saver.Save(ib)
 	} else {
 		fmt.Println("loading global ibrowser status")
-		saver.Load(ib)
+		if ib.container != nil {
+			saver.SetContainer(ib.container)
+		}
+		if err := saver.Load(ib); err != nil {
+			return err
+		}
 		sort.Sort(ib.ChromosomesNames)
 		if !soft {
-			ib.dumper(isSave, outPrefix)
+			return ib.dumper(isSave, outPrefix, compression)
 		}
 	}
 
+	return nil
+
 	// ib.saveLoadBlock(isSave, baseName, format, compression)
 	// ib.saveLoadChromosomes(isSave, baseName, format, compression)
 }
 
-func (ib *IBrowser) saveLoadBlock(isSave bool, outPrefix string, format string, compression string) {
+func (ib *IBrowser) saveLoadBlock(isSave bool, outPrefix string, format string, compression string) error {
 	newPrefix := outPrefix + "_block"
 
 	if isSave {
 		fmt.Println("saving global ibrowser block")
-		ib.Block.Save(newPrefix, format, compression)
+		return ib.Block.Save(newPrefix, format, compression)
 	} else {
 		fmt.Println("loading global ibrowser block")
 		ib.Block = NewIBBlock(
@@ -518,26 +1267,32 @@ func (ib *IBrowser) saveLoadBlock(isSave bool, outPrefix string, format string,
 			0,
 			0,
 		)
-		ib.Block.Load(newPrefix, format, compression)
+		return ib.Block.Load(newPrefix, format, compression)
 	}
 }
 
-func (ib *IBrowser) saveLoadChromosomes(isSave bool, outPrefix string, format string, compression string) {
+func (ib *IBrowser) saveLoadChromosomes(isSave bool, outPrefix string, format string, compression string) error {
 	for chromosomePos := 0; chromosomePos < len(ib.ChromosomesNames); chromosomePos++ {
 		chromosomeName := ib.ChromosomesNames[chromosomePos]
 
 		if isSave {
 			fmt.Println("saving chromosome        : ", chromosomeName)
 			chromosome := ib.Chromosomes[chromosomeName.Name]
-			chromosome.Save(outPrefix, format, compression)
+			if err := chromosome.Save(outPrefix, format, compression); err != nil {
+				return err
+			}
 
 		} else {
 			fmt.Println("loading chromosome       : ", chromosomeName)
-			ib.Chromosomes[chromosomeName.Name] = NewIBChromosome(chromosomeName.Name, chromosomeName.Pos, ib.BlockSize, ib.CounterBits, ib.NumSamples, ib.KeepEmptyBlock)
+			ib.Chromosomes[chromosomeName.Name] = NewIBChromosome(chromosomeName.Name, chromosomeName.Pos, ib.BlockMode, ib.BlockSize, ib.CounterBits, ib.NumSamples, ib.KeepEmptyBlock)
 			chromosome := ib.Chromosomes[chromosomeName.Name]
-			chromosome.Load(outPrefix, format, compression)
+			if err := chromosome.Load(outPrefix, format, compression); err != nil {
+				return err
+			}
 		}
 	}
+
+	return nil
 }
 
 //
@@ -556,7 +1311,62 @@ func (ib *IBrowser) GenMatrixDumpFileName(outPrefix string, chromosomeName strin
 	return
 }
 
-func (ib *IBrowser) dumper(isSave bool, outPrefix string) {
+func (ib *IBrowser) GenLevelMatrixDumpFileName(outPrefix string, chromosomeName string, levelName string) (filename string) {
+	filename = outPrefix + "_chromosomes_" + chromosomeName + "_" + levelName + ".bin"
+	return
+}
+
+// newDumper opens a matrix dump. Dumps written for another number of samples
+// are rejected on load instead of failing on the first mismatching register.
+func (ib *IBrowser) newDumper(fileName string, mode string, compression string) (*MultiArrayFile, error) {
+	var dumper *MultiArrayFile
+	var err error
+
+	if mode == "r" && ib.container != nil {
+		dumper, err = ib.container.OpenMultiArrayFile(fileName)
+	} else {
+		dumper, err = OpenMultiArrayFile(fileName, mode, compression)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == "w" {
+		dumper.SetDimension(ib.NumSamples, int64(ib.CounterBits))
+		dumper.SetMinCounterBits(ib.dumpCounterBits)
+		return dumper, nil
+	}
+
+	if layout := dumper.GetLayout(); layout.HasHeader() && layout.Header.Dimension != ib.NumSamples {
+		dumper.Close()
+		return nil, fmt.Errorf("%w: dump %s has %d samples instead of %d (%s)", ErrCorrupt, fileName, layout.Header.Dimension, ib.NumSamples, layout.Header)
+	}
+
+	return dumper, nil
+}
+
+// dumpBlocks writes or reads blocks from their own dump
+func (ib *IBrowser) dumpBlocks(fileName string, mode string, compression string, blocks []*IBBlock) error {
+	dumper, err := ib.newDumper(fileName, mode, compression)
+
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		if err := block.Dump(dumper, mode == "w"); err != nil {
+			dumper.Close()
+			return err
+		}
+	}
+
+	return dumper.Close()
+}
+
+// dumper saves or loads the matrices. When saving, compression other than
+// none writes the dumps in compressed chunks
+func (ib *IBrowser) dumper(isSave bool, outPrefix string, compression string) error {
 	mode := ""
 
 	if isSave {
@@ -568,15 +1378,25 @@ func (ib *IBrowser) dumper(isSave bool, outPrefix string) {
 	summaryFileName := ib.GenMatrixDumpFileName(outPrefix, "", true, false)
 	// summaryChromFileName := ib.GenMatrixDumpFileName(outPrefix, "", true, true)
 
-	dumperg := NewMultiArrayFile(summaryFileName, mode)
+	dumperg, err := ib.newDumper(summaryFileName, mode, compression)
 	// dumperc := NewMultiArrayFile(summaryChromFileName, mode)
 
-	ib.RegisterSize = dumperg.CalculateRegisterSize(ib.CounterBits, ib.Block.Matrix.Size)
+	if err != nil {
+		return err
+	}
 
 	defer dumperg.Close()
 	// defer dumperc.Close()
 
-	ib.Block.Dump(dumperg, isSave)
+	ib.RegisterSize, err = dumperg.CalculateRegisterSize(ib.CounterBits, ib.Block.Matrix.Size)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ib.Block.Dump(dumperg, isSave); err != nil {
+		return err
+	}
 	// ib.dumperMatrix(dumperg, isSave, ib.Block)
 
 	// fmt.Println("ib.ChromosomesNames", ib.ChromosomesNames)
@@ -586,19 +1406,42 @@ func (ib *IBrowser) dumper(isSave bool, outPrefix string) {
 		chromosome := ib.Chromosomes[chromosomeName.Name]
 
 		// ib.dumperMatrix(dumperg, isSave, chromosome.Block)
-		chromosome.Block.Dump(dumperg, isSave)
+		if err := chromosome.Block.Dump(dumperg, isSave); err != nil {
+			return err
+		}
 
-		// outPrefix+"_chromosomes_"+chromosomeName.Name+".bin"
-		chromosomeFileName := ib.GenMatrixDumpFileName(outPrefix, chromosomeName.Name, false, false)
-		dumperl := NewMultiArrayFile(chromosomeFileName, mode)
-		// dumperl.SetSerial(dumperc.GetSerial())
+		// released chromosomes were dumped to the stream prefix once read
+		if chromosome.IsReleased() {
+			if ib.stream == nil || ib.stream.tempPrefix != outPrefix {
+				return fmt.Errorf("chromosome %s was released before being saved to %s", chromosomeName.Name, outPrefix)
+			}
+			continue
+		}
 
-		for _, block := range chromosome.Blocks {
-			// ib.dumperMatrix(dumperc, isSave, block)
-			// ib.dumperMatrix(dumperl, isSave, block)
-			block.Dump(dumperl, isSave)
+		if err := ib.dumpChromosome(chromosome, mode, outPrefix, compression); err != nil {
+			return err
 		}
+	}
+
+	return dumperg.Close()
+}
 
-		dumperl.Close()
+// dumpChromosome saves or loads the blocks and levels of a chromosome
+func (ib *IBrowser) dumpChromosome(chromosome *IBChromosome, mode string, outPrefix string, compression string) error {
+	// outPrefix+"_chromosomes_"+chromosomeName.Name+".bin"
+	chromosomeFileName := ib.GenMatrixDumpFileName(outPrefix, chromosome.ChromosomeName, false, false)
+
+	if err := ib.dumpBlocks(chromosomeFileName, mode, compression, chromosome.Blocks); err != nil {
+		return err
+	}
+
+	for _, level := range chromosome.Levels {
+		levelFileName := ib.GenLevelMatrixDumpFileName(outPrefix, chromosome.ChromosomeName, level.Name)
+
+		if err := ib.dumpBlocks(levelFileName, mode, compression, level.Blocks); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
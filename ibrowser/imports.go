@@ -15,8 +15,16 @@ var SliceIndex = tools.SliceIndex
 // save
 var NewSaverCompressed = save.NewSaverCompressed
 var NewMultiArrayFile = save.NewMultiArrayFile
+var OpenMultiArrayFile = save.OpenMultiArrayFile
+var NewMmapArrayFile = save.NewMmapArrayFile
+
+var ErrCorrupt = save.ErrCorrupt
+var ErrVersion = save.ErrVersion
+var ErrNotFound = save.ErrNotFound
+var ErrInvalid = save.ErrInvalid
 
 type MultiArrayFile = save.MultiArrayFile
+type MmapArrayFile = save.MmapArrayFile
 
 // interfaces
 type Parameters = interfaces.Parameters
@@ -38,6 +46,9 @@ type VCFSamples = vcf.VCFSamples
 type VCFRegister = vcf.VCFRegister
 type VCFDistanceMatrix = vcf.DistanceMatrix
 
+var NewVCFDistanceMatrix = vcf.NewDistanceMatrix
+var CalculateDistanceExtension = vcf.CalculateDistanceExtension
+
 type NamePosPair struct {
 	Name string
 	Pos  int
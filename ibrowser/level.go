@@ -0,0 +1,240 @@
+package ibrowser
+
+import (
+	"fmt"
+)
+
+//
+//
+// LEVEL SECTION
+//
+//
+
+const BASE_LEVEL_NAME = "base"
+
+// IBLevel holds an extra resolution of a chromosome, e.g. sliding windows.
+// Its blocks are built by summing the base blocks, never by re-reading the VCF.
+type IBLevel struct {
+	Name             string
+	ChromosomeName   string
+	ChromosomeNumber int
+	WindowSize       uint64
+	StepSize         uint64
+	NumBlocks        uint64
+	NumSNPS          uint64
+	BlockNames       map[uint64]uint64
+	Blocks           []*IBBlock
+}
+
+func NewIBLevel(name string, chromosomeName string, chromosomeNumber int, windowSize uint64, stepSize uint64) *IBLevel {
+	fmt.Println("  NewIBLevel :: name: ", name,
+		" chromosomeName: ", chromosomeName,
+		" chromosomeNumber: ", chromosomeNumber,
+		" windowSize: ", windowSize,
+		" stepSize: ", stepSize,
+	)
+
+	ibl := IBLevel{
+		Name:             name,
+		ChromosomeName:   chromosomeName,
+		ChromosomeNumber: chromosomeNumber,
+		WindowSize:       windowSize,
+		StepSize:         stepSize,
+		NumBlocks:        0,
+		NumSNPS:          0,
+		BlockNames:       make(map[uint64]uint64, 100),
+		Blocks:           make([]*IBBlock, 0, 100),
+	}
+
+	return &ibl
+}
+
+func (ibl *IBLevel) String() string {
+	return fmt.Sprint("Level :: ",
+		" Name:             ", ibl.Name, "\n",
+		" ChromosomeName:   ", ibl.ChromosomeName, "\n",
+		" ChromosomeNumber: ", ibl.ChromosomeNumber, "\n",
+		" WindowSize:       ", ibl.WindowSize, "\n",
+		" StepSize:         ", ibl.StepSize, "\n",
+		" NumBlocks:        ", ibl.NumBlocks, "\n",
+		" NumSNPS:          ", ibl.NumSNPS, "\n",
+	)
+}
+
+// firstWindowOf returns the first window containing the source block blockNum
+func firstWindowOf(blockNum uint64, windowBlocks uint64, stepBlocks uint64) uint64 {
+	if blockNum+1 <= windowBlocks {
+		return 0
+	}
+
+	return (blockNum + 1 - windowBlocks + stepBlocks - 1) / stepBlocks
+}
+
+func (ibl *IBLevel) IsSliding() bool {
+	return ibl.StepSize != ibl.WindowSize
+}
+
+// Aggregate sums sourceBlocks, whose block numbers are in units of sourceSize,
+// into windows of WindowSize moving StepSize at a time. Window w covers the
+// source blocks [w*step, w*step+window) in source units.
+//
+// A merged block spans its own block number up to the last of MergedBlocks and
+// is summed whole, as its SNPs can not be told apart. It goes to the windows
+// covering its whole span. If none does, as when it crosses the border of two
+// windows, it goes to the windows covering its first block, so windows which
+// do not overlap keep counting each SNP once.
+func (ibl *IBLevel) Aggregate(sourceBlocks []*IBBlock, sourceSize uint64, counterBits int, numSamples uint64, keepEmptyBlock bool) error {
+	if ibl.WindowSize%sourceSize != 0 || ibl.StepSize%sourceSize != 0 {
+		return fmt.Errorf("%w: level %s window size %d and step size %d must be multiples of %d", ErrInvalid, ibl.Name, ibl.WindowSize, ibl.StepSize, sourceSize)
+	}
+
+	windowBlocks := ibl.WindowSize / sourceSize
+	stepBlocks := ibl.StepSize / sourceSize
+
+	lastBlockNum := uint64(0)
+	for _, block := range sourceBlocks {
+		lastBlockNum = Max64(lastBlockNum, block.BlockNumber)
+		for _, mergedNum := range block.MergedBlocks {
+			lastBlockNum = Max64(lastBlockNum, mergedNum)
+		}
+	}
+
+	numWindows := uint64(0)
+	if len(sourceBlocks) > 0 {
+		numWindows = (lastBlockNum / stepBlocks) + 1
+	}
+
+	windows := make([]*IBBlock, numWindows, numWindows)
+
+	for windowNum := uint64(0); windowNum < numWindows; windowNum++ {
+		windows[windowNum] = NewIBBlock(
+			ibl.ChromosomeName,
+			ibl.ChromosomeNumber,
+			ibl.WindowSize,
+			counterBits,
+			numSamples,
+			0,
+			windowNum,
+		)
+	}
+
+	for _, block := range sourceBlocks {
+		if block.Masked {
+			continue
+		}
+
+		blockNum := block.BlockNumber
+
+		lastBlock := blockNum
+		for _, mergedNum := range block.MergedBlocks {
+			lastBlock = Max64(lastBlock, mergedNum)
+		}
+
+		// first and last windows containing the whole block
+		firstWindow := firstWindowOf(lastBlock, windowBlocks, stepBlocks)
+		lastWindow := blockNum / stepBlocks
+
+		if firstWindow > lastWindow {
+			firstWindow = firstWindowOf(blockNum, windowBlocks, stepBlocks)
+		}
+
+		for windowNum := firstWindow; windowNum <= lastWindow; windowNum++ {
+			if err := windows[windowNum].Sum(block); err != nil {
+				return err
+			}
+		}
+	}
+
+	ibl.Blocks = make([]*IBBlock, 0, numWindows)
+	ibl.BlockNames = make(map[uint64]uint64, numWindows)
+	ibl.NumSNPS = 0
+
+	for _, window := range windows {
+		if window.NumSNPS == 0 && !keepEmptyBlock {
+			continue
+		}
+
+		blockPos := uint64(len(ibl.Blocks))
+
+		window.SetBlockPosition(blockPos)
+
+		ibl.Blocks = append(ibl.Blocks, window)
+		ibl.BlockNames[window.BlockNumber] = blockPos
+	}
+
+	for _, block := range sourceBlocks {
+		if !block.Masked {
+			ibl.NumSNPS += block.NumSNPS
+		}
+	}
+
+	ibl.NumBlocks = uint64(len(ibl.Blocks))
+
+	return nil
+}
+
+//
+// Getters
+//
+
+func (ibl *IBLevel) GetBlocks() ([]*IBBlock, bool) {
+	return ibl.Blocks, true
+}
+
+func (ibl *IBLevel) GetBlock(blockNum uint64) (*IBBlock, bool) {
+	if blockPos, ok := ibl.BlockNames[blockNum]; ok {
+		if blockPos >= uint64(len(ibl.Blocks)) {
+			return nil, false
+		}
+
+		return ibl.Blocks[blockPos], ok
+	} else {
+		return nil, ok
+	}
+}
+
+//
+// Check
+//
+
+func (ibl *IBLevel) Check() (res bool) {
+	res = true
+
+	res = res && (ibl.NumBlocks == uint64(len(ibl.Blocks)))
+
+	if !res {
+		fmt.Printf("Failed level %s %s check - NumBlocks: %d != %d\n", ibl.ChromosomeName, ibl.Name, ibl.NumBlocks, len(ibl.Blocks))
+		return res
+	}
+
+	for _, block := range ibl.Blocks {
+		res = res && block.Check()
+
+		if !res {
+			fmt.Printf("Failed level %s %s - block check - block pos %d number %d\n",
+				ibl.ChromosomeName,
+				ibl.Name,
+				block.BlockPosition,
+				block.BlockNumber,
+			)
+			return res
+		}
+	}
+
+	if !ibl.IsSliding() {
+		sumSNPS := uint64(0)
+
+		for _, block := range ibl.Blocks {
+			sumSNPS += block.NumSNPS
+		}
+
+		res = res && (ibl.NumSNPS == sumSNPS)
+
+		if !res {
+			fmt.Printf("Failed level %s %s check - NumSNPS: %d != %d\n", ibl.ChromosomeName, ibl.Name, ibl.NumSNPS, sumSNPS)
+			return res
+		}
+	}
+
+	return res
+}
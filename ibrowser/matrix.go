@@ -4,6 +4,7 @@ import (
 	"math"
 )
 
+type DistanceRow8 = []uint8
 type DistanceRow16 = []uint16
 type DistanceRow32 = []uint32
 type DistanceRow64 = []uint64
@@ -23,12 +24,12 @@ type DistanceMatrix1D_T interface {
 	Set(uint64, uint64, uint64)
 	Get(uint64, uint64, uint64) uint64
 	GenFilename(string, string, string) (string, string)
-	Save(string, string, string)
-	Load(string, string, string)
+	Save(string, string, string) error
+	Load(string, string, string) error
 	// Unexported Methods
 	ijToK(uint64, uint64) uint64
 	kToIJ(uint64) (uint64, uint64)
-	saveLoad(bool, string, string, string)
+	saveLoad(bool, string, string, string) error
 }
 
 //
@@ -23,6 +23,7 @@ type DistanceMatrix1Dg struct {
 	BlockNumber    uint64
 	Serial         int64
 	CounterBits    int
+	data8          DistanceRow8
 	data16         DistanceRow16
 	data32         DistanceRow32
 	data64         DistanceRow64
@@ -42,6 +43,10 @@ func (d *DistanceMatrix1Dg) String() string {
 	)
 }
 
+func NewDistanceMatrix1Dg8(chromosomeName string, blockSize uint64, dimension uint64, blockPosition uint64, blockNumber uint64) *DistanceMatrix1Dg {
+	return NewDistanceMatrix1Dg(chromosomeName, blockSize, 8, dimension, blockPosition, blockNumber)
+}
+
 func NewDistanceMatrix1Dg16(chromosomeName string, blockSize uint64, dimension uint64, blockPosition uint64, blockNumber uint64) *DistanceMatrix1Dg {
 	return NewDistanceMatrix1Dg(chromosomeName, blockSize, 16, dimension, blockPosition, blockNumber)
 }
@@ -77,26 +82,40 @@ func NewDistanceMatrix1Dg(chromosomeName string, blockSize uint64, numBits int,
 		Serial:         -1,
 	}
 
-	if d.CounterBits == 16 {
+	d.allocate()
+
+	d.Clean()
+
+	return &d
+}
+
+func (d *DistanceMatrix1Dg) allocate() {
+	size := d.Size
+
+	if d.CounterBits == 8 {
+		d.data8 = make(DistanceRow8, size, size)
+		d.data16 = make(DistanceRow16, 0, 0)
+		d.data32 = make(DistanceRow32, 0, 0)
+		d.data64 = make(DistanceRow64, 0, 0)
+	} else if d.CounterBits == 16 {
 		// d.Data = make(DistanceRow32, size, size)
+		d.data8 = make(DistanceRow8, 0, 0)
 		d.data16 = make(DistanceRow16, size, size)
 		d.data32 = make(DistanceRow32, 0, 0)
 		d.data64 = make(DistanceRow64, 0, 0)
 	} else if d.CounterBits == 32 {
 		// d.Data = make(DistanceRow32, size, size)
+		d.data8 = make(DistanceRow8, 0, 0)
 		d.data16 = make(DistanceRow16, 0, 0)
 		d.data32 = make(DistanceRow32, size, size)
 		d.data64 = make(DistanceRow64, 0, 0)
 	} else if d.CounterBits == 64 {
 		// d.Data = make(DistanceRow64, size, size)
+		d.data8 = make(DistanceRow8, 0, 0)
 		d.data16 = make(DistanceRow16, 0, 0)
 		d.data32 = make(DistanceRow32, 0, 0)
 		d.data64 = make(DistanceRow64, size, size)
 	}
-
-	d.Clean()
-
-	return &d
 }
 
 //
@@ -108,21 +127,48 @@ func (d *DistanceMatrix1Dg) GetTable() (*DistanceRow64, bool) {
 		return &d.data64, true
 	} else {
 		data := make(DistanceRow64, d.Size, d.Size)
-		if d.CounterBits == 16 {
+		if d.CounterBits == 8 {
+			for i := range (*d).data8 {
+				data[i] = uint64((*d).data8[i])
+			}
+			return &data, true
+		} else if d.CounterBits == 16 {
 			for i := range (*d).data16 {
 				data[i] = uint64((*d).data16[i])
 			}
-			return &d.data64, true
+			return &data, true
 		} else if d.CounterBits == 32 {
 			for i := range (*d).data32 {
 				data[i] = uint64((*d).data32[i])
 			}
-			return &d.data64, true
+			return &data, true
 		}
 	}
 	return nil, false
 }
 
+// SetTable replaces the counters by table, one value per pair, keeping the width unless it overflows.
+// Tables of another size return ErrCorrupt.
+func (d *DistanceMatrix1Dg) SetTable(table *DistanceRow64) error {
+	if uint64(len(*table)) != d.Size {
+		return fmt.Errorf("%w: matrix %s #%d has %d values instead of %d", ErrCorrupt, d.ChromosomeName, d.BlockNumber, len(*table), d.Size)
+	}
+
+	d.allocate()
+	d.setValues(*table)
+
+	return nil
+}
+
+func (d *DistanceMatrix1Dg) GetTable8() *DistanceRow8 {
+	if d.CounterBits != 8 {
+		fmt.Println("calling GetTable8 when numbits not 8")
+		os.Exit(1)
+	}
+
+	return &d.data8
+}
+
 func (d *DistanceMatrix1Dg) GetTable16() *DistanceRow16 {
 	if d.CounterBits != 16 {
 		fmt.Println("calling GetTable16 when numbits not 16")
@@ -162,12 +208,27 @@ func (d *DistanceMatrix1Dg) GetColumn(columNumber int) (*DistanceRow64, bool) {
 	return &dr, true
 }
 
+//
+// Release
+//
+
+// Release frees the counters of a matrix already saved. The matrix can not be
+// read or added to afterwards.
+func (d *DistanceMatrix1Dg) Release() {
+	d.data8 = nil
+	d.data16 = nil
+	d.data32 = nil
+	d.data64 = nil
+}
+
 //
 // Clean
 //
 
 func (d *DistanceMatrix1Dg) Clean() {
-	if d.CounterBits == 16 {
+	if d.CounterBits == 8 {
+		d.clean8()
+	} else if d.CounterBits == 16 {
 		d.clean16()
 	} else if d.CounterBits == 32 {
 		d.clean32()
@@ -176,6 +237,12 @@ func (d *DistanceMatrix1Dg) Clean() {
 	}
 }
 
+func (d *DistanceMatrix1Dg) clean8() {
+	for i := range (*d).data8 {
+		(*d).data8[i] = uint8(0)
+	}
+}
+
 func (d *DistanceMatrix1Dg) clean16() {
 	for i := range (*d).data16 {
 		(*d).data16[i] = uint16(0)
@@ -194,6 +261,82 @@ func (d *DistanceMatrix1Dg) clean64() {
 	}
 }
 
+//
+// Promote
+//
+
+// counterBitsFor returns the narrowest counter width able to hold val.
+// Above 8 bits the limits are the signed ones, as enforced by the binary dump.
+func counterBitsFor(val uint64) int {
+	if val <= uint64(math.MaxUint8) {
+		return 8
+	} else if val <= uint64(math.MaxInt16) {
+		return 16
+	} else if val <= uint64(math.MaxInt32) {
+		return 32
+	}
+	return 64
+}
+
+// Promote widens the counters to numBits keeping their values.
+// Matrices are never narrowed.
+func (d *DistanceMatrix1Dg) Promote(numBits int) {
+	if numBits <= d.CounterBits {
+		return
+	}
+
+	if numBits != 16 && numBits != 32 && numBits != 64 {
+		fmt.Println("invalid number of bits to promote to:", numBits)
+		os.Exit(1)
+	}
+
+	fmt.Println("    Promote :: Chromosome: ", d.ChromosomeName,
+		" Block Number: ", d.BlockNumber,
+		" Bits: ", d.CounterBits, " -> ", numBits,
+	)
+
+	values, _ := d.GetTable()
+
+	d.CounterBits = numBits
+
+	d.allocate()
+
+	d.setValues(*values)
+}
+
+// SetCounterBits changes the counters to numBits or, if their values do not
+// fit, to the narrowest width holding them
+func (d *DistanceMatrix1Dg) SetCounterBits(numBits int) {
+	values, _ := d.GetTable()
+	table := *values
+
+	maxValue := uint64(0)
+	for _, v := range table {
+		maxValue = Max64(maxValue, v)
+	}
+
+	if bits := counterBitsFor(maxValue); bits > numBits {
+		numBits = bits
+	}
+
+	if numBits == d.CounterBits {
+		return
+	}
+
+	d.CounterBits = numBits
+
+	d.allocate()
+
+	d.setValues(table)
+}
+
+// setValues copies values, one per pair, into the matrix promoting it if needed
+func (d *DistanceMatrix1Dg) setValues(values DistanceRow64) {
+	for k, v := range values {
+		d.setK(uint64(k), v)
+	}
+}
+
 //
 // Increment
 //
@@ -201,7 +344,13 @@ func (d *DistanceMatrix1Dg) clean64() {
 func (d *DistanceMatrix1Dg) Increment(p1 uint64, p2 uint64, val uint64) {
 	p := d.ijToK(p1, p2)
 
-	if d.CounterBits == 16 {
+	d.incrementK(p, val)
+}
+
+func (d *DistanceMatrix1Dg) incrementK(p uint64, val uint64) {
+	if d.CounterBits == 8 {
+		d.increment8(p, val)
+	} else if d.CounterBits == 16 {
 		d.increment16(p, val)
 	} else if d.CounterBits == 32 {
 		d.increment32(p, val)
@@ -210,30 +359,40 @@ func (d *DistanceMatrix1Dg) Increment(p1 uint64, p2 uint64, val uint64) {
 	}
 }
 
-func (d *DistanceMatrix1Dg) increment16(p uint64, val uint64) {
-	if val >= uint64(math.MaxUint16) {
-		fmt.Println("count 16 overflow")
-		os.Exit(1)
+func (d *DistanceMatrix1Dg) increment8(p uint64, val uint64) {
+	v := uint64((*d).data8[p])
+	r := v + val
+
+	if r > uint64(math.MaxUint8) {
+		d.Promote(counterBitsFor(r))
+		d.incrementK(p, val)
+		return
 	}
 
+	(*d).data8[p] = uint8(r)
+}
+
+func (d *DistanceMatrix1Dg) increment16(p uint64, val uint64) {
 	v := uint64((*d).data16[p])
 	r := v + val
 
+	if r > uint64(math.MaxInt16) {
+		d.Promote(counterBitsFor(r))
+		d.incrementK(p, val)
+		return
+	}
+
 	(*d).data16[p] = uint16(r)
 }
 
 func (d *DistanceMatrix1Dg) increment32(p uint64, val uint64) {
-	if val >= uint64(math.MaxUint32) {
-		fmt.Println("count 32 overflow")
-		os.Exit(1)
-	}
-
 	v := uint64((*d).data32[p])
 	r := v + val
 
-	if r >= uint64(math.MaxUint32) {
-		fmt.Println("count 32 overflow")
-		os.Exit(1)
+	if r > uint64(math.MaxInt32) {
+		d.Promote(64)
+		d.incrementK(p, val)
+		return
 	}
 
 	(*d).data32[p] = uint32(r)
@@ -250,7 +409,13 @@ func (d *DistanceMatrix1Dg) increment64(p uint64, val uint64) {
 func (d *DistanceMatrix1Dg) Set(p1 uint64, p2 uint64, val uint64) {
 	p := d.ijToK(p1, p2)
 
-	if d.CounterBits == 16 {
+	d.setK(p, val)
+}
+
+func (d *DistanceMatrix1Dg) setK(p uint64, val uint64) {
+	if d.CounterBits == 8 {
+		d.set8(p, val)
+	} else if d.CounterBits == 16 {
 		d.set16(p, val)
 	} else if d.CounterBits == 32 {
 		d.set32(p, val)
@@ -259,19 +424,31 @@ func (d *DistanceMatrix1Dg) Set(p1 uint64, p2 uint64, val uint64) {
 	}
 }
 
+func (d *DistanceMatrix1Dg) set8(p uint64, val uint64) {
+	if val > uint64(math.MaxUint8) {
+		d.Promote(counterBitsFor(val))
+		d.setK(p, val)
+		return
+	}
+
+	(*d).data8[p] = uint8(val)
+}
+
 func (d *DistanceMatrix1Dg) set16(p uint64, val uint64) {
-	if val >= uint64(math.MaxUint16) {
-		fmt.Println("count 16 overflow")
-		os.Exit(1)
+	if val > uint64(math.MaxInt16) {
+		d.Promote(counterBitsFor(val))
+		d.setK(p, val)
+		return
 	}
 
 	(*d).data16[p] = uint16(val)
 }
 
 func (d *DistanceMatrix1Dg) set32(p uint64, val uint64) {
-	if val >= uint64(math.MaxUint32) {
-		fmt.Println("count 32 overflow")
-		os.Exit(1)
+	if val > uint64(math.MaxInt32) {
+		d.Promote(64)
+		d.setK(p, val)
+		return
 	}
 
 	(*d).data32[p] = uint32(val)
@@ -281,6 +458,54 @@ func (d *DistanceMatrix1Dg) set64(p uint64, val uint64) {
 	(*d).data64[p] = val
 }
 
+//
+// Resize
+//
+
+// Resize enlarges the matrix to a new dimension keeping the existing pair counters
+func (d *DistanceMatrix1Dg) Resize(dimension uint64) {
+	if dimension < d.Dimension {
+		fmt.Println("can not shrink matrix from", d.Dimension, "to", dimension)
+		os.Exit(1)
+	}
+
+	if dimension == d.Dimension {
+		return
+	}
+
+	old := *d
+
+	d.Dimension = dimension
+	d.Size = dimension * (dimension - 1) / 2
+
+	if d.CounterBits == 8 {
+		d.data8 = make(DistanceRow8, d.Size, d.Size)
+	} else if d.CounterBits == 16 {
+		d.data16 = make(DistanceRow16, d.Size, d.Size)
+	} else if d.CounterBits == 32 {
+		d.data32 = make(DistanceRow32, d.Size, d.Size)
+	} else if d.CounterBits == 64 {
+		d.data64 = make(DistanceRow64, d.Size, d.Size)
+	}
+
+	// each row of the upper triangle is contiguous in both layouts
+	for i := uint64(0); i+1 < old.Dimension; i++ {
+		ko := ijToK(old.Dimension, i, i+1)
+		kn := ijToK(dimension, i, i+1)
+		l := old.Dimension - i - 1
+
+		if d.CounterBits == 8 {
+			copy(d.data8[kn:kn+l], old.data8[ko:ko+l])
+		} else if d.CounterBits == 16 {
+			copy(d.data16[kn:kn+l], old.data16[ko:ko+l])
+		} else if d.CounterBits == 32 {
+			copy(d.data32[kn:kn+l], old.data32[ko:ko+l])
+		} else if d.CounterBits == 64 {
+			copy(d.data64[kn:kn+l], old.data64[ko:ko+l])
+		}
+	}
+}
+
 //
 // Add
 //
@@ -302,7 +527,16 @@ func (d *DistanceMatrix1Dg) Add(e *DistanceMatrix1Dg) {
 }
 
 func (d *DistanceMatrix1Dg) add(e *DistanceMatrix1Dg) {
-	if d.CounterBits == 16 {
+	d.Promote(e.CounterBits)
+
+	if d.CounterBits != e.CounterBits {
+		d.addFrom(e, 0)
+		return
+	}
+
+	if d.CounterBits == 8 {
+		d.add8(e)
+	} else if d.CounterBits == 16 {
 		d.add16(e)
 	} else if d.CounterBits == 32 {
 		d.add32(e)
@@ -311,27 +545,42 @@ func (d *DistanceMatrix1Dg) add(e *DistanceMatrix1Dg) {
 	}
 }
 
+func (d *DistanceMatrix1Dg) add8(e *DistanceMatrix1Dg) {
+	mi := uint64(math.MaxUint8)
+	for i := range (*d).data8 {
+		r := uint64((*d).data8[i]) + uint64((*e).data8[i])
+		if r > mi {
+			d.Promote(counterBitsFor(r))
+			d.addFrom(e, uint64(i))
+			return
+		}
+		(*d).data8[i] = uint8(r)
+	}
+}
+
 func (d *DistanceMatrix1Dg) add16(e *DistanceMatrix1Dg) {
 	mi := uint64(math.MaxInt16)
 	for i := range (*d).data16 {
-		if uint64((*d).data16[i])+uint64((*e).data16[i]) >= mi {
-			fmt.Println("counter 16 overflow")
-			os.Exit(1)
+		r := uint64((*d).data16[i]) + uint64((*e).data16[i])
+		if r > mi {
+			d.Promote(counterBitsFor(r))
+			d.addFrom(e, uint64(i))
+			return
 		}
-		(*d).data16[i] += (*e).data16[i]
+		(*d).data16[i] = uint16(r)
 	}
 }
 
 func (d *DistanceMatrix1Dg) add32(e *DistanceMatrix1Dg) {
 	mi := uint64(math.MaxInt32)
 	for i := range (*d).data32 {
-		vdi := uint64((*d).data32[i])
-		vei := uint64((*e).data32[i])
-		if (vdi + vei) >= mi {
-			fmt.Println("counter 32 overflow", vdi, vei, mi)
-			os.Exit(1)
+		r := uint64((*d).data32[i]) + uint64((*e).data32[i])
+		if r > mi {
+			d.Promote(64)
+			d.addFrom(e, uint64(i))
+			return
 		}
-		(*d).data32[i] += (*e).data32[i]
+		(*d).data32[i] = uint32(r)
 	}
 }
 
@@ -341,6 +590,14 @@ func (d *DistanceMatrix1Dg) add64(e *DistanceMatrix1Dg) {
 	}
 }
 
+// addFrom adds the counters of e from position start onwards one by one.
+// Used when the widths differ or after a promotion in the middle of an add.
+func (d *DistanceMatrix1Dg) addFrom(e *DistanceMatrix1Dg, start uint64) {
+	for k := start; k < d.Size; k++ {
+		d.incrementK(k, e.getK(k))
+	}
+}
+
 //
 // IsEqual
 //
@@ -369,13 +626,6 @@ func (d *DistanceMatrix1Dg) IsEqual(e *DistanceMatrix1Dg) (res bool) {
 		return res
 	}
 
-	res = res && (d.CounterBits == e.CounterBits)
-
-	if !res {
-		fmt.Printf("IsEqual :: Failed matrix %s - #%d check - CounterBits %d != %d\n", d.ChromosomeName, d.BlockNumber, d.CounterBits, e.CounterBits)
-		return res
-	}
-
 	res = res && (d.Size == e.Size)
 
 	if !res {
@@ -383,18 +633,50 @@ func (d *DistanceMatrix1Dg) IsEqual(e *DistanceMatrix1Dg) (res bool) {
 		return res
 	}
 
-	if d.CounterBits == 16 {
-		d.isEqual16(e)
+	if d.CounterBits != e.CounterBits {
+		res = d.isEqualMixed(e)
+	} else if d.CounterBits == 8 {
+		res = d.isEqual8(e)
+	} else if d.CounterBits == 16 {
+		res = d.isEqual16(e)
 	} else if d.CounterBits == 32 {
-		d.isEqual32(e)
+		res = d.isEqual32(e)
 	} else if d.CounterBits == 64 {
-		d.isEqual64(e)
+		res = d.isEqual64(e)
 	}
 
 	return res
 
 }
 
+func (d *DistanceMatrix1Dg) isEqual8(e *DistanceMatrix1Dg) (res bool) {
+	res = true
+
+	res = res && (d.Size == uint64(len(d.data8)))
+
+	if !res {
+		fmt.Printf("IsEqual :: Failed matrix %s - #%d check 8 - D Size %d != Len %d\n", d.ChromosomeName, d.BlockNumber, d.Size, uint64(len(d.data8)))
+		return res
+	}
+
+	res = res && (e.Size == uint64(len(e.data8)))
+
+	if !res {
+		fmt.Printf("IsEqual :: Failed matrix %s - #%d check 8 - E Size %d != Len %d\n", d.ChromosomeName, d.BlockNumber, e.Size, uint64(len(e.data8)))
+		return res
+	}
+
+	for i := range (*d).data8 {
+		res = res && ((*d).data8[i] == (*e).data8[i])
+
+		if !res {
+			fmt.Printf("IsEqual :: Failed matrix %s - #%d check 8 - Position %d : %d != %d\n", d.ChromosomeName, d.BlockNumber, i, (*d).data8[i], (*e).data8[i])
+		}
+	}
+
+	return res
+}
+
 func (d *DistanceMatrix1Dg) isEqual16(e *DistanceMatrix1Dg) (res bool) {
 	res = true
 
@@ -479,16 +761,46 @@ func (d *DistanceMatrix1Dg) isEqual64(e *DistanceMatrix1Dg) (res bool) {
 	return res
 }
 
+// isEqualMixed compares matrices which were promoted to different widths
+func (d *DistanceMatrix1Dg) isEqualMixed(e *DistanceMatrix1Dg) (res bool) {
+	res = true
+
+	for k := uint64(0); k < d.Size; k++ {
+		res = res && (d.getK(k) == e.getK(k))
+
+		if !res {
+			fmt.Printf("IsEqual :: Failed matrix %s - #%d check %d/%d - Position %d : %d != %d\n", d.ChromosomeName, d.BlockNumber, d.CounterBits, e.CounterBits, k, d.getK(k), e.getK(k))
+			return res
+		}
+	}
+
+	return res
+}
+
 //
 // Get
 //
 
+func (d *DistanceMatrix1Dg) getK(p uint64) uint64 {
+	if d.CounterBits == 8 {
+		return uint64((*d).data8[p])
+	} else if d.CounterBits == 16 {
+		return uint64((*d).data16[p])
+	} else if d.CounterBits == 32 {
+		return uint64((*d).data32[p])
+	} else if d.CounterBits == 64 {
+		return (*d).data64[p]
+	}
+
+	return 0
+}
+
 func (d *DistanceMatrix1Dg) GetPos(p1 uint64, p2 uint64) uint64 {
 	p := d.ijToK(p1, p2)
 
-	fmt.Printf("GetPos :: p1 %d p2 %d p %d len data16 %d data32 %d data64 %d", p1, p2, p, len((*d).data16), len((*d).data32), len((*d).data64))
-
-	if d.CounterBits == 16 {
+	if d.CounterBits == 8 {
+		return uint64((*d).data8[p])
+	} else if d.CounterBits == 16 {
 		return uint64((*d).data16[p])
 	} else if d.CounterBits == 32 {
 		return uint64((*d).data32[p])
@@ -533,24 +845,24 @@ func (d *DistanceMatrix1Dg) Check() (res bool) {
 //
 // Save and Load
 //
-func (d *DistanceMatrix1Dg) Save(outPrefix string, format string, compression string) {
-	d.saveLoad(true, outPrefix, format, compression)
+func (d *DistanceMatrix1Dg) Save(outPrefix string, format string, compression string) error {
+	return d.saveLoad(true, outPrefix, format, compression)
 }
 
-func (d *DistanceMatrix1Dg) Load(outPrefix string, format string, compression string) {
-	d.saveLoad(false, outPrefix, format, compression)
+func (d *DistanceMatrix1Dg) Load(outPrefix string, format string, compression string) error {
+	return d.saveLoad(false, outPrefix, format, compression)
 }
 
-func (d *DistanceMatrix1Dg) saveLoad(isSave bool, outPrefix string, format string, compression string) {
+func (d *DistanceMatrix1Dg) saveLoad(isSave bool, outPrefix string, format string, compression string) error {
 	baseName, _ := d.GenFilename(outPrefix, format, compression)
 	saver := save.NewSaverCompressed(baseName, format, compression)
 
 	if isSave {
 		fmt.Printf("saving matrix            :  %-70s block num: %d block pos: %d\n", outPrefix, d.BlockNumber, d.BlockPosition)
-		saver.Save(d)
+		return saver.Save(d)
 	} else {
 		fmt.Printf("loading matrix           :  %-70s block num: %d block pos: %d\n", outPrefix, d.BlockNumber, d.BlockPosition)
-		saver.Load(d)
+		return saver.Load(d)
 	}
 }
 
@@ -598,28 +910,70 @@ func (d *DistanceMatrix1Dg) saveLoad(isSave bool, outPrefix string, format strin
 //         os.Exit(1)
 //     }
 //     map_array := (*[n]int)(unsafe.Pointer(&mmap[0]))
+
+// Dump writes the matrix with the smallest encoding for its values:
+// the narrowest counter width able to hold them or delta varints.
+// A minimum counter width set in the dumper forces fixed width registers
+// of at least that width.
 func (d *DistanceMatrix1Dg) Dump(dumper *MultiArrayFile) (serial int64) {
 	serial = int64(0)
 
-	if d.CounterBits == 16 {
-		serial = dumper.Write16(&d.data16)
-	} else if d.CounterBits == 32 {
-		serial = dumper.Write32(&d.data32)
-	} else if d.CounterBits == 64 {
-		serial = dumper.Write64(&d.data64)
+	values, _ := d.GetTable()
+
+	maxValue := uint64(0)
+	for _, v := range *values {
+		maxValue = Max64(maxValue, v)
+	}
+
+	numBits := counterBitsFor(maxValue)
+	minBits := dumper.GetMinCounterBits()
+
+	if minBits > numBits {
+		numBits = minBits
+	}
+
+	fixedSize, err := dumper.CalculateRegisterSize(numBits, d.Size)
+
+	if err != nil {
+		return dumper.WriteVarint(values)
+	}
+
+	if minBits == 0 && dumper.CalculateVarintRegisterSize(values) < fixedSize {
+		return dumper.WriteVarint(values)
+	}
+
+	e := d
+
+	if numBits != d.CounterBits {
+		e = &DistanceMatrix1Dg{Size: d.Size, CounterBits: numBits}
+		e.allocate()
+		e.setValues(*values)
+	}
+
+	if e.CounterBits == 8 {
+		serial = dumper.Write8(&e.data8)
+	} else if e.CounterBits == 16 {
+		serial = dumper.Write16(&e.data16)
+	} else if e.CounterBits == 32 {
+		serial = dumper.Write32(&e.data32)
+	} else if e.CounterBits == 64 {
+		serial = dumper.Write64(&e.data64)
 	}
 
 	return
 }
 
-func (d *DistanceMatrix1Dg) UnDump(dumper *MultiArrayFile) (hasData bool, serial int64) {
-	if d.CounterBits == 16 {
-		hasData, serial = dumper.Read16(&d.data16)
-	} else if d.CounterBits == 32 {
-		hasData, serial = dumper.Read32(&d.data32)
-	} else if d.CounterBits == 64 {
-		hasData, serial = dumper.Read64(&d.data64)
+// UnDump reads a register of any encoding into the matrix width
+func (d *DistanceMatrix1Dg) UnDump(dumper *MultiArrayFile) (hasData bool, serial int64, err error) {
+	values := make(DistanceRow64, 0, 0)
+
+	hasData, serial = dumper.Read(&values)
+
+	if !hasData {
+		return
 	}
 
+	err = d.SetTable(&values)
+
 	return
 }
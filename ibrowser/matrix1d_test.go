@@ -0,0 +1,233 @@
+package ibrowser
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/save"
+)
+
+//
+// Helpers
+//
+
+const testDimension = uint64(5)
+
+func newTestMatrix(counterBits int) *DistanceMatrix1Dg {
+	return NewDistanceMatrix1Dg("ch01", 1000, counterBits, testDimension, 0, 0)
+}
+
+//
+// Promote
+//
+
+var promoteTests = []struct {
+	name        string
+	counterBits int
+	value       uint64
+	increment   uint64
+	wantBits    int
+}{
+	{"8 bits at MaxUint8", 8, math.MaxUint8, 0, 8},
+	{"8 bits over MaxUint8", 8, math.MaxUint8, 1, 16},
+	{"8 bits over MaxInt16", 8, math.MaxUint8, math.MaxInt16, 32},
+	{"8 bits over MaxInt32", 8, math.MaxUint8, math.MaxInt32, 64},
+	{"16 bits at MaxInt16", 16, math.MaxInt16, 0, 16},
+	{"16 bits over MaxInt16", 16, math.MaxInt16, 1, 32},
+	{"16 bits over MaxInt32", 16, math.MaxInt16, math.MaxInt32, 64},
+	{"32 bits at MaxInt32", 32, math.MaxInt32, 0, 32},
+	{"32 bits over MaxInt32", 32, math.MaxInt32, 1, 64},
+	{"64 bits over MaxUint32", 64, math.MaxUint32, 1, 64},
+}
+
+// checkPromoted checks the width of d and that the promotion kept the other counters
+func checkPromoted(t *testing.T, d *DistanceMatrix1Dg, wantBits int, want uint64) {
+	t.Helper()
+
+	if d.CounterBits != wantBits {
+		t.Errorf("counter bits %d, want %d", d.CounterBits, wantBits)
+	}
+
+	if got := d.GetPos(0, 1); got != want {
+		t.Errorf("promoted counter %d, want %d", got, want)
+	}
+
+	if got := d.GetPos(2, 4); got != 7 {
+		t.Errorf("other counter %d, want 7", got)
+	}
+
+	if got := d.GetPos(1, 3); got != 0 {
+		t.Errorf("empty counter %d, want 0", got)
+	}
+}
+
+func TestPromoteIncrement(t *testing.T) {
+	for _, tt := range promoteTests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestMatrix(tt.counterBits)
+			d.Increment(2, 4, 7)
+			d.Increment(0, 1, tt.value)
+			d.Increment(0, 1, tt.increment)
+
+			checkPromoted(t, d, tt.wantBits, tt.value+tt.increment)
+		})
+	}
+}
+
+func TestPromoteSet(t *testing.T) {
+	for _, tt := range promoteTests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestMatrix(tt.counterBits)
+			d.Set(2, 4, 7)
+			d.Set(0, 1, tt.value+tt.increment)
+
+			checkPromoted(t, d, tt.wantBits, tt.value+tt.increment)
+		})
+	}
+}
+
+func TestPromoteAdd(t *testing.T) {
+	for _, tt := range promoteTests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestMatrix(tt.counterBits)
+			d.Set(2, 4, 7)
+			d.Set(0, 1, tt.value)
+
+			e := newTestMatrix(tt.counterBits)
+			e.Set(0, 1, tt.increment)
+
+			d.Add(e)
+
+			checkPromoted(t, d, tt.wantBits, tt.value+tt.increment)
+		})
+	}
+}
+
+func TestPromoteNeverNarrows(t *testing.T) {
+	d := newTestMatrix(32)
+	d.Set(0, 1, 3)
+	d.Promote(16)
+
+	if d.CounterBits != 32 {
+		t.Errorf("counter bits %d, want 32", d.CounterBits)
+	}
+
+	if got := d.GetPos(0, 1); got != 3 {
+		t.Errorf("counter %d, want 3", got)
+	}
+}
+
+//
+// Dump and UnDump
+//
+
+const testDumpDimension = uint64(40)
+
+// newTestDumpMatrix returns a matrix holding values of counter f
+func newTestDumpMatrix(f func(k uint64) uint64) *DistanceMatrix1Dg {
+	d := NewDistanceMatrix1Dg("ch01", 1000, 8, testDumpDimension, 0, 0)
+
+	for k := uint64(0); k < d.Size; k++ {
+		d.setK(k, f(k))
+	}
+
+	return d
+}
+
+// testSpread returns values spread below maxValue, so their deltas do not fit small varints
+func testSpread(maxValue uint64) func(k uint64) uint64 {
+	return func(k uint64) uint64 {
+		return (k*2654435761 + 40503) % maxValue
+	}
+}
+
+var dumpTests = []struct {
+	name         string
+	values       func(k uint64) uint64
+	registerBits int64
+	counterBits  int
+}{
+	{"8 bits", testSpread(math.MaxUint8), 8, 8},
+	{"16 bits", testSpread(math.MaxInt16), 16, 16},
+	{"32 bits", testSpread(math.MaxInt32), 32, 32},
+	{"64 bits", func(k uint64) uint64 { return (k*0x9E3779B97F4A7C15)>>2 | 1<<40 }, 64, 64},
+	{"varint ascending", func(k uint64) uint64 { return k * 1000 }, save.VARINT_COUNTER_BITS, 32},
+	{"varint sparse", func(k uint64) uint64 {
+		if k == 3 {
+			return math.MaxInt32 + 1
+		}
+		return 0
+	}, save.VARINT_COUNTER_BITS, 64},
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	for _, compressor := range []string{"none", "gzip"} {
+		t.Run(compressor, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "matrix.bin")
+
+			writer, err := OpenMultiArrayFile(fileName, "w", compressor)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writer.SetDimension(testDumpDimension, 8)
+
+			matrices := make([]*DistanceMatrix1Dg, len(dumpTests))
+			serials := make([]int64, len(dumpTests))
+
+			for i, tt := range dumpTests {
+				matrices[i] = newTestDumpMatrix(tt.values)
+				serials[i] = matrices[i].Dump(writer)
+
+				if _, _, registerBits := writer.GetLastRegister(); registerBits != tt.registerBits {
+					t.Errorf("%s: written with %d bits, want %d", tt.name, registerBits, tt.registerBits)
+				}
+			}
+
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, err := NewMultiArrayFile(fileName, "r")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+
+			for i, tt := range dumpTests {
+				d := NewDistanceMatrix1Dg("ch01", 1000, 8, testDumpDimension, 0, 0)
+
+				hasData, serial, err := d.UnDump(reader)
+
+				if err != nil || !hasData {
+					t.Fatalf("%s: hasData %v error %v", tt.name, hasData, err)
+				}
+
+				if serial != serials[i] {
+					t.Errorf("%s: serial %d, want %d", tt.name, serial, serials[i])
+				}
+
+				if d.CounterBits != tt.counterBits {
+					t.Errorf("%s: read into %d bits, want %d", tt.name, d.CounterBits, tt.counterBits)
+				}
+
+				if !d.IsEqual(matrices[i]) {
+					t.Errorf("%s: read matrix differs from the written one", tt.name)
+				}
+			}
+
+			d := NewDistanceMatrix1Dg("ch01", 1000, 8, testDumpDimension, 0, 0)
+
+			if hasData, _, err := d.UnDump(reader); hasData || err != nil {
+				t.Errorf("read past the last register: hasData %v error %v", hasData, err)
+			}
+
+			if err := reader.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
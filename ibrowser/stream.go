@@ -0,0 +1,344 @@
+package ibrowser
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/save"
+)
+
+//
+// Stream
+//
+
+// A streamed database saves each chromosome to the temporary prefix as soon as
+// it is read and releases its matrices, so only the summary blocks of the
+// genome are kept in memory. Save then writes the remaining files to the same
+// prefix.
+//
+// Each saved chromosome is followed by a checkpoint, so an interrupted save can
+// be resumed reading only the chromosomes without one. Checkpoints are removed
+// with the temporary prefix once the database is in place.
+
+const CHECKPOINT_INFIX = "_checkpoint_"
+
+type ibStream struct {
+	outPrefix    string
+	tempPrefix   string
+	format       string
+	compression  string
+	check        bool
+	levels       []*IBLevel
+	needsRebuild bool
+	registers    map[string]uint64
+}
+
+// ibCheckpoint holds a saved chromosome and what the genome needs from it.
+// The matrix of its summary block is dumped next to it.
+type ibCheckpoint struct {
+	Parameters   Parameters
+	Samples      VCFSamples
+	NumRegisters uint64
+	PassedCheck  bool
+	Chromosome   *IBChromosome
+}
+
+// StreamTo starts saving chromosomes to the temporary prefix of outPrefix as
+// they are finished. Levels have to be added before reading. resume keeps the
+// checkpoints of an interrupted save, to be loaded by LoadCheckpoints.
+func (ib *IBrowser) StreamTo(outPrefix string, format string, compression string, check bool, resume bool) error {
+	tempPrefix := save.TempPrefix(outPrefix)
+
+	if resume && save.HasTempPrefix(outPrefix) {
+		fmt.Println("resuming save of", outPrefix)
+
+	} else {
+		if resume {
+			fmt.Println("no interrupted save of", outPrefix, "to resume")
+		}
+
+		if _, err := save.NewTempPrefix(outPrefix); err != nil {
+			return err
+		}
+	}
+
+	ib.stream = &ibStream{
+		outPrefix:   outPrefix,
+		tempPrefix:  tempPrefix,
+		format:      format,
+		compression: compression,
+		check:       check,
+		registers:   make(map[string]uint64),
+	}
+
+	return nil
+}
+
+func (ib *IBrowser) IsStreaming() bool {
+	return ib.stream != nil
+}
+
+// FinishChromosome applies the snp limits and levels to a chromosome which was
+// completely read, checks it, saves it and releases its matrices.
+// It is the chromosome callback of the VCF reader.
+func (ib *IBrowser) FinishChromosome(chromosomeName string) error {
+	if ib.stream == nil {
+		return nil
+	}
+
+	chromosome, hasChromosome := ib.GetChromosome(chromosomeName)
+
+	if !hasChromosome {
+		return nil
+	}
+
+	if chromosome.IsReleased() {
+		return fmt.Errorf("%w: chromosome %s was already saved. the VCF file must be sorted by chromosome to be streamed", ErrInvalid, chromosomeName)
+	}
+
+	fmt.Println("saving chromosome", chromosomeName)
+
+	needsRebuild, err := chromosome.ApplySnpLimits()
+
+	if err != nil {
+		return err
+	}
+
+	if needsRebuild {
+		if err := chromosome.RebuildSummary(); err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		ib.stream.needsRebuild = true
+		mutex.Unlock()
+	}
+
+	for _, level := range ib.stream.levels {
+		if _, err := chromosome.AddLevel(level.Name, level.WindowSize, level.StepSize); err != nil {
+			return err
+		}
+	}
+
+	passedCheck := true
+
+	if ib.stream.check {
+		passedCheck = chromosome.Check()
+
+		if !passedCheck {
+			fmt.Println("Failed tests for chromosome", chromosomeName)
+		}
+	}
+
+	if err := ib.dumpChromosome(chromosome, "w", ib.stream.tempPrefix, ib.stream.compression); err != nil {
+		return err
+	}
+
+	if err := ib.saveCheckpoint(chromosome, passedCheck); err != nil {
+		return err
+	}
+
+	chromosome.Release(passedCheck)
+
+	return nil
+}
+
+// FinishStream saves the chromosomes not finished while reading and updates
+// the whole genome summary
+func (ib *IBrowser) FinishStream() error {
+	if ib.stream == nil {
+		return fmt.Errorf("database is not being streamed")
+	}
+
+	ib.NumBlocks = 0
+
+	for _, chromosome := range ib.GetChromosomes() {
+		if !chromosome.IsReleased() {
+			if err := ib.FinishChromosome(chromosome.ChromosomeName); err != nil {
+				return err
+			}
+		}
+
+		ib.NumBlocks += chromosome.NumBlocks
+	}
+
+	if ib.stream.needsRebuild {
+		fmt.Println("rebuilding global ibrowser summary")
+		return ib.sumChromosomes()
+	}
+
+	return nil
+}
+
+// removeTempPrefix removes the temporary prefix of a failed save. Streamed
+// saves keep it to be resumed.
+func (ib *IBrowser) removeTempPrefix(outPrefix string) {
+	if ib.stream != nil {
+		fmt.Println("keeping", save.TempDir(outPrefix), "to resume the save")
+		return
+	}
+
+	save.RemoveTempPrefix(outPrefix)
+}
+
+// newTempPrefix returns the prefix the stream is being saved to or a new
+// temporary prefix for outPrefix
+func (ib *IBrowser) newTempPrefix(outPrefix string, compression string) (string, error) {
+	if ib.stream == nil {
+		return save.NewTempPrefix(outPrefix)
+	}
+
+	if ib.stream.outPrefix != outPrefix || ib.stream.compression != compression {
+		return "", fmt.Errorf("database streamed to %s with compression %s can not be saved to %s with compression %s",
+			ib.stream.outPrefix, ib.stream.compression, outPrefix, compression)
+	}
+
+	return ib.stream.tempPrefix, nil
+}
+
+//
+// Checkpoint
+//
+
+func (ib *IBrowser) checkpointSaver(chromosomeName string) *save.Saver {
+	return NewSaverCompressed(ib.stream.tempPrefix+CHECKPOINT_INFIX+chromosomeName, ib.stream.format, ib.stream.compression)
+}
+
+func (ib *IBrowser) checkpointDumpFileName(chromosomeName string) string {
+	return ib.stream.tempPrefix + CHECKPOINT_INFIX + chromosomeName + ".bin"
+}
+
+// saveCheckpoint is called once the dumps of a chromosome were written. The
+// checkpoint file is written last, so only complete checkpoints are loaded.
+func (ib *IBrowser) saveCheckpoint(chromosome *IBChromosome, passedCheck bool) error {
+	mutex.Lock()
+	numRegisters := ib.stream.registers[chromosome.ChromosomeName]
+	mutex.Unlock()
+
+	checkpoint := ibCheckpoint{
+		Parameters:   ib.Parameters,
+		Samples:      ib.Samples,
+		NumRegisters: numRegisters,
+		PassedCheck:  passedCheck,
+		Chromosome:   chromosome,
+	}
+
+	if err := ib.dumpBlocks(ib.checkpointDumpFileName(chromosome.ChromosomeName), "w", ib.stream.compression, []*IBBlock{chromosome.Block}); err != nil {
+		return err
+	}
+
+	return ib.checkpointSaver(chromosome.ChromosomeName).Save(&checkpoint)
+}
+
+// LoadCheckpoints adds the chromosomes saved before a save was interrupted and
+// returns their names, to be skipped while reading. Incomplete checkpoints are
+// ignored and their chromosomes read again.
+func (ib *IBrowser) LoadCheckpoints() (chromosomeNames []string, err error) {
+	if ib.stream == nil {
+		return nil, fmt.Errorf("database is not being streamed")
+	}
+
+	pattern := ib.checkpointSaver("*").GenFilename()
+	prefix, suffix := pattern[:strings.Index(pattern, "*")], pattern[strings.Index(pattern, "*")+1:]
+
+	fileNames, err := filepath.Glob(pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fileName := range fileNames {
+		chromosomeName := strings.TrimSuffix(strings.TrimPrefix(fileName, prefix), suffix)
+
+		checkpoint, err := ib.loadCheckpoint(chromosomeName)
+
+		if err != nil {
+			fmt.Println("ignoring checkpoint of chromosome", chromosomeName, ":", err)
+			continue
+		}
+
+		if !isResumable(ib.Parameters, checkpoint.Parameters) {
+			return nil, fmt.Errorf("chromosome %s was saved with other parameters. save again without resuming", chromosomeName)
+		}
+
+		chromosome := checkpoint.Chromosome
+
+		if !ib.hasStreamLevels(chromosome) {
+			return nil, fmt.Errorf("chromosome %s was saved with other levels. save again without resuming", chromosomeName)
+		}
+
+		fmt.Println("resuming chromosome", chromosomeName)
+
+		chromosome.Release(checkpoint.PassedCheck)
+
+		ib.Chromosomes[chromosomeName] = chromosome
+		ib.ChromosomesNames = append(ib.ChromosomesNames, NamePosPair{chromosomeName, chromosome.ChromosomeNumber})
+		ib.NumRegisters += checkpoint.NumRegisters
+
+		chromosomeNames = append(chromosomeNames, chromosomeName)
+	}
+
+	sort.Sort(ib.ChromosomesNames)
+
+	// the whole genome block only holds the chromosomes read from now on
+	if len(chromosomeNames) > 0 {
+		ib.stream.needsRebuild = true
+	}
+
+	return chromosomeNames, nil
+}
+
+func (ib *IBrowser) loadCheckpoint(chromosomeName string) (*ibCheckpoint, error) {
+	checkpoint := ibCheckpoint{}
+
+	if err := ib.checkpointSaver(chromosomeName).Load(&checkpoint); err != nil {
+		return nil, err
+	}
+
+	if checkpoint.Chromosome == nil || checkpoint.Chromosome.ChromosomeName != chromosomeName {
+		return nil, fmt.Errorf("%w: checkpoint does not hold chromosome %s", ErrCorrupt, chromosomeName)
+	}
+
+	if atomic.LoadUint64(&ib.NumSamples) == 0 {
+		ib.SetSamples(&checkpoint.Samples)
+
+	} else if len(ib.Samples) != len(checkpoint.Samples) {
+		return nil, fmt.Errorf("%w: checkpoint has %d samples instead of %d", ErrCorrupt, len(checkpoint.Samples), len(ib.Samples))
+	}
+
+	if err := ib.dumpBlocks(ib.checkpointDumpFileName(chromosomeName), "r", ib.stream.compression, []*IBBlock{checkpoint.Chromosome.Block}); err != nil {
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+// hasStreamLevels tells whether a chromosome has the levels being streamed
+func (ib *IBrowser) hasStreamLevels(chromosome *IBChromosome) bool {
+	if len(chromosome.Levels) != len(ib.stream.levels) {
+		return false
+	}
+
+	for levelPos, level := range ib.stream.levels {
+		if chromosome.Levels[levelPos].Name != level.Name {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isResumable tells whether chromosomes saved with parameters o can be part of
+// a database saved with parameters p
+func isResumable(p Parameters, o Parameters) bool {
+	// these do not change the chromosomes
+	p.ContinueOnError, o.ContinueOnError = false, false
+	p.Description, o.Description = "", ""
+
+	return p == o
+}
@@ -0,0 +1,282 @@
+package ibrowser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/save"
+	"github.com/sauloalgolang/introgressionbrowser/vcf"
+)
+
+//
+// Helpers
+//
+
+var testChromosomes = []string{"ch01", "ch02", "ch03"}
+
+const testNumSamples = 6
+
+// writeTestVcf writes a VCF sorted by chromosome with blocks of many and few SNPs
+func writeTestVcf(t *testing.T, dir string) string {
+	t.Helper()
+
+	var b strings.Builder
+
+	b.WriteString("##fileformat=VCFv4.2\n")
+	b.WriteString("#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT")
+	for s := 0; s < testNumSamples; s++ {
+		fmt.Fprintf(&b, "\tS%d", s)
+	}
+	b.WriteString("\n")
+
+	for c, chromosome := range testChromosomes {
+		for pos := uint64(1); pos < 2000; pos += 1 + (pos*7+uint64(c))%13 {
+			// sparse region, merged with its neighbours
+			if pos > 800 && pos < 1100 && pos%5 != 0 {
+				continue
+			}
+
+			fmt.Fprintf(&b, "%s\t%d\t.\tA\tG\t50\t.\tDP=1\tGT", chromosome, pos)
+			for s := uint64(0); s < testNumSamples; s++ {
+				h := (pos*2654435761 + s*40503 + uint64(c)) >> 5
+				fmt.Fprintf(&b, "\t%d|%d", h%2, (h>>1)%2)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	fileName := filepath.Join(dir, "test.vcf")
+
+	if err := os.WriteFile(fileName, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return fileName
+}
+
+func newTestIBrowser(sourceFile string) *IBrowser {
+	return NewIBrowser(Parameters{
+		SourceFile:        sourceFile,
+		BlockMode:         "bp",
+		BlockSize:         100,
+		Compression:       "none",
+		ContinueOnError:   true,
+		CounterBits:       8,
+		Description:       "test",
+		Format:            "yaml",
+		KeepEmptyBlock:    true,
+		MaxSnpPerBlock:    math.MaxUint64,
+		MaxSnpPolicy:      "none",
+		MinSnpPerBlock:    5,
+		MinSnpPolicy:      "merge",
+		Resolutions:       "500",
+		SlidingWindowSize: 300,
+		SlidingWindowStep: 100,
+	})
+}
+
+func testCallBackParameters() vcf.CallBackParameters {
+	return vcf.CallBackParameters{
+		ContinueOnError: true,
+		NumBits:         8,
+		NumThreads:      1,
+	}
+}
+
+func addTestLevels(t *testing.T, ib *IBrowser) {
+	t.Helper()
+
+	if err := ib.AddResolutions([]uint64{500}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ib.AddSlidingWindow(300, 100); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// saveTestInMemory saves sourceFile keeping every chromosome in memory until the end
+func saveTestInMemory(t *testing.T, sourceFile string, outPrefix string) {
+	t.Helper()
+
+	ib := newTestIBrowser(sourceFile)
+
+	if err := vcf.OpenVcfFile(sourceFile, testCallBackParameters(), ib.RegisterCallBack); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ib.ApplySnpLimits(); err != nil {
+		t.Fatal(err)
+	}
+
+	addTestLevels(t, ib)
+
+	if err := ib.Save(outPrefix, "yaml", "none"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// streamTest streams sourceFile to outPrefix, resuming an interrupted save if
+// resume is set. registerCallBack returns the register callback of the database.
+// Returns the error reading the VCF file.
+func streamTest(t *testing.T, sourceFile string, outPrefix string, resume bool, registerCallBack func(ib *IBrowser) vcf.VCFCallBack) error {
+	t.Helper()
+
+	ib := newTestIBrowser(sourceFile)
+
+	if err := ib.StreamTo(outPrefix, "yaml", "none", true, resume); err != nil {
+		t.Fatal(err)
+	}
+
+	addTestLevels(t, ib)
+
+	callBackParameters := testCallBackParameters()
+
+	if resume {
+		skipChromosomes, err := ib.LoadCheckpoints()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		callBackParameters.SkipChromosomes = skipChromosomes
+	}
+
+	if err := vcf.OpenVcfFileChromosomes(sourceFile, callBackParameters, registerCallBack(ib), ib.FinishChromosome); err != nil {
+		return err
+	}
+
+	if err := ib.FinishStream(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ib.Save(outPrefix, "yaml", "none"); err != nil {
+		t.Fatal(err)
+	}
+
+	return nil
+}
+
+func registerAll(ib *IBrowser) vcf.VCFCallBack {
+	return ib.RegisterCallBack
+}
+
+var errTestInterrupted = errors.New("interrupted")
+
+// interruptAt fails reading at register numRegister of chromosome
+func interruptAt(chromosome string, numRegister int) func(ib *IBrowser) vcf.VCFCallBack {
+	return func(ib *IBrowser) vcf.VCFCallBack {
+		count := 0
+
+		return func(samples *VCFSamples, reg *VCFRegister) error {
+			if reg.Chromosome == chromosome {
+				count++
+
+				if count == numRegister {
+					return errTestInterrupted
+				}
+			}
+
+			return ib.RegisterCallBack(samples, reg)
+		}
+	}
+}
+
+// skipping fails if chromosome is read
+func skipping(t *testing.T, chromosome string) func(ib *IBrowser) vcf.VCFCallBack {
+	return func(ib *IBrowser) vcf.VCFCallBack {
+		return func(samples *VCFSamples, reg *VCFRegister) error {
+			if reg.Chromosome == chromosome {
+				t.Errorf("resumed save read chromosome %s again", chromosome)
+				return errTestInterrupted
+			}
+
+			return ib.RegisterCallBack(samples, reg)
+		}
+	}
+}
+
+// checkTestSaves checks the databases saved to both prefixes are equal
+func checkTestSaves(t *testing.T, outPrefix string, otherPrefix string) {
+	t.Helper()
+
+	ib := NewIBrowser(Parameters{})
+
+	if err := ib.Load(outPrefix, "yaml", "none", false); err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewIBrowser(Parameters{})
+
+	if err := other.Load(otherPrefix, "yaml", "none", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ib.ChromosomesNames) != len(testChromosomes) {
+		t.Fatalf("%d chromosomes, want %d", len(ib.ChromosomesNames), len(testChromosomes))
+	}
+
+	if ib.NumRegisters != other.NumRegisters {
+		t.Errorf("%d registers, want %d", other.NumRegisters, ib.NumRegisters)
+	}
+
+	if !ib.IsEqual(other) || !other.IsEqual(ib) {
+		t.Errorf("database saved to %s differs from %s", otherPrefix, outPrefix)
+	}
+}
+
+//
+// Stream
+//
+
+func TestStreamEqualsInMemory(t *testing.T) {
+	dir := t.TempDir()
+	sourceFile := writeTestVcf(t, dir)
+
+	saveTestInMemory(t, sourceFile, filepath.Join(dir, "memory"))
+
+	if err := streamTest(t, sourceFile, filepath.Join(dir, "stream"), false, registerAll); err != nil {
+		t.Fatal(err)
+	}
+
+	checkTestSaves(t, filepath.Join(dir, "memory"), filepath.Join(dir, "stream"))
+}
+
+//
+// Resume
+//
+
+func TestResumeEqualsInMemory(t *testing.T) {
+	dir := t.TempDir()
+	sourceFile := writeTestVcf(t, dir)
+	outPrefix := filepath.Join(dir, "stream")
+
+	saveTestInMemory(t, sourceFile, filepath.Join(dir, "memory"))
+
+	err := streamTest(t, sourceFile, outPrefix, false, interruptAt("ch02", 50))
+
+	if !errors.Is(err, errTestInterrupted) {
+		t.Fatalf("error %v, want %v", err, errTestInterrupted)
+	}
+
+	if !save.HasTempPrefix(outPrefix) {
+		t.Fatal("interrupted save removed its temporary folder")
+	}
+
+	if err := streamTest(t, sourceFile, outPrefix, true, skipping(t, "ch01")); err != nil {
+		t.Fatal(err)
+	}
+
+	if save.HasTempPrefix(outPrefix) {
+		t.Error("resumed save left its temporary folder behind")
+	}
+
+	checkTestSaves(t, filepath.Join(dir, "memory"), outPrefix)
+}
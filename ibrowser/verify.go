@@ -0,0 +1,126 @@
+package ibrowser
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/save"
+)
+
+//
+// Verify
+//
+
+// BlockError is a block whose register can not be read from its dump
+type BlockError struct {
+	FileName       string
+	ChromosomeName string
+	LevelName      string
+	BlockNumber    uint64
+	Serial         int64
+	Err            error
+}
+
+func (e BlockError) Error() string {
+	return fmt.Sprintf("chromosome %s level %s block %d (serial %d) in %s: %s", e.ChromosomeName, e.LevelName, e.BlockNumber, e.Serial, e.FileName, e.Err)
+}
+
+// Verify checks the database file of outPrefix against its checksum and
+// reads the register of every block from the dumps, which checks their
+// checksums. The database is loaded without matrices.
+// hasChecksum is false for databases saved without checksum file.
+// Containers, named by their file, are checked against the checksums of
+// their members.
+func (ib *IBrowser) Verify(outPrefix string) (hasChecksum bool, errs []error) {
+	if save.IsContainer(outPrefix) {
+		return true, ib.verifyContainer(outPrefix)
+	}
+
+	found, format, compression, _ := save.GuessPrefixFormat(outPrefix)
+
+	if !found {
+		return false, []error{fmt.Errorf("database %s not found", outPrefix)}
+	}
+
+	hasChecksum, err := NewSaverCompressed(outPrefix, format, compression).Verify()
+
+	if err != nil {
+		return hasChecksum, []error{err}
+	}
+
+	if err := ib.Load(outPrefix, format, compression, true); err != nil {
+		return hasChecksum, []error{err}
+	}
+
+	return hasChecksum, ib.verifyDumps(outPrefix, NewMmapArrayFile)
+}
+
+// verifyContainer checks the checksums of all files in a container and
+// reads the register of every block from the dumps in it
+func (ib *IBrowser) verifyContainer(containerFileName string) []error {
+	container, err := save.OpenContainer(containerFileName)
+
+	if err != nil {
+		return []error{err}
+	}
+
+	defer container.Close()
+
+	if err := container.Verify(); err != nil {
+		return []error{err}
+	}
+
+	if err := ib.LoadContainer(containerFileName, true); err != nil {
+		return []error{err}
+	}
+
+	return ib.verifyDumps(save.ContainerPrefix(filepath.Base(containerFileName)), container.MmapArrayFile)
+}
+
+// verifyDumps reads the register of every block of a loaded database
+func (ib *IBrowser) verifyDumps(outPrefix string, open func(string) (*MmapArrayFile, error)) (errs []error) {
+	summary := []*IBBlock{ib.Block}
+
+	for _, chromosome := range ib.GetChromosomes() {
+		summary = append(summary, chromosome.Block)
+	}
+
+	errs = ib.verifyDump(open, ib.GenMatrixDumpFileName(outPrefix, "", true, false), "summary", summary, errs)
+
+	for _, chromosome := range ib.GetChromosomes() {
+		errs = ib.verifyDump(open, ib.GenMatrixDumpFileName(outPrefix, chromosome.ChromosomeName, false, false), BASE_LEVEL_NAME, chromosome.Blocks, errs)
+
+		for _, level := range chromosome.Levels {
+			errs = ib.verifyDump(open, ib.GenLevelMatrixDumpFileName(outPrefix, chromosome.ChromosomeName, level.Name), level.Name, level.Blocks, errs)
+		}
+	}
+
+	return errs
+}
+
+func (ib *IBrowser) verifyDump(open func(string) (*MmapArrayFile, error), fileName string, levelName string, blocks []*IBBlock, errs []error) []error {
+	mm, err := open(fileName)
+
+	if err != nil {
+		return append(errs, err)
+	}
+
+	defer mm.Close()
+
+	for _, block := range blocks {
+		if _, err := block.ReadMatrix(mm, ib.RegisterSize); err != nil {
+			errs = append(errs, BlockError{
+				FileName:       fileName,
+				ChromosomeName: block.ChromosomeName,
+				LevelName:      levelName,
+				BlockNumber:    block.BlockNumber,
+				Serial:         block.Serial,
+				Err:            err,
+			})
+		}
+	}
+
+	return errs
+}
@@ -27,16 +27,16 @@ func NewChromosomeNames(size int, cap int) (cn *ChromosomeNamesType) {
 	return cn
 }
 
-func (cn *ChromosomeNamesType) Save(outPrefix string) {
+func (cn *ChromosomeNamesType) Save(outPrefix string) error {
 	saver := NewSaver(outPrefix, "yaml")
 	saver.SetExtension(IndexExtension)
-	saver.Save(cn)
+	return saver.Save(cn)
 }
 
-func (cn *ChromosomeNamesType) Load(outPrefix string) {
+func (cn *ChromosomeNamesType) Load(outPrefix string) error {
 	saver := NewSaver(outPrefix, "yaml")
 	saver.SetExtension(IndexExtension)
-	saver.Load(cn)
+	return saver.Load(cn)
 }
 
 func (cn *ChromosomeNamesType) Exists(outPrefix string) (bool, error) {
@@ -66,7 +66,13 @@ func (cn *ChromosomeNamesType) Add(chromosomeName string, startPosition int64) {
 			cn.NumRegisters += infoC.NumRegisters
 		}
 
-		cn.Infos[cn.NumChromosomes-1].NumRegisters = startPosition - cn.Infos[cn.NumChromosomes-2].StartPosition
+		if cn.NumChromosomes == 0 {
+			fmt.Println("no chromosomes found")
+			return
+		}
+
+		cn.Infos[cn.NumChromosomes-1].NumRegisters = startPosition - cn.Infos[cn.NumChromosomes-1].StartPosition
+		cn.NumRegisters += cn.Infos[cn.NumChromosomes-1].NumRegisters
 
 		cn.StartPosition = cn.Infos[0].StartPosition
 		cn.EndPosition = cn.Infos[cn.NumChromosomes-1].StartPosition
@@ -9,3 +9,11 @@ import (
 //
 
 var NewSaver = save.NewSaver
+
+//
+// Errors
+//
+
+var ErrCorrupt = save.ErrCorrupt
+var ErrVersion = save.ErrVersion
+var ErrNotFound = save.ErrNotFound
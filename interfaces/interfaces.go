@@ -9,7 +9,7 @@ import (
 // vcf
 //
 
-type VCFMaskedReaderType func(io.Reader, CallBackParameters)
+type VCFMaskedReaderType func(io.Reader, CallBackParameters) error
 type VCFMaskedReaderChromosomeType func(io.Reader, bool, []string)
 
 //
@@ -18,11 +18,14 @@ type VCFMaskedReaderChromosomeType func(io.Reader, bool, []string)
 
 type CallBackParameters struct {
 	ContinueOnError bool
+	NoDistance      bool
 	NumBits         int
 	NumThreads      int
+	SkipChromosomes []string // already read. skipped without being parsed
 }
 
 type Parameters struct {
+	BlockMode              string
 	BlockSize              uint64
 	Chromosomes            string
 	Compression            string
@@ -35,12 +38,18 @@ type Parameters struct {
 	Format                 string
 	KeepEmptyBlock         bool
 	MaxSnpPerBlock         uint64
+	MaxSnpPolicy           string
 	MinSnpPerBlock         uint64
+	MinSnpPolicy           string
+	Resolutions            string
+	SlidingWindowSize      uint64
+	SlidingWindowStep      uint64
 	SourceFile             string
 }
 
 func (p Parameters) String() (res string) {
 	res += fmt.Sprintf("Parameters:\n")
+	res += fmt.Sprintf(" BlockMode              : %#v\n", p.BlockMode)
 	res += fmt.Sprintf(" BlockSize              : %d\n", p.BlockSize)
 	res += fmt.Sprintf(" Chromosomes            : %#v\n", p.Chromosomes)
 	res += fmt.Sprintf(" Compression            : %#v\n", p.Compression)
@@ -53,7 +62,12 @@ func (p Parameters) String() (res string) {
 	res += fmt.Sprintf(" Format                 : %#v\n", p.Format)
 	res += fmt.Sprintf(" KeepEmptyBlock         : %#v\n", p.KeepEmptyBlock)
 	res += fmt.Sprintf(" MaxSnpPerBlock         : %d\n", p.MaxSnpPerBlock)
+	res += fmt.Sprintf(" MaxSnpPolicy           : %#v\n", p.MaxSnpPolicy)
 	res += fmt.Sprintf(" MinSnpPerBlock         : %d\n", p.MinSnpPerBlock)
+	res += fmt.Sprintf(" MinSnpPolicy           : %#v\n", p.MinSnpPolicy)
+	res += fmt.Sprintf(" Resolutions            : %#v\n", p.Resolutions)
+	res += fmt.Sprintf(" SlidingWindowSize      : %d\n", p.SlidingWindowSize)
+	res += fmt.Sprintf(" SlidingWindowStep      : %d\n", p.SlidingWindowStep)
 	res += fmt.Sprintf(" SourceFile             : %#v\n", p.SourceFile)
 	return res
 }
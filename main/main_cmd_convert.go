@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/ibrowser"
+	"github.com/sauloalgolang/introgressionbrowser/save"
+)
+
+type ConvertCommand struct {
+	Outfile         string             `long:"outfile" description:"Output file prefix. Must differ from the input prefix" required:"true"`
+	CounterBits     int                `long:"counterBits" description:"Minimum number of bits of the converted counters. Registers are written with fixed width counters of at least this many bits, wider if their values need it. 0 writes each register with its smallest encoding" choice:"0" choice:"8" choice:"16" choice:"32" choice:"64" default:"0"`
+	DeleteSource    bool               `long:"deleteSource" description:"Delete the files of the input database not used by the converted database once verified"`
+	Infile          ConvertArgsOptions `long:"indb" description:"Input database prefix" positional-args:"true" positional-arg-name:"Input Database Prefix" hidden:"true"`
+	ProfileOptions  ProfileOptions
+	SaveLoadOptions SaveLoadOptions
+}
+
+type ConvertArgsOptions struct {
+	DbPrefix string `long:"indb" description:"Input database prefix" required:"true" positional-arg-name:"Input Database Prefix"`
+}
+
+var convertCommand ConvertCommand
+
+func (x *ConvertCommand) Execute(args []string) error {
+	fmt.Printf("Convert\n")
+
+	sourceFile := x.Infile.DbPrefix
+	sourcePrefix := sourceFile
+
+	if save.IsContainer(sourceFile) {
+		sourcePrefix = save.ContainerPrefix(sourceFile)
+	}
+
+	outfile := x.Outfile
+
+	if isSamePrefix(outfile, sourcePrefix) {
+		fmt.Println("outfile must differ from the input database prefix:", outfile)
+		os.Exit(1)
+	}
+
+	fmt.Printf(" sourceFile             : %s\n", sourceFile)
+	fmt.Printf(" outfile                : %s\n", outfile)
+	fmt.Printf(" counterBits            : %d\n", x.CounterBits)
+	fmt.Printf(" deleteSource           : %#v\n", x.DeleteSource)
+	fmt.Println(x.SaveLoadOptions)
+	fmt.Println(x.ProfileOptions)
+
+	processSaveLoad(x.SaveLoadOptions)
+	profileCloser := processProfile(x.ProfileOptions)
+
+	log.Println("Openning", sourceFile)
+
+	ib := ibrowser.NewIBrowser(Parameters{})
+
+	sourceFiles, err := loadConvertSource(ib, sourceFile)
+
+	if err != nil {
+		fmt.Println("error loading:", err)
+		os.Exit(1)
+	}
+
+	if x.CounterBits != 0 {
+		ib.SetCounterBits(x.CounterBits)
+	}
+
+	ib.Parameters.Format = x.SaveLoadOptions.Format
+	ib.Parameters.Compression = x.SaveLoadOptions.Compression
+
+	if err := saveDatabase(ib, outfile, x.SaveLoadOptions); err != nil {
+		fmt.Println("error saving:", err)
+		os.Exit(1)
+	}
+
+	log.Println("Verifying", outfile)
+
+	converted := ibrowser.NewIBrowser(Parameters{})
+
+	var convertedFiles []string
+
+	if x.SaveLoadOptions.Container {
+		err = converted.LoadContainer(save.ContainerFileName(outfile), false)
+		convertedFiles = []string{save.ContainerFileName(outfile)}
+	} else {
+		err = converted.Load(outfile, x.SaveLoadOptions.Format, x.SaveLoadOptions.Compression, false)
+		convertedFiles = converted.DatabaseFiles(outfile, x.SaveLoadOptions.Format, x.SaveLoadOptions.Compression)
+	}
+
+	if err != nil {
+		fmt.Println("error loading converted database:", err)
+		os.Exit(1)
+	}
+
+	// the counters of ib were converted in place. compare against the source as saved
+	ib = ibrowser.NewIBrowser(Parameters{})
+
+	if _, err := loadConvertSource(ib, sourceFile); err != nil {
+		fmt.Println("error reloading:", err)
+		os.Exit(1)
+	}
+
+	if !ib.IsEqual(converted) {
+		fmt.Println("converted database differs from", sourceFile)
+		os.Exit(1)
+	}
+
+	if !x.SaveLoadOptions.NoCheck {
+		if converted.Check() {
+			log.Println("Passed all tests")
+		} else {
+			log.Println("Failed tests")
+			os.Exit(1)
+		}
+	}
+
+	if x.DeleteSource {
+		deleteConvertSource(sourceFiles, convertedFiles)
+	}
+
+	profileCloser()
+
+	return nil
+}
+
+// loadConvertSource loads a database or container and returns its files
+func loadConvertSource(ib *ibrowser.IBrowser, sourceFile string) ([]string, error) {
+	if save.IsContainer(sourceFile) {
+		return []string{sourceFile}, ib.LoadContainer(sourceFile, false)
+	}
+
+	found, format, compression, _ := save.GuessPrefixFormat(sourceFile)
+
+	if !found {
+		if _, err := os.Stat(save.ContainerFileName(sourceFile)); err == nil {
+			return loadConvertSource(ib, save.ContainerFileName(sourceFile))
+		}
+
+		return nil, fmt.Errorf("%w: database %s", save.ErrNotFound, sourceFile)
+	}
+
+	if err := ib.Load(sourceFile, format, compression, false); err != nil {
+		return nil, err
+	}
+
+	return ib.DatabaseFiles(sourceFile, format, compression), nil
+}
+
+// isSamePrefix tells whether two database prefixes name the same files
+func isSamePrefix(prefix string, other string) bool {
+	absPrefix, errPrefix := filepath.Abs(prefix)
+	absOther, errOther := filepath.Abs(other)
+
+	if errPrefix != nil || errOther != nil {
+		return filepath.Clean(prefix) == filepath.Clean(other)
+	}
+
+	return absPrefix == absOther
+}
+
+// deleteConvertSource deletes the files of the source database which were
+// not replaced by the converted database
+func deleteConvertSource(sourceFiles []string, convertedFiles []string) {
+	kept := make(map[string]bool, len(convertedFiles))
+
+	for _, fileName := range convertedFiles {
+		kept[fileName] = true
+	}
+
+	for _, fileName := range sourceFiles {
+		if kept[fileName] {
+			continue
+		}
+
+		if err := os.Remove(fileName); err != nil {
+			if !os.IsNotExist(err) {
+				fmt.Println("error deleting:", err)
+			}
+			continue
+		}
+
+		fmt.Println("deleted", fileName)
+	}
+}
+
+func init() {
+	parser.AddCommand("convert",
+		"Convert database",
+		"Convert a database to another format, compression or counter width, verifying the converted database before deleting the source",
+		&convertCommand)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/export"
+	"github.com/sauloalgolang/introgressionbrowser/ibrowser"
+)
+
+type ExportCommand struct {
+	Outfile        string            `long:"outfile" description:"Output file. Defaults to the database prefix with the format extension" default:""`
+	Format         string            `long:"format" description:"Export format: parquet, arrow, npz (legacy python reader layout)" choice:"parquet" choice:"arrow" choice:"npz" default:"parquet"`
+	Level          string            `long:"level" description:"Level name or window size to export. Defaults to the base blocks" default:""`
+	Infile         ExportArgsOptions `long:"indb" description:"Input database prefix" positional-args:"true" positional-arg-name:"Input Database Prefix" hidden:"true"`
+	ProfileOptions ProfileOptions
+}
+
+type ExportArgsOptions struct {
+	DbPrefix string `long:"indb" description:"Input database prefix" required:"true" positional-arg-name:"Input Database Prefix"`
+}
+
+var exportCommand ExportCommand
+
+func (x *ExportCommand) Execute(args []string) error {
+	fmt.Printf("Export\n")
+
+	sourceFile := x.Infile.DbPrefix
+
+	if _, ok := export.Formats[x.Format]; !ok {
+		fmt.Println("Unknown export format: ", x.Format, ". valid formats are:")
+		for _, k := range export.FormatNames {
+			fmt.Println(" ", k)
+		}
+		os.Exit(1)
+	}
+
+	if x.Outfile == "" {
+		x.Outfile = export.GenFilename(sourceFile, x.Format)
+	}
+
+	fmt.Printf(" sourceFile             : %s\n", sourceFile)
+	fmt.Printf(" outfile                : %s\n", x.Outfile)
+	fmt.Printf(" format                 : %s\n", x.Format)
+	fmt.Printf(" level                  : %s\n", x.Level)
+	fmt.Println(x.ProfileOptions)
+
+	profileCloser := processProfile(x.ProfileOptions)
+
+	log.Println("Openning", sourceFile)
+
+	ib := ibrowser.NewIBrowser(Parameters{})
+
+	if err := ib.EasyLoadPrefix(sourceFile, false); err != nil {
+		fmt.Println("error loading:", err)
+		os.Exit(1)
+	}
+
+	opts := export.ExportOptions{
+		DatabaseName: export.GenDatabaseName(sourceFile),
+		OutFile:      x.Outfile,
+		Level:        x.Level,
+	}
+
+	log.Println("Exporting", x.Outfile)
+
+	if err := export.Export(ib, x.Format, opts); err != nil {
+		fmt.Println("error exporting:", err)
+		os.Exit(1)
+	}
+
+	profileCloser()
+
+	return nil
+}
+
+func init() {
+	parser.AddCommand("export",
+		"Export database",
+		"Export the block matrices of a database as long format tables for external tools",
+		&exportCommand)
+}
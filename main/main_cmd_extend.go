@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/ibrowser"
+	"github.com/sauloalgolang/introgressionbrowser/vcf"
+)
+
+type ExtendCommand struct {
+	Original          string            `long:"original" description:"VCF file used to create the database" required:"true"`
+	NoContinueOnError bool              `long:"continueOnError" description:"Continue reading the file on parsing error"`
+	Outfile           string            `long:"outfile" description:"Output file prefix" default:"res/extended"`
+	Description       string            `long:"description" description:"Description of the database" default:""`
+	Infiles           ExtendArgsOptions `long:"indb" description:"Input database prefix and VCF with the new samples" positional-args:"true" hidden:"true"`
+	ProfileOptions    ProfileOptions
+	SaveLoadOptions   SaveLoadOptions
+}
+
+type ExtendArgsOptions struct {
+	DbPrefix string `long:"indb" description:"Input database prefix" required:"true" positional-arg-name:"Input Database Prefix"`
+	VCF      string `long:"infile" description:"VCF file with the new samples at the same sites" required:"true" positional-arg-name:"Input VCF file"`
+}
+
+var extendCommand ExtendCommand
+
+func (x *ExtendCommand) Execute(args []string) error {
+	fmt.Printf("Extend\n")
+
+	sourceFile := x.Infiles.DbPrefix
+	originalFile := x.Original
+	newFile := x.Infiles.VCF
+
+	for _, vcfFile := range []string{originalFile, newFile} {
+		fi, err := os.Stat(vcfFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !fi.Mode().IsRegular() {
+			fmt.Println("input file ", vcfFile, " is not a file")
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf(" sourceFile             : %s\n", sourceFile)
+	fmt.Printf(" originalFile           : %s\n", originalFile)
+	fmt.Printf(" newFile                : %s\n", newFile)
+	fmt.Printf(" outfile                : %s\n", x.Outfile)
+	fmt.Println(x.SaveLoadOptions)
+	fmt.Println(x.ProfileOptions)
+
+	processSaveLoad(x.SaveLoadOptions)
+	profileCloser := processProfile(x.ProfileOptions)
+
+	log.Println("Openning", sourceFile)
+
+	ibrowser := ibrowser.NewIBrowser(Parameters{})
+
+	if err := ibrowser.EasyLoadPrefix(sourceFile, false); err != nil {
+		fmt.Println("error loading:", err)
+		os.Exit(1)
+	}
+
+	callBackParameters := CallBackParameters{
+		ContinueOnError: !x.NoContinueOnError,
+		NumBits:         ibrowser.CounterBits,
+		NumThreads:      1,
+	}
+
+	countSnps(ibrowser, originalFile, callBackParameters)
+
+	log.Println("Openning", originalFile, "and", newFile)
+
+	if err := vcf.OpenVcfFilePair(originalFile, newFile, callBackParameters, ibrowser.ExtendCallBack); err != nil {
+		fmt.Println("error reading vcf:", err)
+		os.Exit(1)
+	}
+
+	if err := ibrowser.FinishExtension(); err != nil {
+		fmt.Println("error extending:", err)
+		os.Exit(1)
+	}
+
+	ibrowser.Parameters.SourceFile += "," + newFile
+
+	if x.Description != "" {
+		ibrowser.Parameters.Description = x.Description
+	}
+
+	ibrowser.Parameters.Format = x.SaveLoadOptions.Format
+	ibrowser.Parameters.Compression = x.SaveLoadOptions.Compression
+
+	if !x.SaveLoadOptions.NoCheck {
+		checkRes := ibrowser.Check()
+
+		if checkRes {
+			log.Println("Passed all tests")
+		} else {
+			log.Println("Failed tests")
+			os.Exit(1)
+		}
+	}
+
+	if err := saveDatabase(ibrowser, x.Outfile, x.SaveLoadOptions); err != nil {
+		fmt.Println("error saving:", err)
+		os.Exit(1)
+	}
+
+	profileCloser()
+
+	return nil
+}
+
+func init() {
+	parser.AddCommand("extend",
+		"Add samples to database",
+		"Add new samples to an existing database from a VCF with the same sites, computing only the pairs involving the new samples",
+		&extendCommand)
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 )
 
 import (
 	"github.com/sauloalgolang/introgressionbrowser/ibrowser"
+	"github.com/sauloalgolang/introgressionbrowser/save"
 )
 
 type LoadCommand struct {
@@ -44,9 +46,21 @@ func (x *LoadCommand) Execute(args []string) error {
 
 	ibrowser := ibrowser.NewIBrowser(parameters)
 
-	ibrowser.Load(sourceFile, x.SaveLoadOptions.Format, x.SaveLoadOptions.Compression, x.Soft)
+	var err error
 
-	if !x.SaveLoadOptions.NoCheck {
+	if save.IsContainer(sourceFile) {
+		err = ibrowser.LoadContainer(sourceFile, x.Soft)
+	} else {
+		err = ibrowser.Load(sourceFile, x.SaveLoadOptions.Format, x.SaveLoadOptions.Compression, x.Soft)
+	}
+
+	if err != nil {
+		fmt.Println("error loading:", err)
+		os.Exit(1)
+	}
+
+	// soft loads have no matrices to check
+	if !x.SaveLoadOptions.NoCheck && !x.Soft {
 		checkRes := ibrowser.Check()
 
 		if checkRes {
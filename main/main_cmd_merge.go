@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/ibrowser"
+)
+
+type MergeCommand struct {
+	Outfile         string           `long:"outfile" description:"Output file prefix" default:"res/merged"`
+	Description     string           `long:"description" description:"Description of the database" default:""`
+	Infiles         MergeArgsOptions `long:"indb" description:"Input database prefixes" positional-args:"true" positional-arg-name:"Input Database Prefixes" hidden:"true"`
+	ProfileOptions  ProfileOptions
+	SaveLoadOptions SaveLoadOptions
+}
+
+type MergeArgsOptions struct {
+	DbPrefixes []string `long:"indb" description:"Input database prefixes" required:"2" positional-arg-name:"Input Database Prefixes"`
+}
+
+var mergeCommand MergeCommand
+
+func (x *MergeCommand) Execute(args []string) error {
+	fmt.Printf("Merge\n")
+
+	sourceFiles := x.Infiles.DbPrefixes
+
+	if len(sourceFiles) < 2 {
+		fmt.Println("at least two database prefixes are needed")
+		os.Exit(1)
+	}
+
+	fmt.Printf(" sourceFiles            : %v\n", sourceFiles)
+	fmt.Printf(" outfile                : %s\n", x.Outfile)
+	fmt.Println(x.SaveLoadOptions)
+	fmt.Println(x.ProfileOptions)
+
+	processSaveLoad(x.SaveLoadOptions)
+	profileCloser := processProfile(x.ProfileOptions)
+
+	var merged *ibrowser.IBrowser
+
+	for _, sourceFile := range sourceFiles {
+		log.Println("Openning", sourceFile)
+
+		ib := ibrowser.NewIBrowser(Parameters{})
+
+		if err := ib.EasyLoadPrefix(sourceFile, false); err != nil {
+			fmt.Println("error loading:", err)
+			os.Exit(1)
+		}
+
+		if !x.SaveLoadOptions.NoCheck {
+			if !ib.Check() {
+				log.Println("Failed tests for", sourceFile)
+				os.Exit(1)
+			}
+		}
+
+		if merged == nil {
+			merged = ib
+		} else {
+			if err := merged.Merge(ib); err != nil {
+				fmt.Println("error merging", sourceFile, ":", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if x.Description != "" {
+		merged.Parameters.Description = x.Description
+	}
+
+	merged.Parameters.Format = x.SaveLoadOptions.Format
+	merged.Parameters.Compression = x.SaveLoadOptions.Compression
+
+	if !x.SaveLoadOptions.NoCheck {
+		checkRes := merged.Check()
+
+		if checkRes {
+			log.Println("Passed all tests")
+		} else {
+			log.Println("Failed tests")
+			os.Exit(1)
+		}
+	}
+
+	if err := saveDatabase(merged, x.Outfile, x.SaveLoadOptions); err != nil {
+		fmt.Println("error saving:", err)
+		os.Exit(1)
+	}
+
+	profileCloser()
+
+	return nil
+}
+
+func init() {
+	parser.AddCommand("merge",
+		"Merge databases",
+		"Merge databases created from the same samples and block size into a single database",
+		&mergeCommand)
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 import (
@@ -13,15 +15,23 @@ import (
 )
 
 type SaveCommand struct {
-	BlockSize         uint64          `long:"blockSize" description:"Block size" default:"100000"`
+	BlockMode         string          `long:"blockMode" description:"Block mode: bp (fixed base pair windows) or snps (fixed number of SNPs per block)" choice:"bp" choice:"snps" default:"bp"`
+	BlockSize         uint64          `long:"blockSize" description:"Block size. Base pairs in bp mode, SNPs in snps mode" default:"100000"`
 	Chromosomes       string          `long:"chromosomes" description:"Comma separated list of chromomomes to read" default:""`
 	NoContinueOnError bool            `long:"continueOnError" description:"Continue reading the file on parsing error"`
-	CounterBits       int             `long:"counterBits" description:"Number of bits" default:"32"`
+	CounterBits       int             `long:"counterBits" description:"Initial number of bits. Counters are promoted per block to 16, 32 or 64 bits on overflow" choice:"8" choice:"16" choice:"32" choice:"64" default:"8"`
 	NoKeepEmptyBlock  bool            `long:"keepEmptyBlocks" description:"Keep empty blocks"`
 	MaxSnpPerBlock    uint64          `long:"maxSnpPerBlock" description:"Maximum number of SNPs per block" default:"18446744073709551615"`
+	MaxSnpPolicy      string          `long:"maxSnpPolicy" description:"What to do with blocks above maxSnpPerBlock: none, flag or downsample (keep maxSnpPerBlock SNPs evenly spread over the block)" choice:"none" choice:"flag" choice:"downsample" default:"none"`
 	MinSnpPerBlock    uint64          `long:"minSnpPerBlock" description:"Minimum number of SNPs per block" default:"10"`
+	MinSnpPolicy      string          `long:"minSnpPolicy" description:"What to do with blocks below minSnpPerBlock: none, flag, mask (exclude from summaries) or merge (with neighbour blocks)" choice:"none" choice:"flag" choice:"mask" choice:"merge" default:"none"`
+	Resolutions       string          `long:"resolutions" description:"Comma separated list of extra resolutions, multiples of blockSize. Eg: 1000000,10000000" default:""`
+	SlidingWindowSize uint64          `long:"slidingWindowSize" description:"Sliding window size, multiple of blockSize. 0 disables sliding windows" default:"0"`
+	SlidingWindowStep uint64          `long:"slidingWindowStep" description:"Sliding window step, multiple of blockSize. 0 uses blockSize" default:"0"`
 	Outfile           string          `long:"outfile" description:"Output file prefix" default:"res/output"`
 	Description       string          `long:"description" description:"Description of the database" default:""`
+	Stream            bool            `long:"stream" description:"Save each chromosome once read instead of keeping all chromosomes in memory until the end. The VCF file must be sorted by chromosome"`
+	Resume            bool            `long:"resume" description:"Resume an interrupted streamed save with the same options, reading only the chromosomes not saved yet"`
 	Infile            SaveArgsOptions `long:"infile" description:"Input VCF file" positional-args:"true" positional-arg-name:"Input VCF file" hidden:"true"`
 	ProfileOptions    ProfileOptions
 	SaveLoadOptions   SaveLoadOptions
@@ -42,9 +52,9 @@ type DebugOptions struct {
 func (d DebugOptions) String() (res string) {
 	res += fmt.Sprintf("Debug:\n")
 	res += fmt.Sprintf(" Debug                  : %#v\n", d.Debug)
-	res += fmt.Sprintf(" DebugFirstOnly         : %#v\n", d.Debug)
-	res += fmt.Sprintf(" DebugMaxRegisterThread : %d\n", d.Debug)
-	res += fmt.Sprintf(" DebugMaxRegisterChrom  : %d\n", d.Debug)
+	res += fmt.Sprintf(" DebugFirstOnly         : %#v\n", d.DebugFirstOnly)
+	res += fmt.Sprintf(" DebugMaxRegisterThread : %d\n", d.DebugMaxRegisterThread)
+	res += fmt.Sprintf(" DebugMaxRegisterChrom  : %d\n", d.DebugMaxRegisterChrom)
 	return res
 }
 
@@ -83,6 +93,11 @@ func (x *SaveCommand) Execute(args []string) error {
 	fmt.Println(x.ProfileOptions)
 	fmt.Println(x.DebugOptions)
 
+	if x.Resume && !x.Stream {
+		fmt.Println("only streamed saves can be resumed. use --stream")
+		os.Exit(1)
+	}
+
 	processDebug(x.DebugOptions)
 	processSaveLoad(x.SaveLoadOptions)
 	profileCloser := processProfile(x.ProfileOptions)
@@ -97,7 +112,53 @@ func (x *SaveCommand) Execute(args []string) error {
 		NumThreads:      x.SaveLoadOptions.NumThreads,
 	}
 
-	vcf.OpenVcfFile(sourceFile, callBackParameters, ibrowser.RegisterCallBack)
+	if !x.Stream {
+		countSnps(ibrowser, sourceFile, callBackParameters)
+
+		if err := vcf.OpenVcfFile(sourceFile, callBackParameters, ibrowser.RegisterCallBack); err != nil {
+			fmt.Println("error reading vcf:", err)
+			os.Exit(1)
+		}
+
+		if err := ibrowser.ApplySnpLimits(); err != nil {
+			fmt.Println("error applying snp limits:", err)
+			os.Exit(1)
+		}
+
+		addLevels(ibrowser, x)
+
+	} else {
+		// chromosomes are saved and released as soon as they are read
+		if err := ibrowser.StreamTo(x.Outfile, x.SaveLoadOptions.Format, x.SaveLoadOptions.Compression, !x.SaveLoadOptions.NoCheck, x.Resume); err != nil {
+			fmt.Println("error saving:", err)
+			os.Exit(1)
+		}
+
+		addLevels(ibrowser, x)
+
+		if x.Resume {
+			skipChromosomes, err := ibrowser.LoadCheckpoints()
+
+			if err != nil {
+				fmt.Println("error resuming:", err)
+				os.Exit(1)
+			}
+
+			callBackParameters.SkipChromosomes = skipChromosomes
+		}
+
+		countSnps(ibrowser, sourceFile, callBackParameters)
+
+		if err := vcf.OpenVcfFileChromosomes(sourceFile, callBackParameters, ibrowser.RegisterCallBack, ibrowser.FinishChromosome); err != nil {
+			fmt.Println("error reading vcf:", err)
+			os.Exit(1)
+		}
+
+		if err := ibrowser.FinishStream(); err != nil {
+			fmt.Println("error saving:", err)
+			os.Exit(1)
+		}
+	}
 
 	if !x.SaveLoadOptions.NoCheck {
 		checkRes := ibrowser.Check()
@@ -109,13 +170,45 @@ func (x *SaveCommand) Execute(args []string) error {
 		}
 	}
 
-	ibrowser.Save(x.Outfile, x.SaveLoadOptions.Format, x.SaveLoadOptions.Compression)
+	if err := saveDatabase(ibrowser, x.Outfile, x.SaveLoadOptions); err != nil {
+		fmt.Println("error saving:", err)
+		os.Exit(1)
+	}
 
 	profileCloser()
 
 	return nil
 }
 
+// countSnps reads the VCF without distances to count the SNPs of each block,
+// needed to downsample evenly
+func countSnps(ib *ibrowser.IBrowser, sourceFile string, callBackParameters CallBackParameters) {
+	if !ib.NeedsSnpCounts() {
+		return
+	}
+
+	log.Println("Counting SNPs of", sourceFile)
+
+	callBackParameters.NoDistance = true
+
+	if err := vcf.OpenVcfFile(sourceFile, callBackParameters, ib.CountCallBack); err != nil {
+		fmt.Println("error reading vcf:", err)
+		os.Exit(1)
+	}
+}
+
+func addLevels(ib *ibrowser.IBrowser, x *SaveCommand) {
+	if err := ib.AddResolutions(processResolutions(x.Resolutions)); err != nil {
+		fmt.Println("error adding resolutions:", err)
+		os.Exit(1)
+	}
+
+	if err := ib.AddSlidingWindow(x.SlidingWindowSize, x.SlidingWindowStep); err != nil {
+		fmt.Println("error adding sliding window:", err)
+		os.Exit(1)
+	}
+}
+
 func processDebug(opts DebugOptions) {
 	vcf.DEBUG = opts.Debug
 	vcf.ONLYFIRST = opts.DebugFirstOnly
@@ -123,7 +216,31 @@ func processDebug(opts DebugOptions) {
 	vcf.BREAKAT_CHROM = opts.DebugMaxRegisterChrom
 }
 
+func processResolutions(resolutionsStr string) (resolutions []uint64) {
+	resolutions = make([]uint64, 0, 0)
+
+	for _, resolutionStr := range strings.Split(resolutionsStr, ",") {
+		resolutionStr = strings.TrimSpace(resolutionStr)
+
+		if resolutionStr == "" {
+			continue
+		}
+
+		resolution, err := strconv.ParseUint(resolutionStr, 10, 64)
+
+		if err != nil || resolution == 0 {
+			fmt.Println("invalid resolution:", resolutionStr)
+			os.Exit(1)
+		}
+
+		resolutions = append(resolutions, resolution)
+	}
+
+	return resolutions
+}
+
 func processSaveParameters(parameters *Parameters, saveCommand SaveCommand) {
+	parameters.BlockMode = saveCommand.BlockMode
 	parameters.BlockSize = saveCommand.BlockSize
 	parameters.Chromosomes = saveCommand.Chromosomes
 	parameters.ContinueOnError = !saveCommand.NoContinueOnError
@@ -131,7 +248,12 @@ func processSaveParameters(parameters *Parameters, saveCommand SaveCommand) {
 	parameters.Description = saveCommand.Description
 	parameters.KeepEmptyBlock = !saveCommand.NoKeepEmptyBlock
 	parameters.MaxSnpPerBlock = saveCommand.MaxSnpPerBlock
+	parameters.MaxSnpPolicy = saveCommand.MaxSnpPolicy
 	parameters.MinSnpPerBlock = saveCommand.MinSnpPerBlock
+	parameters.MinSnpPolicy = saveCommand.MinSnpPolicy
+	parameters.Resolutions = saveCommand.Resolutions
+	parameters.SlidingWindowSize = saveCommand.SlidingWindowSize
+	parameters.SlidingWindowStep = saveCommand.SlidingWindowStep
 }
 
 func processDebugParameters(parameters *Parameters, debugOptions DebugOptions) {
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/ibrowser"
+)
+
+type VerifyCommand struct {
+	Infile VerifyArgsOptions `long:"indb" description:"Input database prefix" positional-args:"true" positional-arg-name:"Input Database Prefix" hidden:"true"`
+}
+
+type VerifyArgsOptions struct {
+	DbPrefix string `long:"indb" description:"Input database prefix" required:"true" positional-arg-name:"Input Database Prefix"`
+}
+
+var verifyCommand VerifyCommand
+
+func (x *VerifyCommand) Execute(args []string) error {
+	fmt.Printf("Verify\n")
+
+	sourceFile := x.Infile.DbPrefix
+
+	fmt.Printf(" sourceFile             : %s\n", sourceFile)
+
+	ib := ibrowser.NewIBrowser(Parameters{})
+
+	hasChecksum, errs := ib.Verify(sourceFile)
+
+	if !hasChecksum {
+		fmt.Println("database file has no checksum")
+	}
+
+	for _, err := range errs {
+		fmt.Println("ERROR", err)
+	}
+
+	if len(errs) != 0 {
+		fmt.Printf("%d errors\n", len(errs))
+		os.Exit(1)
+	}
+
+	fmt.Println("OK")
+
+	return nil
+}
+
+func init() {
+	parser.AddCommand("verify",
+		"Verify database",
+		"Check the checksums of a database and of the matrix of every block",
+		&verifyCommand)
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"fmt"
+	"math"
 	// "log"
 	log "github.com/sirupsen/logrus"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 import (
@@ -16,6 +20,8 @@ type WebCommand struct {
 	Port        int    `long:"port" description:"Port" default:"8000"`
 	DatabaseDir string `long:"DatabaseDir" description:"Databases folder" default:"res/"`
 	HttpDir     string `long:"HttpDir" description:"Web page folder to be served folder" default:"http/"`
+	MaxMemory   string `long:"maxMemory" description:"Memory budget for loaded databases and block matrices. Eg: 512M, 4G. 0 for no limit" default:"0"`
+	Watch       int    `long:"watch" description:"Poll DatabaseDir every N seconds for new, changed and deleted databases. 0 to disable" default:"0"`
 	Verbose     []bool `short:"v" long:"verbose" description:"Show verbose debug information"`
 	verbosity   int
 }
@@ -25,6 +31,8 @@ func (w WebCommand) String() (res string) {
 	res += fmt.Sprintf(" Port                   : %d\n", w.Port)
 	res += fmt.Sprintf(" DatabaseDir            : %s\n", w.DatabaseDir)
 	res += fmt.Sprintf(" HttpDir                : %s\n", w.HttpDir)
+	res += fmt.Sprintf(" MaxMemory              : %s\n", w.MaxMemory)
+	res += fmt.Sprintf(" Watch                  : %d\n", w.Watch)
 	return res
 }
 
@@ -72,11 +80,52 @@ func (x *WebCommand) Execute(args []string) error {
 		log.Fatal("input folder ", x.DatabaseDir, " is not a folder")
 	}
 
-	web.NewWeb(x.DatabaseDir, x.HttpDir, x.Host, x.Port, verbosityLevel)
+	maxMemory := processMemory(x.MaxMemory)
+
+	if x.Watch < 0 {
+		fmt.Println("invalid watch interval:", x.Watch)
+		os.Exit(1)
+	}
+
+	watch := time.Duration(x.Watch) * time.Second
+
+	web.NewWeb(x.DatabaseDir, x.HttpDir, x.Host, x.Port, maxMemory, watch, verbosityLevel)
 
 	return nil
 }
 
+func processMemory(memoryStr string) (memory uint64) {
+	valueStr := strings.ToUpper(strings.TrimSpace(memoryStr))
+	valueStr = strings.TrimSuffix(valueStr, "B")
+
+	multiplier := uint64(1)
+
+	// at most one unit
+	if len(valueStr) > 0 {
+		switch valueStr[len(valueStr)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+
+		if multiplier != 1 {
+			valueStr = valueStr[:len(valueStr)-1]
+		}
+	}
+
+	memory, err := strconv.ParseUint(valueStr, 10, 64)
+
+	if err != nil || memory > math.MaxUint64/multiplier {
+		fmt.Println("invalid memory:", memoryStr)
+		os.Exit(1)
+	}
+
+	return memory * multiplier
+}
+
 func init() {
 	parser.AddCommand(
 		"web",
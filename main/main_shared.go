@@ -9,14 +9,16 @@ import (
 )
 
 import (
+	"github.com/sauloalgolang/introgressionbrowser/ibrowser"
 	"github.com/sauloalgolang/introgressionbrowser/save"
 )
 
 type SaveLoadOptions struct {
 	NoCheck     bool   `long:"check" description:"Check for self consistency"`
-	Compression string `long:"compression" description:"Compression format: none, snappy, gzip" choice:"none" choice:"snappy" choice:"gzip" default:"none"`
-	Format      string `long:"format" description:"File format: yaml" choice:"yaml" default:"yaml"`
+	Compression string `long:"compression" description:"Compression format: none, snappy, gzip, zstd" choice:"none" choice:"snappy" choice:"gzip" choice:"zstd" default:"none"`
+	Format      string `long:"format" description:"File format: yaml, gob, json, msgpack" choice:"yaml" choice:"gob" choice:"json" choice:"msgpack" default:"yaml"`
 	NumThreads  int    `long:"threads" description:"Number of threads" default:"4"`
+	Container   bool   `long:"container" description:"Save database as a single container file"`
 }
 
 func (s SaveLoadOptions) String() (res string) {
@@ -25,6 +27,7 @@ func (s SaveLoadOptions) String() (res string) {
 	res += fmt.Sprintf(" Compression            : %s\n", s.Compression)
 	res += fmt.Sprintf(" Format                 : %s\n", s.Format)
 	res += fmt.Sprintf(" NumThreads             : %d\n", s.NumThreads)
+	res += fmt.Sprintf(" Container              : %#v\n", s.Container)
 	return res
 }
 
@@ -125,6 +128,15 @@ func processArgs(args []string) (sourceFile string) {
 	return sourceFile
 }
 
+// saveDatabase saves ib to outfile, as a container if requested
+func saveDatabase(ib *ibrowser.IBrowser, outfile string, opts SaveLoadOptions) error {
+	if opts.Container {
+		return ib.SaveContainer(outfile, opts.Format, opts.Compression)
+	}
+
+	return ib.Save(outfile, opts.Format, opts.Compression)
+}
+
 func processSaveLoadParameters(parameters *Parameters, saveLoadOptions SaveLoadOptions) {
 	parameters.Compression = saveLoadOptions.Compression
 	parameters.Format = saveLoadOptions.Format
@@ -15,28 +15,31 @@ import (
 
 import "github.com/sauloalgolang/introgressionbrowser/interfaces"
 
-func OpenFile(sourceFile string, isTar bool, isGz bool, callBackParameters interfaces.CallBackParameters, callBack interfaces.VCFMaskedReaderType) {
+// OpenFile calls callBack with the contents of sourceFile, or of each file
+// in it if it is a tar file. The first error of callBack is returned.
+func OpenFile(sourceFile string, isTar bool, isGz bool, callBackParameters interfaces.CallBackParameters, callBack interfaces.VCFMaskedReaderType) error {
 	f, err := os.Open(sourceFile)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", interfaces.ErrNotFound, err)
+		}
+		return err
 	}
 	defer f.Close()
 
 	if !isTar && !isGz {
-		callBack(io.Reader(f), callBackParameters)
+		return callBack(io.Reader(f), callBackParameters)
 	} else {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 
 		gzReader, err := gzip.NewReaderN(f, 2500000, 32)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			return fmt.Errorf("%w: %s: %s", interfaces.ErrCorrupt, sourceFile, err)
 		}
 		defer gzReader.Close()
 
 		if !isTar {
-			callBack(gzReader, callBackParameters)
+			return callBack(gzReader, callBackParameters)
 		} else {
 			tarReader := tar.NewReader(gzReader)
 
@@ -49,8 +52,7 @@ func OpenFile(sourceFile string, isTar bool, isGz bool, callBackParameters inter
 				}
 
 				if err != nil {
-					fmt.Println(err)
-					os.Exit(1)
+					return fmt.Errorf("%w: %s: %s", interfaces.ErrCorrupt, sourceFile, err)
 				}
 
 				name := header.Name
@@ -60,7 +62,9 @@ func OpenFile(sourceFile string, isTar bool, isGz bool, callBackParameters inter
 					continue
 				case tar.TypeReg:
 					fmt.Println("(", i, ")", "Name: ", name)
-					callBack(tarReader, callBackParameters)
+					if err := callBack(tarReader, callBackParameters); err != nil {
+						return err
+					}
 				default:
 					fmt.Printf("%s : %c %s %s\n",
 						"Yikes! Unable to figure out type",
@@ -74,4 +78,6 @@ func OpenFile(sourceFile string, isTar bool, isGz bool, callBackParameters inter
 			}
 		}
 	}
+
+	return nil
 }
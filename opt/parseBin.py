@@ -1,5 +1,6 @@
 #!/usr/bin/env python3
 
+import io
 import os
 import sys
 
@@ -12,7 +13,168 @@ np.core.arrayprint._line_width = 80
 fname = "res/output_360_merged_2.50.vcf.gz_summary.bin"
 # fname = "res/output_360_merged_2.50.vcf.gz_chromosomes.bin"
 
+VARINT_COUNTER_BITS = 1
+
+MULTI_ARRAY_MAGIC        = b"IBMATRIX"
+MULTI_ARRAY_VERSION      = 1
+MULTI_ARRAY_ENDIANNESS   = 0x01020304
+MULTI_ARRAY_HEADER_SIZE  = 64
+MULTI_ARRAY_TRAILER_SIZE = 64
+
+HEADER_TYPE = np.dtype([
+    ('magic'      , 'S8'     ),
+    ('version'    , np.uint32),
+    ('endianness' , np.uint32),
+    ('counterBits', np.int64 ),
+    ('dimension'  , np.uint64),
+    ('dataLen'    , np.uint64),
+    ('compressor' , 'S8'     ),
+    ('reserved'   , 'V16'    )
+])
+
+TRAILER_TYPE = np.dtype([
+    ('tablePosition', np.uint64),
+    ('numRegisters' , np.uint64),
+    ('chunkPosition', np.uint64),
+    ('numChunks'    , np.uint64),
+    ('dataSize'     , np.uint64),
+    ('checksum'     , np.uint32),
+    ('tableChecksum', np.uint32),
+    ('reserved'     , 'V8'     ),
+    ('magic'        , 'S8'     )
+])
+
+DATA_FORMATS = {
+     8: (np.uint8 , 1),
+    16: (np.uint16, 2),
+    32: (np.uint32, 4),
+    64: (np.uint64, 8)
+}
+
+def registerType(counterBits, dataLen):
+    if counterBits not in DATA_FORMATS:
+        print("unknown counter bits", counterBits)
+        sys.exit(1)
+
+    dataFmt, dataFmtLen = DATA_FORMATS[counterBits]
+
+    dataSize     = dataLen * dataFmtLen
+    registerSize = 1 + 8 + 8 + 8 + 8 + dataSize
+
+    dt = np.dtype([
+        ('hasData'    , bool     ), #1  1
+        ('serial'     , np.int64 ), #8  9
+        ('counterBits', np.int64 ), #8 17
+        ('dataLen'    , np.int64 ), #8 25
+        ('sumData'    , np.uint64), #8 33
+        ('data', dataFmt, dataLen)
+    ])
+
+    return dt, registerSize
+
+def readLayout(infile):
+    """
+    Dumps start with a header and end with the chunk table of compressed
+    dumps, the register table and a trailer. Returns the position and size
+    of the registers, the compressor and the position and number of chunks.
+    Headerless dumps are plain.
+    """
+    fileSize = os.stat(infile).st_size
+
+    with open(infile, 'rb') as fhd:
+        if fileSize >= MULTI_ARRAY_HEADER_SIZE + MULTI_ARRAY_TRAILER_SIZE and fhd.read(8) == MULTI_ARRAY_MAGIC:
+            fhd.seek(0)
+            header = readStruct(fhd, HEADER_TYPE, 1)[0]
+
+            fhd.seek(fileSize - MULTI_ARRAY_TRAILER_SIZE)
+            trailer = readStruct(fhd, TRAILER_TYPE, 1)[0]
+
+            if header["endianness"] != MULTI_ARRAY_ENDIANNESS or header["version"] > MULTI_ARRAY_VERSION:
+                print("unsupported dump", header)
+                sys.exit(1)
+
+            if trailer["magic"] != MULTI_ARRAY_MAGIC:
+                print("truncated dump", infile)
+                sys.exit(1)
+
+            compressor = header["compressor"].rstrip(b"\0").decode() or "none"
+
+            return MULTI_ARRAY_HEADER_SIZE, int(trailer["dataSize"]), compressor, int(trailer["chunkPosition"]), int(trailer["numChunks"])
+
+        return 0, fileSize, "none", 0, 0
+
+def readChunked(infile, compressor, indexPosition, numChunks):
+    """
+    Compressed dumps hold the registers in chunks compressed on their own.
+    Returns the uncompressed registers.
+    """
+    with open(infile, 'rb') as fhd:
+        if compressor == "zstd":
+            import zstandard
+            decompress = lambda d: zstandard.ZstdDecompressor().decompressobj().decompress(d)
+        elif compressor == "gzip":
+            import gzip
+            decompress = gzip.decompress
+        elif compressor == "snappy":
+            import snappy
+            decompress = lambda d: snappy.StreamDecompressor().decompress(d)
+        else:
+            print("unknown compressor", compressor)
+            sys.exit(1)
+
+        fhd.seek(indexPosition)
+        index = np.frombuffer(fhd.read(numChunks * 32), dtype=np.uint64).reshape((numChunks, 4))
+
+        data = bytearray()
+        for position, size, dataPosition, dataSize in index:
+            fhd.seek(int(position))
+            chunk = decompress(fhd.read(int(size)))
+            assert len(data) == dataPosition and len(chunk) == dataSize
+            data += chunk
+
+    return bytes(data)
+
+def readStruct(fhd, dtype, count):
+    return np.frombuffer(fhd.read(dtype.itemsize * count), dtype=dtype, count=count)
+
+def readVarintRegister(fhd, header):
+    """
+    Varint registers hold the zigzag varint of the difference of each
+    counter to the previous one, preceded by the number of bytes.
+    """
+    dataBytes = int(readStruct(fhd, np.dtype(np.int64), 1)[0])
+    raw       = fhd.read(dataBytes)
+
+    data  = np.zeros(int(header["dataLen"]), dtype=np.uint64)
+    prev  = 0
+    pos   = 0
+    for i in range(len(data)):
+        value = 0
+        shift = 0
+        while True:
+            b      = raw[pos]
+            pos   += 1
+            value |= (b & 0x7f) << shift
+            shift += 7
+            if b < 0x80:
+                break
+        delta   = (value >> 1) ^ -(value & 1)
+        prev    = (prev + delta) & 0xffffffffffffffff
+        data[i] = prev
+
+    register = {k: header[k] for k in header.dtype.names}
+    register['data'] = data
+
+    return register, 1 + 8 + 8 + 8 + 8 + 8 + dataBytes
+
 def readIbrowserBinary(infile):
+    """
+    Registers are saved independently as 8, 16, 32 or 64 bits or as delta
+    varints, whichever is smaller, so each one can have its own encoding.
+    Files with a single fixed width are returned as a memmap, mixed files
+    as a list of one element memmaps and decoded varint registers.
+    Compressed dumps are read in memory instead of mapped.
+    """
     dt0 = np.dtype([
         ('hasData'    , bool    ), 
         ('serial'     , np.int64),
@@ -21,47 +183,49 @@ def readIbrowserBinary(infile):
         ('sumData'    , np.uint64)
     ])
 
-    fileSize = os.stat(infile).st_size
-    
-    with open(infile, 'rb') as fhd:
-        d = np.fromfile(fhd, dtype=dt0, count=1)[0]
-
-        counterBits = d["counterBits"]
-        dataLen = d["dataLen"]
-
-        dataFmt = None
-        dataFmtLen = None
-        
-        if counterBits == 16:
-            dataFmt = np.uint16
-            dataFmtLen = 2
-        elif counterBits == 32:
-            dataFmt = np.uint32
-            dataFmtLen = 4
-        elif counterBits == 64:
-            dataFmt = np.uint64
-            dataFmtLen = 8
-        else:
-            print("unknown counter bits", counterBits)
-            sys.exit(1)
+    dataPosition, dataSize, compressor, indexPosition, numChunks = readLayout(infile)
+
+    if compressor == "none":
+        fileSize = dataSize
+        opener   = lambda: open(infile, 'rb')
+        mapper   = lambda dt, offset, count: np.memmap(infile, dtype=dt, mode='r', offset=dataPosition + offset, shape=(count,))
+    else:
+        chunked      = readChunked(infile, compressor, indexPosition, numChunks)
+        dataPosition = 0
+        fileSize     = len(chunked)
+        opener       = lambda: io.BytesIO(chunked)
+        mapper       = lambda dt, offset, count: np.frombuffer(chunked, dtype=dt, offset=offset, count=count)
+
+    registers = []
+    offset    = 0
+
+    with opener() as fhd:
+        while offset < fileSize:
+            fhd.seek(dataPosition + offset)
+            d = readStruct(fhd, dt0, 1)[0]
+
+            if not d["hasData"]:
+                break
+
+            if d["counterBits"] == VARINT_COUNTER_BITS:
+                register, registerSize = readVarintRegister(fhd, d)
+                registers.append((offset, register))
+            else:
+                dt, registerSize = registerType(int(d["counterBits"]), int(d["dataLen"]))
+                registers.append((offset, dt))
+
+            offset += registerSize
+
+    numRegisters = len(registers)
+
+    widths = set(dt if isinstance(dt, np.dtype) else VARINT_COUNTER_BITS for _, dt in registers)
+
+    if len(widths) <= 1 and VARINT_COUNTER_BITS not in widths:
+        dt = registers[0][1] if registers else dt0
+        memmap = mapper(dt, 0, numRegisters)
+        return numRegisters, memmap
 
-        dataSize     = dataLen * dataFmtLen
-        registerSize = 1 + 8 + 8 + 8 + 8 + dataSize
-
-        dt = np.dtype([
-            ('hasData'    , bool     ), #1  1
-            ('serial'     , np.int64 ), #8  9
-            ('counterBits', np.int64 ), #8 17
-            ('dataLen'    , np.int64 ), #8 25
-            ('sumData'    , np.uint64), #8 33
-            ('data', dataFmt, dataLen)
-        ])
-
-        assert (fileSize % registerSize) == 0, "wrong file size: fileSize {} % registerSize {} != 0. {}".format(fileSize, registerSize, fileSize % registerSize)
-        
-        numRegisters = int(fileSize / registerSize) - 1
-    
-    memmap = np.memmap(infile, dtype=dt, mode='r')
+    memmap = [mapper(dt, offset, 1)[0] if isinstance(dt, np.dtype) else dt for offset, dt in registers]
 
     return numRegisters, memmap
 
@@ -0,0 +1,266 @@
+package save
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//
+// Atomic save
+//
+
+// Databases are saved into a temporary folder next to their prefix and moved
+// into place once complete. The files of the database being replaced are moved
+// aside into another folder while the new ones are moved in, and moved back if
+// that fails. The completion marker is written last.
+
+const COMPLETE_EXTENSION = "complete"
+const TEMP_DIR_SUFFIX = ".saving"
+const REPLACED_DIR_SUFFIX = ".replaced"
+
+func CompleteFileName(fileName string) string {
+	return fileName + "." + COMPLETE_EXTENSION
+}
+
+// TempDir returns the folder outPrefix is saved to before being moved into place
+func TempDir(outPrefix string) string {
+	return filepath.Join(filepath.Dir(outPrefix), "."+filepath.Base(outPrefix)+TEMP_DIR_SUFFIX)
+}
+
+// ReplacedDir returns the folder the files replaced by a save of outPrefix are
+// moved to until the save is complete
+func ReplacedDir(outPrefix string) string {
+	return filepath.Join(filepath.Dir(outPrefix), "."+filepath.Base(outPrefix)+REPLACED_DIR_SUFFIX)
+}
+
+// IsTempDir tells whether path is a folder used while saving a database
+func IsTempDir(path string) bool {
+	base := filepath.Base(path)
+	return strings.HasPrefix(base, ".") && (strings.HasSuffix(base, TEMP_DIR_SUFFIX) || strings.HasSuffix(base, REPLACED_DIR_SUFFIX))
+}
+
+// TempPrefix returns the prefix outPrefix is saved to in its temporary folder
+func TempPrefix(outPrefix string) string {
+	return filepath.Join(TempDir(outPrefix), filepath.Base(outPrefix))
+}
+
+// NewTempPrefix creates an empty temporary folder for outPrefix and returns
+// the prefix to save to. Leftovers of interrupted saves are removed.
+func NewTempPrefix(outPrefix string) (string, error) {
+	dir := TempDir(outPrefix)
+
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+
+	return TempPrefix(outPrefix), nil
+}
+
+// HasTempPrefix tells whether an interrupted save of outPrefix left its
+// temporary folder behind
+func HasTempPrefix(outPrefix string) bool {
+	fi, err := os.Stat(TempDir(outPrefix))
+	return err == nil && fi.IsDir()
+}
+
+// RemoveTempPrefix removes the temporary folder of a failed save
+func RemoveTempPrefix(outPrefix string) error {
+	return os.RemoveAll(TempDir(outPrefix))
+}
+
+// CommitTempPrefix moves fileNames, the files of the database saved to the
+// temporary prefix of outPrefix, into place. fileName, the database file, and
+// its checksum are moved last. The files they replace and oldFiles, the files
+// of the database being replaced, are moved aside first and removed once the
+// completion marker is written, followed by the temporary folder and whatever
+// else, as checkpoints, was saved to it. If moving fails the replaced database
+// is moved back. The database has no completion marker while its files are replaced.
+func CommitTempPrefix(outPrefix string, fileName string, fileNames []string, oldFiles []string) error {
+	dir := TempDir(outPrefix)
+	replaced := ReplacedDir(outPrefix)
+	dest := filepath.Dir(outPrefix)
+	base := filepath.Base(fileName)
+	last := []string{base, filepath.Base(ChecksumFileName(fileName))}
+
+	entries, err := tempFiles(dir, fileNames)
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := syncFile(filepath.Join(dir, entry)); err != nil {
+			return err
+		}
+	}
+
+	// leftovers of an interrupted commit belong to a database being replaced
+	if err := os.RemoveAll(replaced); err != nil {
+		return err
+	}
+
+	if err := os.Mkdir(replaced, 0755); err != nil {
+		return err
+	}
+
+	// the completion markers are moved aside first
+	names := []string{filepath.Base(CompleteFileName(fileName))}
+	others := []string{}
+	aside := []string{}
+
+	for _, oldFile := range oldFiles {
+		if strings.HasSuffix(oldFile, "."+COMPLETE_EXTENSION) {
+			names = append(names, filepath.Base(oldFile))
+		} else {
+			others = append(others, filepath.Base(oldFile))
+		}
+	}
+
+	others = append(others, entries...)
+
+	names = append(names, others...)
+
+	moved := make(map[string]bool, len(names))
+
+	for _, name := range names {
+		if !moved[name] {
+			moved[name] = true
+			aside = append(aside, name)
+		}
+	}
+
+	aside, err = moveFiles(aside, dest, replaced)
+
+	if err != nil {
+		return restoreFiles(err, nil, dir, dest, aside, replaced)
+	}
+
+	first := []string{}
+
+	for _, entry := range entries {
+		if entry != last[0] && entry != last[1] {
+			first = append(first, entry)
+		}
+	}
+
+	movedIn, err := moveFiles(append(first, last...), dir, dest)
+
+	if err != nil {
+		return restoreFiles(err, movedIn, dir, dest, aside, replaced)
+	}
+
+	// renames are durable before the marker
+	if err := syncFile(dest); err != nil {
+		return restoreFiles(err, movedIn, dir, dest, aside, replaced)
+	}
+
+	marker := time.Now().Format(time.RFC3339) + "  " + base + "\n"
+
+	if err := ioutil.WriteFile(CompleteFileName(fileName), []byte(marker), 0644); err != nil {
+		os.Remove(CompleteFileName(fileName))
+		return restoreFiles(err, movedIn, dir, dest, aside, replaced)
+	}
+
+	if err := syncFile(CompleteFileName(fileName)); err != nil {
+		os.Remove(CompleteFileName(fileName))
+		return restoreFiles(err, movedIn, dir, dest, aside, replaced)
+	}
+
+	if err := os.RemoveAll(replaced); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// tempFiles returns the base names of fileNames saved to the temporary folder dir
+func tempFiles(dir string, fileNames []string) (names []string, err error) {
+	names = make([]string, 0, len(fileNames))
+
+	for _, fileName := range fileNames {
+		name := filepath.Base(fileName)
+
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// rename moves a file. Tests replace it to make moves fail
+var rename = os.Rename
+
+// moveFiles renames names from one folder to another, skipping missing files,
+// and returns the names moved
+func moveFiles(names []string, from string, to string) (moved []string, err error) {
+	moved = make([]string, 0, len(names))
+
+	for _, name := range names {
+		if err := rename(filepath.Join(from, name), filepath.Join(to, name)); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return moved, err
+		}
+
+		moved = append(moved, name)
+	}
+
+	return moved, nil
+}
+
+// restoreFiles moves the files of a failed commit back to the temporary folder
+// and the replaced files back into place. Returns err.
+func restoreFiles(err error, movedIn []string, dir string, dest string, aside []string, replaced string) error {
+	if _, rerr := moveFiles(movedIn, dest, dir); rerr != nil {
+		return fmt.Errorf("%w. restoring %s failed: %v", err, dir, rerr)
+	}
+
+	if _, rerr := moveFiles(aside, replaced, dest); rerr != nil {
+		return fmt.Errorf("%w. restoring the replaced files from %s failed: %v", err, replaced, rerr)
+	}
+
+	os.Remove(replaced)
+
+	return err
+}
+
+// IsComplete returns false for databases being saved or whose save was
+// interrupted. Databases saved before completion markers are complete.
+func IsComplete(fileName string, outPrefix string) bool {
+	if _, err := os.Stat(CompleteFileName(fileName)); err == nil {
+		return true
+	}
+
+	_, err := os.Stat(TempDir(outPrefix))
+
+	return os.IsNotExist(err)
+}
+
+func syncFile(fileName string) error {
+	file, err := os.Open(fileName)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return file.Sync()
+}
@@ -0,0 +1,213 @@
+package save
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+//
+// Helpers
+//
+
+var errTestRename = errors.New("rename failed")
+
+// writeTestFiles writes files, name to content, to dir
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkTestFiles checks the files in dir are exactly files, name to content
+func checkTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	want := []string{}
+	for name := range files {
+		want = append(want, name)
+	}
+
+	sort.Strings(want)
+
+	if len(names) != len(want) {
+		t.Fatalf("%s holds %v, want %v", dir, names, want)
+	}
+
+	for i, name := range want {
+		if names[i] != name {
+			t.Fatalf("%s holds %v, want %v", dir, names, want)
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(content) != files[name] {
+			t.Errorf("%s: %q, want %q", name, content, files[name])
+		}
+	}
+}
+
+// failRename makes moving name out of dir fail until the returned function is called
+func failRename(dir string, name string) func() {
+	rename = func(oldpath string, newpath string) error {
+		if oldpath == filepath.Join(dir, name) {
+			return errTestRename
+		}
+		return os.Rename(oldpath, newpath)
+	}
+
+	return func() { rename = os.Rename }
+}
+
+//
+// CommitTempPrefix
+//
+
+var oldTestDatabase = map[string]string{
+	"db.yaml":          "old database",
+	"db.yaml.crc32c":   "old checksum",
+	"db.yaml.complete": "old marker",
+	"db_ch01.bin":      "old ch01",
+	"db_ch02.bin":      "old ch02",
+}
+
+var newTestDatabase = map[string]string{
+	"db.yaml":        "new database",
+	"db.yaml.crc32c": "new checksum",
+	"db_ch01.bin":    "new ch01",
+}
+
+// newTestCommit saves the old database to a folder and the new one to the
+// temporary prefix. Returns the prefix and the arguments of CommitTempPrefix.
+func newTestCommit(t *testing.T) (outPrefix string, fileName string, fileNames []string, oldFiles []string) {
+	dest := t.TempDir()
+	outPrefix = filepath.Join(dest, "db")
+	fileName = outPrefix + ".yaml"
+
+	writeTestFiles(t, dest, oldTestDatabase)
+
+	for name := range oldTestDatabase {
+		oldFiles = append(oldFiles, filepath.Join(dest, name))
+	}
+
+	tempPrefix, err := NewTempPrefix(outPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFiles(t, TempDir(outPrefix), newTestDatabase)
+	writeTestFiles(t, TempDir(outPrefix), map[string]string{"db_checkpoint.yaml": "checkpoint"})
+
+	for name := range newTestDatabase {
+		fileNames = append(fileNames, filepath.Join(filepath.Dir(tempPrefix), name))
+	}
+
+	return outPrefix, fileName, fileNames, oldFiles
+}
+
+func TestCommitTempPrefix(t *testing.T) {
+	outPrefix, fileName, fileNames, oldFiles := newTestCommit(t)
+
+	if err := CommitTempPrefix(outPrefix, fileName, fileNames, oldFiles); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(CompleteFileName(fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"db.yaml.complete": string(content)}
+	for name, content := range newTestDatabase {
+		want[name] = content
+	}
+
+	checkTestFiles(t, filepath.Dir(outPrefix), want)
+
+	if HasTempPrefix(outPrefix) {
+		t.Error("temporary folder left behind")
+	}
+
+	if _, err := os.Stat(ReplacedDir(outPrefix)); !os.IsNotExist(err) {
+		t.Errorf("replaced folder left behind: %v", err)
+	}
+
+	if !IsComplete(fileName, outPrefix) {
+		t.Error("committed database is not complete")
+	}
+}
+
+func TestCommitTempPrefixRestores(t *testing.T) {
+	tests := []struct {
+		name   string
+		inTemp bool
+		file   string
+	}{
+		{"moving an old file aside", false, "db_ch02.bin"},
+		{"moving a replaced file aside", false, "db_ch01.bin"},
+		{"moving a new file in", true, "db_ch01.bin"},
+		{"moving the new database in", true, "db.yaml"},
+		{"moving the new checksum in", true, "db.yaml.crc32c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outPrefix, fileName, fileNames, oldFiles := newTestCommit(t)
+			dest := filepath.Dir(outPrefix)
+
+			from := dest
+			if tt.inTemp {
+				from = TempDir(outPrefix)
+			}
+
+			restore := failRename(from, tt.file)
+			err := CommitTempPrefix(outPrefix, fileName, fileNames, oldFiles)
+			restore()
+
+			if !errors.Is(err, errTestRename) {
+				t.Fatalf("error %v, want %v", err, errTestRename)
+			}
+
+			// the old database is back in place with its marker
+			checkTestFiles(t, dest, oldTestDatabase)
+
+			if !IsComplete(fileName, outPrefix) {
+				t.Error("restored database is not complete")
+			}
+
+			// the new database is kept to be committed again
+			want := map[string]string{"db_checkpoint.yaml": "checkpoint"}
+			for name, content := range newTestDatabase {
+				want[name] = content
+			}
+
+			checkTestFiles(t, TempDir(outPrefix), want)
+
+			if _, err := os.Stat(ReplacedDir(outPrefix)); !os.IsNotExist(err) {
+				t.Errorf("replaced folder left behind: %v", err)
+			}
+		})
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
 	"log"
 	"math"
 	"os"
 	// "io/ioutil"
-	// "fmt"
 )
 
 // https://golang.org/pkg/encoding/binary/
@@ -18,6 +21,11 @@ import (
 // MultiArrayFile
 //
 
+// VARINT_COUNTER_BITS marks registers stored as zigzag varints of the
+// difference to the previous counter instead of fixed width counters.
+// They are followed by the number of bytes of the encoded data.
+const VARINT_COUNTER_BITS = int64(1)
+
 type MultiArrayFile struct {
 	fileName    string
 	endianness  binary.ByteOrder
@@ -25,53 +33,145 @@ type MultiArrayFile struct {
 	serial      int64
 	counterBits int64
 	dataLen     int64
+	offset      uint64
+	lastOffset  uint64
+	lastSize    uint64
+	lastBits    int64
 	isFinished  bool
+	isClosed    bool
 	writeMode   bool
 	bufReader   *bufio.Reader
 	bufWriter   *bufio.Writer
 	file        *os.File
+	chunked     *chunkedWriter
+	writer      io.Writer
+	reader      io.Reader
+	checksum    *checksumWriter
+	header      []byte
+	dimension   uint64
+	headerBits  int64
+	minBits     int
+	lastSerial  int64
+	hasLast     bool
+	registers   []RegisterInfo
+	numRead     int
+	layout      *MultiArrayLayout
+	err         error
 }
 
-func NewMultiArrayFile(fileName string, mode string) *MultiArrayFile {
-	m := MultiArrayFile{
-		fileName:    fileName,
-		endianness:  binary.LittleEndian,
-		buf:         new(bytes.Buffer),
-		serial:      0,
-		counterBits: 0,
-		dataLen:     0,
-		isFinished:  false,
-	}
+func NewMultiArrayFile(fileName string, mode string) (*MultiArrayFile, error) {
+	return OpenMultiArrayFile(fileName, mode, "none")
+}
+
+// OpenMultiArrayFile writes the registers in compressed chunks unless
+// compressor is none. Readers detect compressed dumps on their own.
+// Errors while writing or reading are kept by the file, see Err.
+func OpenMultiArrayFile(fileName string, mode string, compressor string) (*MultiArrayFile, error) {
+	m := newMultiArrayFile(fileName)
 
 	if mode == "w" {
 		log.Println("Saving binary matrix to", fileName)
 
+		newWriter, err := GetCompressInterfaceWriter(compressor)
+		if err != nil {
+			return nil, err
+		}
+
 		file, err := os.Create(fileName)
 		if err != nil {
-			log.Fatalln(err)
+			return nil, err
 		}
 
 		m.writeMode = true
 		m.bufWriter = bufio.NewWriter(file)
 		m.file = file
+		m.checksum = &checksumWriter{}
+		m.registers = make([]RegisterInfo, 0, 100)
+
+		if compressor != "none" {
+			m.chunked = newChunkedWriter(file, compressor, newWriter)
+			m.bufWriter = bufio.NewWriter(m.chunked)
+		}
+
+		m.writer = io.MultiWriter(m.bufWriter, m.checksum)
 
 	} else if mode == "r" {
 		log.Println("Loading binary matrix from", fileName)
 
 		file, err := os.Open(fileName)
 		if err != nil {
-			log.Fatalln(err)
+			return nil, notFoundError(err)
+		}
+
+		fi, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		if err := m.openReader(file, uint64(fi.Size())); err != nil {
+			file.Close()
+			return nil, err
 		}
 
-		m.writeMode = false
-		m.bufReader = bufio.NewReader(file)
 		m.file = file
 
 	} else {
-		log.Fatalf("invalid mode '%s'. wither w or r\n", mode)
+		return nil, fmt.Errorf("%w: mode '%s'. either w or r", ErrInvalid, mode)
 	}
 
-	return &m
+	return &m, nil
+}
+
+// OpenMultiArrayReader reads the dump of size bytes in reader,
+// such as a member of a container
+func OpenMultiArrayReader(fileName string, reader io.ReaderAt, size uint64) (*MultiArrayFile, error) {
+	log.Println("Loading binary matrix from", fileName)
+
+	m := newMultiArrayFile(fileName)
+
+	if err := m.openReader(reader, size); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func newMultiArrayFile(fileName string) MultiArrayFile {
+	return MultiArrayFile{
+		fileName:    fileName,
+		endianness:  binary.LittleEndian,
+		buf:         new(bytes.Buffer),
+		serial:      0,
+		counterBits: 0,
+		dataLen:     0,
+		offset:      0,
+		lastOffset:  0,
+		lastSize:    0,
+		lastBits:    0,
+		isFinished:  false,
+	}
+}
+
+func (m *MultiArrayFile) openReader(reader io.ReaderAt, size uint64) error {
+	layout, err := ReadMultiArrayLayout(reader, size)
+	if err != nil {
+		return fmt.Errorf("failed reading layout of %s: %w", m.fileName, err)
+	}
+
+	m.writeMode = false
+	m.layout = layout
+
+	if layout.IsChunked() {
+		m.bufReader = bufio.NewReader(newChunkedReader(reader, layout.Compressor, layout.Chunks))
+	} else {
+		m.bufReader = bufio.NewReader(io.NewSectionReader(reader, int64(layout.DataPosition), int64(layout.DataSize)))
+	}
+
+	m.checksum = &checksumWriter{}
+	m.reader = io.TeeReader(m.bufReader, m.checksum)
+
+	return nil
 }
 
 func (m *MultiArrayFile) SetSerial(serial int64) {
@@ -82,7 +182,58 @@ func (m *MultiArrayFile) GetSerial() (serial int64) {
 	return m.serial
 }
 
-func (m *MultiArrayFile) CalculateRegisterSize(counterBits int, size uint64) (res uint64) {
+func (m *MultiArrayFile) GetFileName() string {
+	return m.fileName
+}
+
+// SetDimension sets the number of samples and counter bits of the database
+// stored in the header. It must be called before the first register is written.
+func (m *MultiArrayFile) SetDimension(dimension uint64, counterBits int64) {
+	m.dimension = dimension
+	m.headerBits = counterBits
+}
+
+// SetMinCounterBits sets the narrowest counter width of the registers dumped
+// to the file. 0 lets each register use its smallest encoding.
+func (m *MultiArrayFile) SetMinCounterBits(counterBits int) {
+	m.minBits = counterBits
+}
+
+// GetMinCounterBits returns the narrowest counter width of the registers dumped to the file
+func (m *MultiArrayFile) GetMinCounterBits() int {
+	return m.minBits
+}
+
+// GetLayout returns the header, chunks and register table of a file being read
+func (m *MultiArrayFile) GetLayout() *MultiArrayLayout {
+	return m.layout
+}
+
+// Err returns the first error writing or reading the file.
+// Once failed, writes are ignored and reads return no data.
+func (m *MultiArrayFile) Err() error {
+	return m.err
+}
+
+func (m *MultiArrayFile) fail(err error) {
+	if m.err == nil {
+		m.err = fmt.Errorf("%s: %w", m.fileName, err)
+	}
+}
+
+// corrupt fails with ErrCorrupt
+func (m *MultiArrayFile) corrupt(format string, a ...interface{}) {
+	m.fail(fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, a...)))
+}
+
+// GetLastRegister returns the byte offset, size and counter bits of the last register written or read.
+// Registers have different sizes when their counters have different widths.
+func (m *MultiArrayFile) GetLastRegister() (offset uint64, size uint64, counterBits int64) {
+	return m.lastOffset, m.lastSize, m.lastBits
+}
+
+// CalculateRegisterSize returns the size of a register of size counters of counterBits
+func (m *MultiArrayFile) CalculateRegisterSize(counterBits int, size uint64) (res uint64, err error) {
 	res += 1 // hasData     bool
 	res += 8 // serial      int64
 	res += 8 // counterBits int64
@@ -91,6 +242,8 @@ func (m *MultiArrayFile) CalculateRegisterSize(counterBits int, size uint64) (re
 
 	dbytes := uint64(0)
 	switch counterBits {
+	case 8:
+		dbytes = 1
 	case 16:
 		dbytes = 2
 	case 32:
@@ -100,11 +253,31 @@ func (m *MultiArrayFile) CalculateRegisterSize(counterBits int, size uint64) (re
 	}
 
 	if dbytes == 0 {
-		panic("wrong counterbits")
+		return 0, fmt.Errorf("%w: counter bits %d. either 8, 16, 32 or 64", ErrInvalid, counterBits)
 	}
 
 	res += dbytes * size
 
+	return res, nil
+}
+
+// CalculateVarintRegisterSize returns the size of data as a varint register
+func (m *MultiArrayFile) CalculateVarintRegisterSize(data *[]uint64) (res uint64) {
+	res += 1 // hasData     bool
+	res += 8 // serial      int64
+	res += 8 // counterBits int64
+	res += 8 // dataLen     int64
+	res += 8 // sumData     uint64
+	res += 8 // dataBytes   int64
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	prev := uint64(0)
+
+	for _, v := range *data {
+		res += uint64(binary.PutVarint(buf, int64(v-prev)))
+		prev = v
+	}
+
 	return
 }
 
@@ -114,183 +287,412 @@ func (m *MultiArrayFile) CalculateRegisterSize(counterBits int, size uint64) (re
 
 func (m *MultiArrayFile) write() (serial int64) {
 	if !m.writeMode {
-		log.Fatalln("Trying to write to a reader")
+		m.fail(errors.New("trying to write to a reader"))
+	}
+
+	if m.err != nil {
+		return -1
 	}
 
+	m.writeHeader()
+	m.addRegister()
+	m.cutChunk()
+
+	m.checksum.register = 0
+
 	hasData := true
 
-	err := binary.Write(m.bufWriter, m.endianness, &hasData)
+	err := binary.Write(m.writer, m.endianness, &hasData)
 	if err != nil {
-		log.Fatalln("binary.Write failed to write hasData:", err)
+		m.fail(fmt.Errorf("binary.Write failed to write hasData: %w", err))
 	}
 
-	err = binary.Write(m.bufWriter, m.endianness, &m.serial)
+	err = binary.Write(m.writer, m.endianness, &m.serial)
 	if err != nil {
-		log.Fatalln("binary.Write failed to write serial:", err)
+		m.fail(fmt.Errorf("binary.Write failed to write serial: %w", err))
 	}
 
-	err = binary.Write(m.bufWriter, m.endianness, &m.counterBits)
+	err = binary.Write(m.writer, m.endianness, &m.counterBits)
 	if err != nil {
-		log.Fatalln("binary.Write failed to write counterBits:", err)
+		m.fail(fmt.Errorf("binary.Write failed to write counterBits: %w", err))
 	}
 
-	err = binary.Write(m.bufWriter, m.endianness, &m.dataLen)
+	err = binary.Write(m.writer, m.endianness, &m.dataLen)
 	if err != nil {
-		log.Fatalln("binary.Write failed to write dataLen:", err)
+		m.fail(fmt.Errorf("binary.Write failed to write dataLen: %w", err))
 	}
 
 	serial = m.serial
 
 	m.serial++
 
+	m.lastOffset = m.offset
+	m.lastBits = m.counterBits
+	m.lastSerial = serial
+	m.hasLast = true
+
+	// varint registers update their size once encoded
+	if m.counterBits != VARINT_COUNTER_BITS {
+		size, err := m.CalculateRegisterSize(int(m.counterBits), uint64(m.dataLen))
+
+		if err != nil {
+			m.fail(err)
+		}
+
+		m.lastSize = size
+		m.offset += m.lastSize
+	}
+
 	return serial
 }
 
-func (m *MultiArrayFile) Write16(data *[]uint16) (serial int64) {
-	if m.counterBits == 0 {
-		m.counterBits = 16
-	} else if m.counterBits != 16 {
-		log.Panicln("can't write different bits", m.counterBits, " != ", 16)
+func (m *MultiArrayFile) Write8(data *[]uint8) (serial int64) {
+	m.counterBits = 8
+
+	dataLen := int64(len(*data))
+
+	if m.dataLen == 0 {
+		m.dataLen = dataLen
+	} else if m.dataLen != dataLen {
+		m.fail(fmt.Errorf("can't write different sizes %d != %d", m.dataLen, dataLen))
+	}
+
+	serial = m.write()
+
+	if m.err != nil {
+		return serial
+	}
+
+	sumData := uint64(0)
+
+	for _, v := range *data {
+		sumData += uint64(v)
+	}
+
+	err1 := binary.Write(m.writer, m.endianness, &sumData)
+
+	if err1 != nil {
+		m.fail(fmt.Errorf("binary.Write failed to write data8 sum: %w", err1))
 	}
 
+	err2 := binary.Write(m.writer, m.endianness, data)
+
+	if err2 != nil {
+		m.fail(fmt.Errorf("binary.Write failed to write data8: %w", err2))
+	}
+
+	return serial
+}
+
+func (m *MultiArrayFile) Write16(data *[]uint16) (serial int64) {
+	m.counterBits = 16
+
 	dataLen := int64(len(*data))
 
 	if m.dataLen == 0 {
 		m.dataLen = dataLen
 	} else if m.dataLen != dataLen {
-		log.Panicln("can't write different sizes", m.dataLen, " != ", dataLen)
+		m.fail(fmt.Errorf("can't write different sizes %d != %d", m.dataLen, dataLen))
 	}
 
 	serial = m.write()
 
+	if m.err != nil {
+		return serial
+	}
+
 	ndata := make([]uint16, dataLen, dataLen)
 	sumData := uint64(0)
 
 	for i, v := range *data {
 		if int64(v) > int64(math.MaxInt16) {
-			log.Panicln("overflow")
+			m.fail(fmt.Errorf("overflow %d", v))
+			return serial
 		}
 
 		ndata[i] = uint16(v)
 		sumData += uint64(v)
 	}
 
-	err1 := binary.Write(m.bufWriter, m.endianness, &sumData)
+	err1 := binary.Write(m.writer, m.endianness, &sumData)
 
 	if err1 != nil {
-		log.Fatalln("binary.Write failed to write data16 sum:", err1)
+		m.fail(fmt.Errorf("binary.Write failed to write data16 sum: %w", err1))
 	}
 
-	err2 := binary.Write(m.bufWriter, m.endianness, &ndata)
+	err2 := binary.Write(m.writer, m.endianness, &ndata)
 
 	if err2 != nil {
-		log.Fatalln("binary.Write failed to write data16:", err2)
+		m.fail(fmt.Errorf("binary.Write failed to write data16: %w", err2))
 	}
 
 	return serial
 }
 
 func (m *MultiArrayFile) Write32(data *[]uint32) (serial int64) {
-	if m.counterBits == 0 {
-		m.counterBits = 32
-	} else if m.counterBits != 32 {
-		log.Panicln("can't write different bits", m.counterBits, " != ", 32)
-	}
+	m.counterBits = 32
 
 	dataLen := int64(len(*data))
 
 	if m.dataLen == 0 {
 		m.dataLen = dataLen
 	} else if m.dataLen != dataLen {
-		log.Panicln("can't write different sizes", m.dataLen, " != ", dataLen)
+		m.fail(fmt.Errorf("can't write different sizes %d != %d", m.dataLen, dataLen))
 	}
 
 	serial = m.write()
 
+	if m.err != nil {
+		return serial
+	}
+
 	ndata := make([]uint32, dataLen, dataLen)
 	sumData := uint64(0)
 
 	for i, v := range *data {
 		if int64(v) > int64(math.MaxInt32) {
-			log.Panicln("overflow")
+			m.fail(fmt.Errorf("overflow %d", v))
+			return serial
 		}
 
 		ndata[i] = uint32(v)
 		sumData += uint64(v)
 	}
 
-	err1 := binary.Write(m.bufWriter, m.endianness, &sumData)
+	err1 := binary.Write(m.writer, m.endianness, &sumData)
 
 	if err1 != nil {
-		log.Fatalln("binary.Write failed to write data32 sum:", err1)
+		m.fail(fmt.Errorf("binary.Write failed to write data32 sum: %w", err1))
 	}
 
-	err2 := binary.Write(m.bufWriter, m.endianness, &ndata)
+	err2 := binary.Write(m.writer, m.endianness, &ndata)
 
 	if err2 != nil {
-		log.Fatalln("binary.Write failed to write data32:", err2)
+		m.fail(fmt.Errorf("binary.Write failed to write data32: %w", err2))
 	}
 
 	return serial
 }
 
 func (m *MultiArrayFile) Write64(data *[]uint64) (serial int64) {
-	if m.counterBits == 0 {
-		m.counterBits = 64
-	} else if m.counterBits != 64 {
-		log.Panicln("can't write different bits", m.counterBits, " != ", 64)
-	}
+	m.counterBits = 64
 
 	dataLen := int64(len(*data))
 
 	if m.dataLen == 0 {
 		m.dataLen = dataLen
 	} else if m.dataLen != dataLen {
-		log.Panicln("can't write different sizes", m.dataLen, " != ", dataLen)
+		m.fail(fmt.Errorf("can't write different sizes %d != %d", m.dataLen, dataLen))
 	}
 
 	serial = m.write()
 
+	if m.err != nil {
+		return serial
+	}
+
 	ndata := make([]uint64, dataLen, dataLen)
 	sumData := uint64(0)
 
 	for i, v := range *data {
-		if int64(v) > int64(math.MaxInt64) {
-			log.Panicln("overflow")
-		}
-
 		ndata[i] = uint64(v)
 		sumData += uint64(v)
 	}
 
-	err1 := binary.Write(m.bufWriter, m.endianness, &sumData)
+	err1 := binary.Write(m.writer, m.endianness, &sumData)
+
+	if err1 != nil {
+		m.fail(fmt.Errorf("binary.Write failed to write data64 sum: %w", err1))
+	}
+
+	err2 := binary.Write(m.writer, m.endianness, &ndata)
+
+	if err2 != nil {
+		m.fail(fmt.Errorf("binary.Write failed to write data64: %w", err2))
+	}
+
+	return serial
+}
+
+func (m *MultiArrayFile) WriteVarint(data *[]uint64) (serial int64) {
+	m.counterBits = VARINT_COUNTER_BITS
+
+	dataLen := int64(len(*data))
+
+	if m.dataLen == 0 {
+		m.dataLen = dataLen
+	} else if m.dataLen != dataLen {
+		m.fail(fmt.Errorf("can't write different sizes %d != %d", m.dataLen, dataLen))
+	}
+
+	serial = m.write()
+
+	if m.err != nil {
+		return serial
+	}
+
+	ndata := make([]byte, 0, dataLen)
+	buf := make([]byte, binary.MaxVarintLen64)
+	sumData := uint64(0)
+	prev := uint64(0)
+
+	for _, v := range *data {
+		n := binary.PutVarint(buf, int64(v-prev))
+		ndata = append(ndata, buf[:n]...)
+		sumData += v
+		prev = v
+	}
+
+	dataBytes := int64(len(ndata))
+
+	err1 := binary.Write(m.writer, m.endianness, &sumData)
 
 	if err1 != nil {
-		log.Fatalln("binary.Write failed to write data64 sum:", err1)
+		m.fail(fmt.Errorf("binary.Write failed to write varint sum: %w", err1))
 	}
 
-	err2 := binary.Write(m.bufWriter, m.endianness, &ndata)
+	err2 := binary.Write(m.writer, m.endianness, &dataBytes)
 
 	if err2 != nil {
-		log.Fatalln("binary.Write failed to write data64:", err2)
+		m.fail(fmt.Errorf("binary.Write failed to write varint length: %w", err2))
 	}
 
+	_, err3 := m.writer.Write(ndata)
+
+	if err3 != nil {
+		m.fail(fmt.Errorf("binary.Write failed to write varint: %w", err3))
+	}
+
+	m.lastSize = 1 + 8 + 8 + 8 + 8 + 8 + uint64(dataBytes)
+	m.offset += m.lastSize
+
 	return serial
 }
 
+// cutChunk closes the compressed chunk between registers once it is large enough
+func (m *MultiArrayFile) cutChunk() {
+	if m.chunked == nil {
+		return
+	}
+
+	if err := m.bufWriter.Flush(); err != nil {
+		m.fail(fmt.Errorf("failed writing chunk: %w", err))
+	}
+
+	if m.err != nil || m.chunked.Buffered() < COMPRESSED_CHUNK_SIZE {
+		return
+	}
+
+	if err := m.chunked.Cut(); err != nil {
+		m.fail(fmt.Errorf("failed writing chunk: %w", err))
+	}
+}
+
+// writeHeader writes the header before the first register
+func (m *MultiArrayFile) writeHeader() {
+	if m.header != nil {
+		return
+	}
+
+	compressor := "none"
+	if m.chunked != nil {
+		compressor = m.chunked.compressor
+	}
+
+	dimension := m.dimension
+	if dimension == 0 {
+		dimension = DimensionFromDataLen(uint64(m.dataLen))
+	}
+
+	header := NewMultiArrayHeader(m.headerBits, dimension, uint64(m.dataLen), compressor)
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, m.endianness, &header)
+	m.header = buf.Bytes()
+
+	var err error
+
+	// chunks are positioned after the header
+	if m.chunked != nil {
+		_, err = m.file.Write(m.header)
+		m.chunked.position = uint64(len(m.header))
+	} else {
+		_, err = m.bufWriter.Write(m.header)
+	}
+
+	if err != nil {
+		m.fail(fmt.Errorf("binary.Write failed to write header: %w", err))
+	}
+}
+
+// addRegister adds the last register written to the register table
+func (m *MultiArrayFile) addRegister() {
+	if !m.hasLast {
+		return
+	}
+
+	m.registers = append(m.registers, RegisterInfo{
+		Offset:      m.lastOffset,
+		Size:        m.lastSize,
+		Serial:      m.lastSerial,
+		CounterBits: int32(m.lastBits),
+		Checksum:    m.checksum.register,
+	})
+
+	m.hasLast = false
+}
+
+// writeTrailer writes the chunk index, the register table and the trailer
+func (m *MultiArrayFile) writeTrailer() {
+	trailer := MultiArrayTrailer{
+		ChunkPosition: MULTI_ARRAY_HEADER_SIZE + m.checksum.size,
+		NumRegisters:  uint64(len(m.registers)),
+		DataSize:      m.checksum.size,
+		Checksum:      m.checksum.stream,
+	}
+
+	copy(trailer.Magic[:], MULTI_ARRAY_MAGIC)
+
+	buf := new(bytes.Buffer)
+
+	if m.chunked != nil {
+		trailer.ChunkPosition = m.chunked.position
+		trailer.NumChunks = uint64(len(m.chunked.chunks))
+		binary.Write(buf, m.endianness, m.chunked.chunks)
+	}
+
+	trailer.TablePosition = trailer.ChunkPosition + uint64(buf.Len())
+
+	binary.Write(buf, m.endianness, m.registers)
+
+	trailer.TableChecksum = crc32.Update(crc32.Checksum(m.header, crc32cTable), crc32cTable, buf.Bytes())
+
+	binary.Write(buf, m.endianness, &trailer)
+
+	if _, err := m.file.Write(buf.Bytes()); err != nil {
+		m.fail(fmt.Errorf("binary.Write failed to write register table: %w", err))
+	}
+}
+
 //
 // MultiArrayFile :: Reader
 //
 
 func (m *MultiArrayFile) read() (hasData bool, serial int64, counterBits int64, dataLen int64, sumData uint64) {
 	if m.writeMode {
-		log.Fatalln("Trying to read from a writer")
+		m.fail(errors.New("trying to read from a writer"))
+	} else if m.isFinished {
+		m.fail(errors.New("trying to read a finished file"))
 	}
 
-	if m.isFinished {
-		log.Fatalln("Trying to read a finished file")
+	m.checkRegister()
+
+	if m.err != nil {
+		return false, -1, 0, 0, 0
 	}
 
+	m.checksum.register = 0
+
 	hasData = false
 	serial = int64(0)
 	counterBits = int64(0)
@@ -300,10 +702,11 @@ func (m *MultiArrayFile) read() (hasData bool, serial int64, counterBits int64,
 	//
 	// HasData
 
-	err := binary.Read(m.bufReader, m.endianness, &hasData)
+	err := binary.Read(m.reader, m.endianness, &hasData)
 
 	if err != nil {
-		log.Fatalln("binary.Read failed reading hasData:", err)
+		m.corrupt("binary.Read failed reading hasData: %s", err)
+		return false, -1, 0, 0, 0
 	}
 
 	if !hasData {
@@ -313,79 +716,295 @@ func (m *MultiArrayFile) read() (hasData bool, serial int64, counterBits int64,
 
 	//
 	// Serial
-	err = binary.Read(m.bufReader, m.endianness, &serial)
+	err = binary.Read(m.reader, m.endianness, &serial)
 
 	if err != nil {
-		log.Fatalln("binary.Read failed reading serial:", err)
+		m.corrupt("binary.Read failed reading serial: %s", err)
+		return false, -1, 0, 0, 0
 	}
 
 	if serial < 0 {
-		log.Fatalln("serial < 0", serial)
+		m.corrupt("serial < 0: %d", serial)
+		return false, -1, 0, 0, 0
 	}
 
 	if serial != m.serial {
-		log.Fatalln("serial out of order", serial, " != ", m.serial)
+		m.corrupt("serial out of order %d != %d", serial, m.serial)
+		return false, -1, 0, 0, 0
 	}
 
 	//
 	// counterBits
-	err = binary.Read(m.bufReader, m.endianness, &counterBits)
+	err = binary.Read(m.reader, m.endianness, &counterBits)
 
 	if err != nil {
-		log.Fatalln("binary.Read failed reading counterBits:", err)
+		m.corrupt("binary.Read failed reading counterBits: %s", err)
+		return false, -1, 0, 0, 0
 	}
 
 	if counterBits <= 0 {
-		log.Fatalln("Length <= 0", counterBits)
-	} else if counterBits != 16 && counterBits != 32 && counterBits != 64 {
-		log.Fatalln("Length not 16, 32 or 64", counterBits)
+		m.corrupt("Length <= 0: %d", counterBits)
+		return false, -1, 0, 0, 0
+	} else if counterBits != 8 && counterBits != 16 && counterBits != 32 && counterBits != 64 && counterBits != VARINT_COUNTER_BITS {
+		m.corrupt("Length not 8, 16, 32, 64 or varint: %d", counterBits)
+		return false, -1, 0, 0, 0
 	}
 
-	if m.counterBits == 0 {
-		m.counterBits = counterBits
-	} else if m.counterBits != counterBits {
-		log.Fatalln("counterBits mismatch", m.counterBits != counterBits)
-	}
+	m.counterBits = counterBits
 
 	//
 	// dataLen
-	err = binary.Read(m.bufReader, m.endianness, &dataLen)
+	err = binary.Read(m.reader, m.endianness, &dataLen)
 
 	if err != nil {
-		log.Fatalln("binary.Read failed reading dataLen:", err)
+		m.corrupt("binary.Read failed reading dataLen: %s", err)
+		return false, -1, 0, 0, 0
 	}
 
 	if dataLen <= 0 {
-		log.Fatalln("Length <= 0", dataLen)
+		m.corrupt("Length <= 0: %d", dataLen)
+		return false, -1, 0, 0, 0
 	}
 
 	//
 	// sumData
-	err = binary.Read(m.bufReader, m.endianness, &sumData)
+	err = binary.Read(m.reader, m.endianness, &sumData)
 
 	if err != nil {
-		log.Fatalln("binary.Read failed reading sumData:", err)
+		m.corrupt("binary.Read failed reading sumData: %s", err)
+		return false, -1, 0, 0, 0
+	}
+
+	if m.dataLen == 0 {
+		m.dataLen = dataLen
+	} else if m.dataLen != dataLen {
+		m.corrupt("dataLen mismatch %d != %d", m.dataLen, dataLen)
+		return false, -1, 0, 0, 0
 	}
 
 	m.serial++
 
+	m.lastOffset = m.offset
+	m.lastBits = counterBits
+	m.lastSerial = serial
+	m.hasLast = true
+
+	// varint registers update their size once decoded
+	if counterBits != VARINT_COUNTER_BITS {
+		size, err := m.CalculateRegisterSize(int(counterBits), uint64(dataLen))
+
+		if err != nil {
+			m.corrupt("%s", err)
+			return false, -1, 0, 0, 0
+		}
+
+		m.lastSize = size
+		m.offset += m.lastSize
+	}
+
 	return hasData, serial, counterBits, dataLen, sumData
 }
 
+// checkRegister compares the last register read to the register table
+func (m *MultiArrayFile) checkRegister() {
+	if m.err != nil || !m.hasLast || !m.layout.HasHeader() {
+		return
+	}
+
+	if m.numRead >= len(m.layout.Registers) {
+		m.corrupt("register %d missing in register table", m.lastSerial)
+		return
+	}
+
+	register := m.layout.Registers[m.numRead]
+
+	if register.Offset != m.lastOffset || register.Serial != m.lastSerial {
+		m.corrupt("register %d at %d does not match the register table: %d at %d", m.lastSerial, m.lastOffset, register.Serial, register.Offset)
+		return
+	}
+
+	if register.Checksum != m.checksum.register {
+		m.corrupt("register %d: crc32c error %08x != %08x", m.lastSerial, m.checksum.register, register.Checksum)
+		return
+	}
+
+	m.numRead++
+	m.hasLast = false
+}
+
+// Read reads the next register whatever its encoding
+func (m *MultiArrayFile) Read(data *[]uint64) (hasData bool, serial int64) {
+	dataLen := int64(0)
+	sumData := uint64(0)
+	serial = int64(0)
+
+	counterBits := int64(0)
+
+	hasData, serial, counterBits, dataLen, sumData = m.read()
+
+	if !hasData {
+		return hasData, serial
+	}
+
+	*data = make([]uint64, dataLen, dataLen)
+
+	var err error
+
+	switch counterBits {
+	case 8:
+		ndata := make([]uint8, dataLen, dataLen)
+		err = binary.Read(m.reader, m.endianness, &ndata)
+		for i, w := range ndata {
+			(*data)[i] = uint64(w)
+		}
+	case 16:
+		ndata := make([]uint16, dataLen, dataLen)
+		err = binary.Read(m.reader, m.endianness, &ndata)
+		for i, w := range ndata {
+			(*data)[i] = uint64(w)
+		}
+	case 32:
+		ndata := make([]uint32, dataLen, dataLen)
+		err = binary.Read(m.reader, m.endianness, &ndata)
+		for i, w := range ndata {
+			(*data)[i] = uint64(w)
+		}
+	case 64:
+		err = binary.Read(m.reader, m.endianness, data)
+	case VARINT_COUNTER_BITS:
+		err = m.readVarint(data)
+	}
+
+	if err != nil {
+		m.corrupt("binary.Read failed reading data %d: %s", counterBits, err)
+		return false, -1
+	}
+
+	sumDataV := uint64(0)
+	for _, w := range *data {
+		sumDataV += w
+	}
+
+	if sumData != sumDataV {
+		m.corrupt("binary.Read failed reading data %d: checksum error %d != %d", counterBits, sumData, sumDataV)
+		return false, -1
+	}
+
+	return hasData, serial
+}
+
+func (m *MultiArrayFile) readVarint(data *[]uint64) (err error) {
+	dataBytes := int64(0)
+
+	err = binary.Read(m.reader, m.endianness, &dataBytes)
+
+	if err != nil {
+		return err
+	}
+
+	if dataBytes < 0 {
+		return fmt.Errorf("varint length < 0: %d", dataBytes)
+	}
+
+	ndata := make([]byte, dataBytes, dataBytes)
+
+	_, err = io.ReadFull(m.reader, ndata)
+
+	if err != nil {
+		return err
+	}
+
+	prev := uint64(0)
+	pos := 0
+
+	for i := range *data {
+		delta, n := binary.Varint(ndata[pos:])
+
+		if n <= 0 {
+			return fmt.Errorf("corrupted varint at position %d", i)
+		}
+
+		pos += n
+		prev += uint64(delta)
+		(*data)[i] = prev
+	}
+
+	if pos != len(ndata) {
+		return fmt.Errorf("varint trailing bytes %d", len(ndata)-pos)
+	}
+
+	m.lastSize = 1 + 8 + 8 + 8 + 8 + 8 + uint64(dataBytes)
+	m.offset += m.lastSize
+
+	return nil
+}
+
+func (m *MultiArrayFile) Read8(data *[]uint8) (hasData bool, serial int64) {
+	dataLen := int64(0)
+	sumData := uint64(0)
+	serial = int64(0)
+
+	counterBits := int64(0)
+
+	hasData, serial, counterBits, dataLen, sumData = m.read()
+
+	if !hasData {
+		return hasData, serial
+	}
+
+	if counterBits != 8 {
+		m.corrupt("binary.Read failed reading data8: register has %d bits", counterBits)
+		return false, -1
+	}
+
+	*data = make([]uint8, dataLen, dataLen)
+
+	err := binary.Read(m.reader, m.endianness, data)
+
+	if err != nil {
+		m.corrupt("binary.Read failed reading data8: %s", err)
+		return false, -1
+	}
+
+	sumDataV := uint64(0)
+	for _, w := range *data {
+		sumDataV += uint64(w)
+	}
+
+	if sumData != sumDataV {
+		m.corrupt("binary.Read failed reading data8: checksum error %d != %d", sumData, sumDataV)
+		return false, -1
+	}
+
+	return hasData, serial
+}
+
 func (m *MultiArrayFile) Read16(data *[]uint16) (hasData bool, serial int64) {
 	dataLen := int64(0)
 	sumData := uint64(0)
 	serial = int64(0)
 
-	hasData, serial, _, dataLen, sumData = m.read()
+	counterBits := int64(0)
+
+	hasData, serial, counterBits, dataLen, sumData = m.read()
+
+	if !hasData {
+		return hasData, serial
+	}
+
+	if counterBits != 16 {
+		m.corrupt("binary.Read failed reading data16: register has %d bits", counterBits)
+		return false, -1
+	}
 
 	ndata := make([]uint16, dataLen, dataLen)
 	*data = make([]uint16, dataLen, dataLen)
 
-	err := binary.Read(m.bufReader, m.endianness, &ndata)
+	err := binary.Read(m.reader, m.endianness, &ndata)
 
 	if err != nil {
-		log.Fatalln("binary.Read failed reading data16:", err)
+		m.corrupt("binary.Read failed reading data16: %s", err)
+		return false, -1
 	}
 
 	sumDataV := uint64(0)
@@ -396,7 +1015,8 @@ func (m *MultiArrayFile) Read16(data *[]uint16) (hasData bool, serial int64) {
 	}
 
 	if sumData != sumDataV {
-		log.Fatalln("binary.Read failed reading data16: checksum error", sumData, sumDataV)
+		m.corrupt("binary.Read failed reading data16: checksum error %d != %d", sumData, sumDataV)
+		return false, -1
 	}
 
 	return hasData, serial
@@ -407,15 +1027,27 @@ func (m *MultiArrayFile) Read32(data *[]uint32) (hasData bool, serial int64) {
 	sumData := uint64(0)
 	serial = int64(0)
 
-	hasData, serial, _, dataLen, sumData = m.read()
+	counterBits := int64(0)
+
+	hasData, serial, counterBits, dataLen, sumData = m.read()
+
+	if !hasData {
+		return hasData, serial
+	}
+
+	if counterBits != 32 {
+		m.corrupt("binary.Read failed reading data32: register has %d bits", counterBits)
+		return false, -1
+	}
 
 	ndata := make([]uint32, dataLen, dataLen)
 	*data = make([]uint32, dataLen, dataLen)
 
-	err := binary.Read(m.bufReader, m.endianness, &ndata)
+	err := binary.Read(m.reader, m.endianness, &ndata)
 
 	if err != nil {
-		log.Fatalln("binary.Read failed reading data32:", err)
+		m.corrupt("binary.Read failed reading data32: %s", err)
+		return false, -1
 	}
 
 	sumDataV := uint64(0)
@@ -426,7 +1058,8 @@ func (m *MultiArrayFile) Read32(data *[]uint32) (hasData bool, serial int64) {
 	}
 
 	if sumData != sumDataV {
-		log.Fatalln("binary.Read failed reading data32: checksum error", sumData, sumDataV)
+		m.corrupt("binary.Read failed reading data32: checksum error %d != %d", sumData, sumDataV)
+		return false, -1
 	}
 
 	return hasData, serial
@@ -437,15 +1070,27 @@ func (m *MultiArrayFile) Read64(data *[]uint64) (hasData bool, serial int64) {
 	sumData := uint64(0)
 	serial = int64(0)
 
-	hasData, serial, _, dataLen, sumData = m.read()
+	counterBits := int64(0)
+
+	hasData, serial, counterBits, dataLen, sumData = m.read()
+
+	if !hasData {
+		return hasData, serial
+	}
+
+	if counterBits != 64 {
+		m.corrupt("binary.Read failed reading data64: register has %d bits", counterBits)
+		return false, -1
+	}
 
 	ndata := make([]uint64, dataLen, dataLen)
 	*data = make([]uint64, dataLen, dataLen)
 
-	err := binary.Read(m.bufReader, m.endianness, &ndata)
+	err := binary.Read(m.reader, m.endianness, &ndata)
 
 	if err != nil {
-		log.Fatalln("binary.Read failed reading data64:", err)
+		m.corrupt("binary.Read failed reading data64: %s", err)
+		return false, -1
 	}
 
 	sumDataV := uint64(0)
@@ -456,7 +1101,8 @@ func (m *MultiArrayFile) Read64(data *[]uint64) (hasData bool, serial int64) {
 	}
 
 	if sumData != sumDataV {
-		log.Fatalln("binary.Read failed reading data64: checksum error", sumData, sumDataV)
+		m.corrupt("binary.Read failed reading data64: checksum error %d != %d", sumData, sumDataV)
+		return false, -1
 	}
 
 	return hasData, serial
@@ -466,47 +1112,73 @@ func (m *MultiArrayFile) Read64(data *[]uint64) (hasData bool, serial int64) {
 // Close
 //
 
-func (m *MultiArrayFile) Close() {
-	defer m.file.Close()
+// Close finishes the file. It returns the first error writing or reading it.
+// Closing a closed file does nothing.
+func (m *MultiArrayFile) Close() error {
+	if m.isClosed {
+		return m.err
+	}
+
+	m.isClosed = true
+
+	if m.file != nil {
+		defer m.file.Close()
+	}
 
 	if m.writeMode {
-		err1 := binary.Write(m.bufWriter, m.endianness, false)     // hasData
-		err2 := binary.Write(m.bufWriter, m.endianness, int64(0))  // serial
-		err3 := binary.Write(m.bufWriter, m.endianness, int64(0))  // counterBits
-		err4 := binary.Write(m.bufWriter, m.endianness, int64(0))  // dataLen
-		err5 := binary.Write(m.bufWriter, m.endianness, uint64(0)) // sumData
-
-		if err1 != nil {
-			log.Fatalln("binary.Read failed closing file:", err1)
-		}
-		if err2 != nil {
-			log.Fatalln("binary.Read failed closing file:", err2)
-		}
-		if err3 != nil {
-			log.Fatalln("binary.Read failed closing file:", err3)
+		if m.err != nil {
+			return m.err
 		}
-		if err4 != nil {
-			log.Fatalln("binary.Read failed closing file:", err4)
-		}
-		if err5 != nil {
-			log.Fatalln("binary.Read failed closing file:", err5)
+
+		m.writeHeader()
+		m.addRegister()
+
+		err1 := binary.Write(m.writer, m.endianness, false)     // hasData
+		err2 := binary.Write(m.writer, m.endianness, int64(0))  // serial
+		err3 := binary.Write(m.writer, m.endianness, int64(0))  // counterBits
+		err4 := binary.Write(m.writer, m.endianness, int64(0))  // dataLen
+		err5 := binary.Write(m.writer, m.endianness, uint64(0)) // sumData
+
+		for _, err := range []error{err1, err2, err3, err4, err5} {
+			if err != nil {
+				m.fail(fmt.Errorf("failed closing file: %w", err))
+			}
 		}
 
-		if m.counterBits == 16 {
+		if m.counterBits == 8 {
+			data := make([]int8, m.dataLen, m.dataLen)
+			err5 = binary.Write(m.writer, m.endianness, data)
+		} else if m.counterBits == 16 {
 			data := make([]int16, m.dataLen, m.dataLen)
-			err5 = binary.Write(m.bufWriter, m.endianness, data)
+			err5 = binary.Write(m.writer, m.endianness, data)
 		} else if m.counterBits == 32 {
 			data := make([]int32, m.dataLen, m.dataLen)
-			err5 = binary.Write(m.bufWriter, m.endianness, data)
+			err5 = binary.Write(m.writer, m.endianness, data)
 		} else if m.counterBits == 64 {
 			data := make([]int64, m.dataLen, m.dataLen)
-			err5 = binary.Write(m.bufWriter, m.endianness, data)
+			err5 = binary.Write(m.writer, m.endianness, data)
 		}
 
 		if err5 != nil {
-			log.Fatalln("binary.Read failed closing file:", err5)
+			m.fail(fmt.Errorf("failed closing file: %w", err5))
+		}
+
+		if err := m.bufWriter.Flush(); err != nil {
+			m.fail(fmt.Errorf("failed closing file: %w", err))
 		}
 
-		m.bufWriter.Flush()
+		if m.chunked != nil && m.err == nil {
+			if err := m.chunked.Close(); err != nil {
+				m.fail(fmt.Errorf("failed closing chunks: %w", err))
+			}
+		}
+
+		if m.err == nil {
+			m.writeTrailer()
+		}
+	} else {
+		m.checkRegister()
 	}
+
+	return m.err
 }
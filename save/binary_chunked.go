@@ -0,0 +1,194 @@
+package save
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+//
+// Chunked compressed dumps
+//
+
+// A compressed dump holds the same stream of registers as a plain dump cut in
+// frames of about COMPRESSED_CHUNK_SIZE bytes, always at register boundaries,
+// each compressed on its own. The frames are followed by their offset table,
+// numChunks x {position, size, dataPosition, dataSize} uint64, in the layout
+// of binary_header.go.
+//
+// Register offsets stay the offsets in the uncompressed stream, so a register
+// is read by decompressing the single chunk holding it.
+
+const COMPRESSED_CHUNK_SIZE = uint64(1 << 18)
+const COMPRESSED_CHUNK_INDEX_SIZE = 8 + 8 + 8 + 8
+
+// ChunkInfo is the position of a compressed chunk in the file and of its
+// data in the uncompressed stream
+type ChunkInfo struct {
+	Position     uint64
+	Size         uint64
+	DataPosition uint64
+	DataSize     uint64
+}
+
+//
+// Chunked :: Writer
+//
+
+type chunkedWriter struct {
+	writer       io.Writer
+	compressor   string
+	newWriter    GenericNewWriter
+	buf          *bytes.Buffer
+	chunks       []ChunkInfo
+	position     uint64
+	dataPosition uint64
+}
+
+func newChunkedWriter(writer io.Writer, compressor string, newWriter GenericNewWriter) *chunkedWriter {
+	c := chunkedWriter{
+		writer:     writer,
+		compressor: compressor,
+		newWriter:  newWriter,
+		buf:        new(bytes.Buffer),
+		chunks:     make([]ChunkInfo, 0, 100),
+	}
+
+	return &c
+}
+
+func (c *chunkedWriter) Write(b []byte) (int, error) {
+	return c.buf.Write(b)
+}
+
+// Buffered returns the size of the data of the chunk being written
+func (c *chunkedWriter) Buffered() uint64 {
+	return uint64(c.buf.Len())
+}
+
+// Cut compresses and writes the buffered data as a chunk
+func (c *chunkedWriter) Cut() error {
+	if c.buf.Len() == 0 {
+		return nil
+	}
+
+	compressed := new(bytes.Buffer)
+
+	comp := c.newWriter(compressed)
+
+	if _, err := comp.Write(c.buf.Bytes()); err != nil {
+		return err
+	}
+
+	if err := comp.Close(); err != nil {
+		return err
+	}
+
+	if _, err := c.writer.Write(compressed.Bytes()); err != nil {
+		return err
+	}
+
+	chunk := ChunkInfo{
+		Position:     c.position,
+		Size:         uint64(compressed.Len()),
+		DataPosition: c.dataPosition,
+		DataSize:     uint64(c.buf.Len()),
+	}
+
+	c.chunks = append(c.chunks, chunk)
+	c.position += chunk.Size
+	c.dataPosition += chunk.DataSize
+	c.buf.Reset()
+
+	return nil
+}
+
+// Close writes the last chunk. The index is written by the MultiArrayFile.
+func (c *chunkedWriter) Close() error {
+	return c.Cut()
+}
+
+//
+// Chunked :: Reader
+//
+
+// findChunk returns the number of the chunk holding dataPosition
+func findChunk(chunks []ChunkInfo, dataPosition uint64) (int, bool) {
+	i := sort.Search(len(chunks), func(i int) bool {
+		return chunks[i].DataPosition+chunks[i].DataSize > dataPosition
+	})
+
+	return i, i < len(chunks)
+}
+
+func decompressChunk(compressor string, compressed []byte, dataSize uint64) ([]byte, error) {
+	newReader, err := GetCompressInterfaceReader(compressor)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	decomp := newReader(bytes.NewReader(compressed))
+	defer decomp.Close()
+
+	data, err := ioutil.ReadAll(decomp)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	if uint64(len(data)) != dataSize {
+		return nil, fmt.Errorf("%w: chunk has %d bytes instead of %d", ErrCorrupt, len(data), dataSize)
+	}
+
+	return data, nil
+}
+
+// chunkedReader reads the uncompressed stream of a compressed dump
+type chunkedReader struct {
+	reader     io.ReaderAt
+	compressor string
+	chunks     []ChunkInfo
+	current    int
+	data       *bytes.Reader
+}
+
+func newChunkedReader(reader io.ReaderAt, compressor string, chunks []ChunkInfo) *chunkedReader {
+	c := chunkedReader{
+		reader:     reader,
+		compressor: compressor,
+		chunks:     chunks,
+		current:    0,
+		data:       bytes.NewReader([]byte{}),
+	}
+
+	return &c
+}
+
+func (c *chunkedReader) Read(b []byte) (int, error) {
+	for c.data.Len() == 0 {
+		if c.current >= len(c.chunks) {
+			return 0, io.EOF
+		}
+
+		chunk := c.chunks[c.current]
+		compressed := make([]byte, chunk.Size)
+
+		if _, err := c.reader.ReadAt(compressed, int64(chunk.Position)); err != nil {
+			return 0, err
+		}
+
+		data, err := decompressChunk(c.compressor, compressed, chunk.DataSize)
+
+		if err != nil {
+			return 0, fmt.Errorf("chunk %d: %w", c.current, err)
+		}
+
+		c.data = bytes.NewReader(data)
+		c.current++
+	}
+
+	return c.data.Read(b)
+}
@@ -0,0 +1,270 @@
+package save
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+//
+// MultiArrayFile :: Layout
+//
+
+// Dumps start with a header and end with the register table and a trailer:
+//
+//   header      MultiArrayHeader
+//   registers   the registers, plain or in compressed chunks
+//   chunks      numChunks x ChunkInfo, compressed dumps only
+//   table       numRegisters x RegisterInfo
+//   trailer     MultiArrayTrailer
+//
+// Register offsets are relative to the start of the registers, as in
+// headerless dumps, which are still read. Headerless dumps are never compressed.
+
+const MULTI_ARRAY_MAGIC = "IBMATRIX"
+const MULTI_ARRAY_VERSION = uint32(1)
+const MULTI_ARRAY_ENDIANNESS = uint32(0x01020304)
+const MULTI_ARRAY_HEADER_SIZE = 64
+const MULTI_ARRAY_TRAILER_SIZE = 64
+const MULTI_ARRAY_REGISTER_INFO_SIZE = 32
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type MultiArrayHeader struct {
+	Magic       [8]byte
+	Version     uint32
+	Endianness  uint32
+	CounterBits int64
+	Dimension   uint64
+	DataLen     uint64
+	Compressor  [8]byte
+	Reserved    [16]byte
+}
+
+type MultiArrayTrailer struct {
+	TablePosition uint64
+	NumRegisters  uint64
+	ChunkPosition uint64
+	NumChunks     uint64
+	DataSize      uint64
+	Checksum      uint32 // CRC32C of the uncompressed registers
+	TableChecksum uint32 // CRC32C of the header, chunk index and register table
+	Reserved      [8]byte
+	Magic         [8]byte
+}
+
+// RegisterInfo is the position of a register in the uncompressed registers
+type RegisterInfo struct {
+	Offset      uint64
+	Size        uint64
+	Serial      int64
+	CounterBits int32
+	Checksum    uint32 // CRC32C of the register
+}
+
+// MultiArrayLayout describes a dump as read from its header and trailer.
+// Headerless dumps have version 0, no registers table and no checksums.
+type MultiArrayLayout struct {
+	Version      uint32
+	Header       MultiArrayHeader
+	Compressor   string
+	DataPosition uint64 // position of the registers in the file. unused for compressed dumps
+	DataSize     uint64
+	Checksum     uint32
+	Chunks       []ChunkInfo
+	Registers    []RegisterInfo
+}
+
+func (l *MultiArrayLayout) IsChunked() bool {
+	return l.Chunks != nil
+}
+
+func (l *MultiArrayLayout) HasHeader() bool {
+	return l.Version > 0
+}
+
+// FindRegister returns the register starting at offset
+func (l *MultiArrayLayout) FindRegister(offset uint64) (RegisterInfo, bool) {
+	i := sort.Search(len(l.Registers), func(i int) bool {
+		return l.Registers[i].Offset >= offset
+	})
+
+	if i == len(l.Registers) || l.Registers[i].Offset != offset {
+		return RegisterInfo{}, false
+	}
+
+	return l.Registers[i], true
+}
+
+func NewMultiArrayHeader(counterBits int64, dimension uint64, dataLen uint64, compressor string) MultiArrayHeader {
+	h := MultiArrayHeader{
+		Version:     MULTI_ARRAY_VERSION,
+		Endianness:  MULTI_ARRAY_ENDIANNESS,
+		CounterBits: counterBits,
+		Dimension:   dimension,
+		DataLen:     dataLen,
+	}
+
+	copy(h.Magic[:], MULTI_ARRAY_MAGIC)
+
+	if isCompressed, err := GetCompressIsCompressed(compressor); err == nil && isCompressed {
+		copy(h.Compressor[:], compressor)
+	}
+
+	return h
+}
+
+func (h MultiArrayHeader) GetCompressor() string {
+	compressor := strings.TrimRight(string(h.Compressor[:]), "\x00")
+
+	if compressor == "" {
+		return "none"
+	}
+
+	return compressor
+}
+
+func (h MultiArrayHeader) String() string {
+	return fmt.Sprintf("version %d counterBits %d dimension %d dataLen %d compressor %s", h.Version, h.CounterBits, h.Dimension, h.DataLen, h.GetCompressor())
+}
+
+// DimensionFromDataLen returns the number of samples of a matrix of dataLen counters
+func DimensionFromDataLen(dataLen uint64) uint64 {
+	if dataLen == 0 {
+		return 0
+	}
+
+	return uint64(math.Round((1 + math.Sqrt(1+8*float64(dataLen))) / 2))
+}
+
+// ReadMultiArrayLayout reads the header and trailer of a dump of size bytes
+func ReadMultiArrayLayout(r io.ReaderAt, size uint64) (*MultiArrayLayout, error) {
+	layout := MultiArrayLayout{
+		Compressor: "none",
+		DataSize:   size,
+	}
+
+	magic := make([]byte, len(MULTI_ARRAY_MAGIC))
+
+	if size < MULTI_ARRAY_HEADER_SIZE+MULTI_ARRAY_TRAILER_SIZE {
+		magic = []byte{}
+	} else if _, err := r.ReadAt(magic, 0); err != nil {
+		return nil, err
+	}
+
+	if string(magic) != MULTI_ARRAY_MAGIC {
+		return &layout, nil
+	}
+
+	head := make([]byte, MULTI_ARRAY_HEADER_SIZE)
+	tail := make([]byte, MULTI_ARRAY_TRAILER_SIZE)
+
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.ReadAt(tail, int64(size-MULTI_ARRAY_TRAILER_SIZE)); err != nil {
+		return nil, err
+	}
+
+	header := MultiArrayHeader{}
+	trailer := MultiArrayTrailer{}
+
+	binary.Read(bytes.NewReader(head), binary.LittleEndian, &header)
+	binary.Read(bytes.NewReader(tail), binary.LittleEndian, &trailer)
+
+	if header.Endianness != MULTI_ARRAY_ENDIANNESS {
+		return nil, fmt.Errorf("%w: unsupported endianness %08x", ErrVersion, header.Endianness)
+	}
+
+	if header.Version == 0 || header.Version > MULTI_ARRAY_VERSION {
+		return nil, fmt.Errorf("%w: unsupported version %d. newest known version is %d", ErrVersion, header.Version, MULTI_ARRAY_VERSION)
+	}
+
+	if string(trailer.Magic[:]) != MULTI_ARRAY_MAGIC {
+		return nil, fmt.Errorf("%w: missing trailer. file truncated", ErrCorrupt)
+	}
+
+	chunksSize := trailer.NumChunks * COMPRESSED_CHUNK_INDEX_SIZE
+	tableSize := trailer.NumRegisters * MULTI_ARRAY_REGISTER_INFO_SIZE
+
+	if trailer.ChunkPosition+chunksSize != trailer.TablePosition || trailer.TablePosition+tableSize != size-MULTI_ARRAY_TRAILER_SIZE {
+		return nil, fmt.Errorf("%w: corrupted trailer: %d registers at %d and %d chunks at %d in %d bytes", ErrCorrupt, trailer.NumRegisters, trailer.TablePosition, trailer.NumChunks, trailer.ChunkPosition, size)
+	}
+
+	index := make([]byte, chunksSize+tableSize)
+
+	if _, err := r.ReadAt(index, int64(trailer.ChunkPosition)); err != nil {
+		return nil, err
+	}
+
+	tableChecksum := crc32.Update(crc32.Checksum(head, crc32cTable), crc32cTable, index)
+
+	if tableChecksum != trailer.TableChecksum {
+		return nil, fmt.Errorf("%w: register table checksum error %08x != %08x", ErrCorrupt, tableChecksum, trailer.TableChecksum)
+	}
+
+	layout.Version = header.Version
+	layout.Header = header
+	layout.Compressor = header.GetCompressor()
+	layout.DataPosition = MULTI_ARRAY_HEADER_SIZE
+	layout.DataSize = trailer.DataSize
+	layout.Checksum = trailer.Checksum
+	layout.Registers = make([]RegisterInfo, trailer.NumRegisters)
+
+	indexReader := bytes.NewReader(index)
+
+	isCompressed, err := GetCompressIsCompressed(layout.Compressor)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	if isCompressed {
+		layout.Chunks = make([]ChunkInfo, trailer.NumChunks)
+		binary.Read(indexReader, binary.LittleEndian, &layout.Chunks)
+
+		dataPosition := uint64(0)
+
+		for i, chunk := range layout.Chunks {
+			if chunk.DataPosition != dataPosition || chunk.Position < MULTI_ARRAY_HEADER_SIZE || chunk.Position+chunk.Size > trailer.ChunkPosition {
+				return nil, fmt.Errorf("%w: corrupted chunk index: chunk %d", ErrCorrupt, i)
+			}
+			dataPosition += chunk.DataSize
+		}
+
+		if dataPosition != layout.DataSize {
+			return nil, fmt.Errorf("%w: corrupted chunk index: %d bytes in chunks instead of %d", ErrCorrupt, dataPosition, layout.DataSize)
+		}
+	} else if MULTI_ARRAY_HEADER_SIZE+layout.DataSize != trailer.ChunkPosition {
+		return nil, fmt.Errorf("%w: corrupted trailer: %d bytes of registers", ErrCorrupt, layout.DataSize)
+	}
+
+	binary.Read(indexReader, binary.LittleEndian, &layout.Registers)
+
+	return &layout, nil
+}
+
+//
+// MultiArrayFile :: Checksums
+//
+
+// checksumWriter keeps the checksums of the current register and of all
+// registers written through it, and their size
+type checksumWriter struct {
+	register uint32
+	stream   uint32
+	size     uint64
+}
+
+func (c *checksumWriter) Write(b []byte) (int, error) {
+	c.register = crc32.Update(c.register, crc32cTable, b)
+	c.stream = crc32.Update(c.stream, crc32cTable, b)
+	c.size += uint64(len(b))
+	return len(b), nil
+}
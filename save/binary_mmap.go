@@ -0,0 +1,346 @@
+package save
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sync"
+)
+
+//
+// MmapArrayFile
+//
+
+// MmapArrayFile reads the registers of a MultiArrayFile dump at random
+// from a read only memory map of the file. Compressed dumps keep the last
+// decompressed chunk.
+type MmapArrayFile struct {
+	fileName   string
+	path       string
+	base       uint64
+	endianness binary.ByteOrder
+	data       []byte
+	registers  []byte
+	file       *os.File
+	layout     *MultiArrayLayout
+	compressor string
+	chunks     []ChunkInfo
+	chunkNum   int
+	chunkData  []byte
+	chunkMutex sync.Mutex
+}
+
+func NewMmapArrayFile(fileName string) (*MmapArrayFile, error) {
+	file, err := os.Open(fileName)
+
+	if err != nil {
+		return nil, notFoundError(err)
+	}
+
+	fi, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	data, err := mmapFile(file, int(fi.Size()))
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	m, err := newMmapArrayFile(fileName, fileName, data, 0)
+
+	if err != nil {
+		munmapFile(data)
+		file.Close()
+		return nil, err
+	}
+
+	m.file = file
+
+	return m, nil
+}
+
+// newMmapArrayFile reads the dump in data, found at base in the file at path.
+// Dumps with a file own the memory map and unmap it on close.
+func newMmapArrayFile(fileName string, path string, data []byte, base uint64) (*MmapArrayFile, error) {
+	m := MmapArrayFile{
+		fileName:   fileName,
+		path:       path,
+		base:       base,
+		endianness: binary.LittleEndian,
+		data:       data,
+		compressor: "none",
+		chunkNum:   -1,
+	}
+
+	layout, err := ReadMultiArrayLayout(bytes.NewReader(data), uint64(len(data)))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed reading layout of %s: %w", fileName, err)
+	}
+
+	m.layout = layout
+	m.compressor = layout.Compressor
+	m.chunks = layout.Chunks
+
+	if !layout.IsChunked() {
+		m.registers = data[layout.DataPosition : layout.DataPosition+layout.DataSize]
+	}
+
+	return &m, nil
+}
+
+func (m *MmapArrayFile) GetFileName() string {
+	return m.fileName
+}
+
+// GetPath returns the file holding the dump. Positions are relative to it.
+func (m *MmapArrayFile) GetPath() string {
+	return m.path
+}
+
+func (m *MmapArrayFile) Size() uint64 {
+	return uint64(len(m.data))
+}
+
+func (m *MmapArrayFile) IsChunked() bool {
+	return m.chunks != nil
+}
+
+func (m *MmapArrayFile) GetCompressor() string {
+	return m.compressor
+}
+
+func (m *MmapArrayFile) GetLayout() *MultiArrayLayout {
+	return m.layout
+}
+
+// GetPosition returns the position in the file of the register at offset
+// of an uncompressed dump
+func (m *MmapArrayFile) GetPosition(offset uint64) (uint64, bool) {
+	if m.IsChunked() || offset >= m.layout.DataSize {
+		return 0, false
+	}
+
+	return m.base + m.layout.DataPosition + offset, true
+}
+
+// GetChunk returns the compressed chunk holding the register at offset
+func (m *MmapArrayFile) GetChunk(offset uint64) (ChunkInfo, bool) {
+	chunkNum, ok := findChunk(m.chunks, offset)
+
+	if !ok {
+		return ChunkInfo{}, false
+	}
+
+	chunk := m.chunks[chunkNum]
+	chunk.Position += m.base
+
+	return chunk, true
+}
+
+// getRegisterData returns the bytes holding the register at offset and the
+// position of the register in them
+func (m *MmapArrayFile) getRegisterData(offset uint64) ([]byte, uint64, error) {
+	if !m.IsChunked() {
+		return m.registers, offset, nil
+	}
+
+	chunkNum, ok := findChunk(m.chunks, offset)
+
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: register at %d beyond end of file %s", ErrCorrupt, offset, m.fileName)
+	}
+
+	chunk := m.chunks[chunkNum]
+
+	m.chunkMutex.Lock()
+	defer m.chunkMutex.Unlock()
+
+	if m.chunkNum != chunkNum {
+		data, err := decompressChunk(m.compressor, m.data[chunk.Position:chunk.Position+chunk.Size], chunk.DataSize)
+
+		if err != nil {
+			return nil, 0, fmt.Errorf("chunk %d of %s: %w", chunkNum, m.fileName, err)
+		}
+
+		m.chunkNum = chunkNum
+		m.chunkData = data
+	}
+
+	return m.chunkData, offset - chunk.DataPosition, nil
+}
+
+// ReadAt decodes the register starting at offset whatever its encoding
+func (m *MmapArrayFile) ReadAt(offset uint64, data *[]uint64) (serial int64, counterBits int64, err error) {
+	headerSize := uint64(1 + 8 + 8 + 8 + 8)
+
+	buf, pos, err := m.getRegisterData(offset)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	size := uint64(len(buf))
+
+	if err := m.checkRegister(offset, buf, pos); err != nil {
+		return 0, 0, err
+	}
+
+	if pos+headerSize > size {
+		return 0, 0, fmt.Errorf("%w: register at %d beyond end of file %s (%d)", ErrCorrupt, offset, m.fileName, m.Size())
+	}
+
+	header := buf[pos : pos+headerSize]
+
+	hasData := header[0] != 0
+	serial = int64(m.endianness.Uint64(header[1:9]))
+	counterBits = int64(m.endianness.Uint64(header[9:17]))
+	dataLen := int64(m.endianness.Uint64(header[17:25]))
+	sumData := m.endianness.Uint64(header[25:33])
+
+	if !hasData {
+		return serial, counterBits, fmt.Errorf("%w: register at %d of %s has no data", ErrCorrupt, offset, m.fileName)
+	}
+
+	if dataLen <= 0 {
+		return serial, counterBits, fmt.Errorf("%w: register at %d of %s has length %d", ErrCorrupt, offset, m.fileName, dataLen)
+	}
+
+	pos += headerSize
+	dbytes := uint64(0)
+
+	switch counterBits {
+	case 8:
+		dbytes = 1
+	case 16:
+		dbytes = 2
+	case 32:
+		dbytes = 4
+	case 64:
+		dbytes = 8
+	case VARINT_COUNTER_BITS:
+		dbytes = 0
+	default:
+		return serial, counterBits, fmt.Errorf("%w: register at %d of %s has %d bits", ErrCorrupt, offset, m.fileName, counterBits)
+	}
+
+	*data = make([]uint64, dataLen, dataLen)
+
+	if counterBits == VARINT_COUNTER_BITS {
+		err = m.readVarintAt(buf, pos, data)
+
+		if err != nil {
+			return serial, counterBits, err
+		}
+	} else {
+		end := pos + dbytes*uint64(dataLen)
+
+		if end > size {
+			return serial, counterBits, fmt.Errorf("%w: register at %d of %s truncated", ErrCorrupt, offset, m.fileName)
+		}
+
+		for i := range *data {
+			p := pos + uint64(i)*dbytes
+
+			switch dbytes {
+			case 1:
+				(*data)[i] = uint64(buf[p])
+			case 2:
+				(*data)[i] = uint64(m.endianness.Uint16(buf[p : p+2]))
+			case 4:
+				(*data)[i] = uint64(m.endianness.Uint32(buf[p : p+4]))
+			case 8:
+				(*data)[i] = m.endianness.Uint64(buf[p : p+8])
+			}
+		}
+	}
+
+	sumDataV := uint64(0)
+	for _, w := range *data {
+		sumDataV += w
+	}
+
+	if sumData != sumDataV {
+		return serial, counterBits, fmt.Errorf("%w: register at %d of %s: checksum error %d != %d", ErrCorrupt, offset, m.fileName, sumData, sumDataV)
+	}
+
+	return serial, counterBits, nil
+}
+
+// checkRegister compares the register at pos of buf to the register table
+func (m *MmapArrayFile) checkRegister(offset uint64, buf []byte, pos uint64) error {
+	if !m.layout.HasHeader() {
+		return nil
+	}
+
+	register, ok := m.layout.FindRegister(offset)
+
+	if !ok {
+		return fmt.Errorf("%w: no register at %d of %s", ErrCorrupt, offset, m.fileName)
+	}
+
+	if pos+register.Size > uint64(len(buf)) {
+		return fmt.Errorf("%w: register at %d of %s truncated", ErrCorrupt, offset, m.fileName)
+	}
+
+	if checksum := crc32.Checksum(buf[pos:pos+register.Size], crc32cTable); checksum != register.Checksum {
+		return fmt.Errorf("%w: register at %d of %s: crc32c error %08x != %08x", ErrCorrupt, offset, m.fileName, checksum, register.Checksum)
+	}
+
+	return nil
+}
+
+func (m *MmapArrayFile) readVarintAt(buf []byte, pos uint64, data *[]uint64) error {
+	size := uint64(len(buf))
+
+	if pos+8 > size {
+		return fmt.Errorf("%w: varint register at %d of %s truncated", ErrCorrupt, pos, m.fileName)
+	}
+
+	dataBytes := m.endianness.Uint64(buf[pos : pos+8])
+	pos += 8
+
+	if pos+dataBytes > size {
+		return fmt.Errorf("%w: varint register at %d of %s truncated", ErrCorrupt, pos, m.fileName)
+	}
+
+	ndata := buf[pos : pos+dataBytes]
+
+	prev := uint64(0)
+	p := 0
+
+	for i := range *data {
+		delta, n := binary.Varint(ndata[p:])
+
+		if n <= 0 {
+			return fmt.Errorf("%w: varint register at %d of %s: corrupted value at position %d", ErrCorrupt, pos, m.fileName, i)
+		}
+
+		p += n
+		prev += uint64(delta)
+		(*data)[i] = prev
+	}
+
+	return nil
+}
+
+func (m *MmapArrayFile) Close() {
+	if m.file != nil {
+		if err := munmapFile(m.data); err != nil {
+			fmt.Println("failed unmapping", m.fileName, err)
+		}
+
+		m.file.Close()
+	}
+
+	m.data = nil
+	m.registers = nil
+	m.chunkData = nil
+}
@@ -0,0 +1,477 @@
+package save
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//
+// Helpers
+//
+
+const testDataLen = 100000
+
+var testCompressors = []string{"none", "gzip", "snappy", "zstd"}
+
+// testRegisters returns registers whose values fit counterBits.
+// Large registers make compressed dumps span several chunks.
+func testRegisters(counterBits int64, numRegisters int) [][]uint64 {
+	maxValue := uint64(1)<<uint(counterBits) - 1
+
+	if counterBits == 64 || counterBits == VARINT_COUNTER_BITS {
+		maxValue = ^uint64(0) >> 1
+	}
+
+	registers := make([][]uint64, numRegisters)
+
+	for r := range registers {
+		registers[r] = make([]uint64, testDataLen)
+
+		for i := range registers[r] {
+			registers[r][i] = (uint64(i)*2654435761 + uint64(r)*40503) % (maxValue / 4)
+		}
+	}
+
+	return registers
+}
+
+// writeRegister writes data with the encoding of counterBits
+func writeRegister(m *MultiArrayFile, counterBits int64, data []uint64) int64 {
+	switch counterBits {
+	case 8:
+		ndata := make([]uint8, len(data))
+		for i, v := range data {
+			ndata[i] = uint8(v)
+		}
+		return m.Write8(&ndata)
+	case 16:
+		ndata := make([]uint16, len(data))
+		for i, v := range data {
+			ndata[i] = uint16(v)
+		}
+		return m.Write16(&ndata)
+	case 32:
+		ndata := make([]uint32, len(data))
+		for i, v := range data {
+			ndata[i] = uint32(v)
+		}
+		return m.Write32(&ndata)
+	case 64:
+		return m.Write64(&data)
+	default:
+		return m.WriteVarint(&data)
+	}
+}
+
+// writeDump writes a register of each of encodings and returns their offsets
+func writeDump(t *testing.T, fileName string, compressor string, encodings []int64, registers [][]uint64) []uint64 {
+	t.Helper()
+
+	m, err := OpenMultiArrayFile(fileName, "w", compressor)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetDimension(DimensionFromDataLen(testDataLen), 64)
+
+	offsets := make([]uint64, len(registers))
+
+	for r, data := range registers {
+		if serial := writeRegister(m, encodings[r], data); serial != int64(r) {
+			t.Fatalf("register %d written with serial %d", r, serial)
+		}
+
+		offsets[r], _, _ = m.GetLastRegister()
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return offsets
+}
+
+// readDump reads all registers of a dump
+func readDump(fileName string) ([][]uint64, error) {
+	m, err := OpenMultiArrayFile(fileName, "r", "none")
+
+	if err != nil {
+		return nil, err
+	}
+
+	registers := make([][]uint64, 0)
+
+	for {
+		data := make([]uint64, 0)
+
+		hasData, serial := m.Read(&data)
+
+		if !hasData {
+			break
+		}
+
+		if serial != int64(len(registers)) {
+			m.Close()
+			return nil, errors.New("serial out of order")
+		}
+
+		registers = append(registers, data)
+	}
+
+	return registers, m.Close()
+}
+
+func equalRegisters(a [][]uint64, b [][]uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for r := range a {
+		if len(a[r]) != len(b[r]) {
+			return false
+		}
+
+		for i := range a[r] {
+			if a[r][i] != b[r][i] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+//
+// Round trip
+//
+
+func TestMultiArrayRoundTrip(t *testing.T) {
+	encodings := map[string][]int64{
+		"8":      {8, 8, 8, 8},
+		"16":     {16, 16, 16, 16},
+		"32":     {32, 32, 32, 32},
+		"64":     {64, 64, 64, 64},
+		"varint": {VARINT_COUNTER_BITS, VARINT_COUNTER_BITS, VARINT_COUNTER_BITS, VARINT_COUNTER_BITS},
+		"mixed":  {8, VARINT_COUNTER_BITS, 16, 64, 32},
+	}
+
+	for name, encoding := range encodings {
+		for _, compressor := range testCompressors {
+			t.Run(name+"_"+compressor, func(t *testing.T) {
+				fileName := filepath.Join(t.TempDir(), "dump.bin")
+
+				// the narrowest encoding bounds the values of all registers
+				minBits := int64(64)
+				for _, bits := range encoding {
+					if bits != VARINT_COUNTER_BITS && bits < minBits {
+						minBits = bits
+					}
+				}
+
+				registers := testRegisters(minBits, len(encoding))
+				offsets := writeDump(t, fileName, compressor, encoding, registers)
+
+				read, err := readDump(fileName)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !equalRegisters(registers, read) {
+					t.Fatal("registers read differ from registers written")
+				}
+
+				mm, err := NewMmapArrayFile(fileName)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				defer mm.Close()
+
+				if mm.GetCompressor() != compressor {
+					t.Errorf("compressor %s instead of %s", mm.GetCompressor(), compressor)
+				}
+
+				if compressor != "none" && len(mm.GetLayout().Chunks) < 2 {
+					t.Errorf("%d chunks. expected several", len(mm.GetLayout().Chunks))
+				}
+
+				// read backwards so chunks are not read in order
+				for r := len(offsets) - 1; r >= 0; r-- {
+					data := make([]uint64, 0)
+
+					serial, counterBits, err := mm.ReadAt(offsets[r], &data)
+
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if serial != int64(r) || counterBits != encoding[r] {
+						t.Fatalf("register %d read as serial %d with %d bits instead of %d", r, serial, counterBits, encoding[r])
+					}
+
+					if !equalRegisters([][]uint64{registers[r]}, [][]uint64{data}) {
+						t.Fatalf("register %d differs", r)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMultiArrayHeader(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "dump.bin")
+	encoding := []int64{16, 16}
+
+	writeDump(t, fileName, "zstd", encoding, testRegisters(16, len(encoding)))
+
+	m, err := OpenMultiArrayFile(fileName, "r", "none")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	layout := m.GetLayout()
+
+	if !layout.HasHeader() || layout.Version != MULTI_ARRAY_VERSION {
+		t.Fatalf("version %d instead of %d", layout.Version, MULTI_ARRAY_VERSION)
+	}
+
+	if layout.Header.DataLen != testDataLen || layout.Header.Dimension != DimensionFromDataLen(testDataLen) || layout.Header.CounterBits != 64 {
+		t.Errorf("wrong header: %s", layout.Header)
+	}
+
+	if len(layout.Registers) != len(encoding) {
+		t.Errorf("%d registers in the table instead of %d", len(layout.Registers), len(encoding))
+	}
+}
+
+//
+// Headerless
+//
+
+// writeHeaderless writes registers as dumps were written before headers
+func writeHeaderless(t *testing.T, fileName string, counterBits int64, registers [][]uint64) []uint64 {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	offsets := make([]uint64, len(registers))
+
+	for r, data := range registers {
+		offsets[r] = uint64(buf.Len())
+
+		sumData := uint64(0)
+		for _, v := range data {
+			sumData += v
+		}
+
+		binary.Write(buf, binary.LittleEndian, true)
+		binary.Write(buf, binary.LittleEndian, int64(r))
+		binary.Write(buf, binary.LittleEndian, counterBits)
+		binary.Write(buf, binary.LittleEndian, int64(len(data)))
+		binary.Write(buf, binary.LittleEndian, sumData)
+
+		for _, v := range data {
+			switch counterBits {
+			case 16:
+				binary.Write(buf, binary.LittleEndian, uint16(v))
+			case 32:
+				binary.Write(buf, binary.LittleEndian, uint32(v))
+			case 64:
+				binary.Write(buf, binary.LittleEndian, v)
+			}
+		}
+	}
+
+	// the last register has no data
+	binary.Write(buf, binary.LittleEndian, false)
+	binary.Write(buf, binary.LittleEndian, [4]int64{})
+	binary.Write(buf, binary.LittleEndian, make([]byte, len(registers[0])*int(counterBits/8)))
+
+	if err := ioutil.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return offsets
+}
+
+func TestMultiArrayHeaderless(t *testing.T) {
+	for _, counterBits := range []int64{16, 32, 64} {
+		fileName := filepath.Join(t.TempDir(), "dump.bin")
+		registers := testRegisters(counterBits, 3)
+		offsets := writeHeaderless(t, fileName, counterBits, registers)
+
+		read, err := readDump(fileName)
+
+		if err != nil {
+			t.Fatalf("%d bits: %s", counterBits, err)
+		}
+
+		if !equalRegisters(registers, read) {
+			t.Fatalf("%d bits: registers read differ from registers written", counterBits)
+		}
+
+		mm, err := NewMmapArrayFile(fileName)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if mm.GetLayout().HasHeader() {
+			t.Errorf("%d bits: headerless dump read with header", counterBits)
+		}
+
+		data := make([]uint64, 0)
+
+		if _, _, err := mm.ReadAt(offsets[2], &data); err != nil {
+			t.Fatalf("%d bits: %s", counterBits, err)
+		}
+
+		if !equalRegisters(registers[2:], [][]uint64{data}) {
+			t.Fatalf("%d bits: register 2 differs", counterBits)
+		}
+
+		mm.Close()
+	}
+}
+
+//
+// Corruption
+//
+
+// corruptFile applies change to the contents of fileName
+func corruptFile(t *testing.T, fileName string, change func(data []byte)) {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(fileName)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change(data)
+
+	if err := ioutil.WriteFile(fileName, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectCorrupt(t *testing.T, err error, contains string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatal("corruption not detected")
+	}
+
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("error is not ErrCorrupt: %s", err)
+	}
+
+	if !strings.Contains(err.Error(), contains) {
+		t.Fatalf("error does not mention %s: %s", contains, err)
+	}
+}
+
+func TestMultiArrayRegisterChecksum(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "dump.bin")
+	encoding := []int64{32, 32, 32}
+	registers := testRegisters(32, len(encoding))
+	offsets := writeDump(t, fileName, "none", encoding, registers)
+
+	// swapping two counters keeps their sum, so only the crc32c finds it
+	corruptFile(t, fileName, func(data []byte) {
+		pos := MULTI_ARRAY_HEADER_SIZE + offsets[1] + 1 + 8 + 8 + 8 + 8
+
+		for i := uint64(0); i < 4; i++ {
+			data[pos+i], data[pos+4+i] = data[pos+4+i], data[pos+i]
+		}
+	})
+
+	_, err := readDump(fileName)
+
+	expectCorrupt(t, err, "crc32c")
+
+	mm, err := NewMmapArrayFile(fileName)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer mm.Close()
+
+	data := make([]uint64, 0)
+
+	if _, _, err := mm.ReadAt(offsets[0], &data); err != nil {
+		t.Fatalf("register 0 is not corrupted: %s", err)
+	}
+
+	_, _, err = mm.ReadAt(offsets[1], &data)
+
+	expectCorrupt(t, err, "crc32c")
+}
+
+func TestMultiArrayTableChecksum(t *testing.T) {
+	for _, compressor := range testCompressors {
+		fileName := filepath.Join(t.TempDir(), "dump.bin")
+		encoding := []int64{16, 16}
+
+		writeDump(t, fileName, compressor, encoding, testRegisters(16, len(encoding)))
+
+		// the checksum of the last register of the table
+		corruptFile(t, fileName, func(data []byte) {
+			data[len(data)-MULTI_ARRAY_TRAILER_SIZE-1] ^= 0xff
+		})
+
+		_, err := readDump(fileName)
+
+		expectCorrupt(t, err, "register table checksum")
+	}
+}
+
+func TestMultiArrayTruncated(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "dump.bin")
+	encoding := []int64{8, 8}
+
+	writeDump(t, fileName, "gzip", encoding, testRegisters(8, len(encoding)))
+
+	if err := os.Truncate(fileName, MULTI_ARRAY_HEADER_SIZE+MULTI_ARRAY_TRAILER_SIZE+100); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := readDump(fileName)
+
+	expectCorrupt(t, err, "truncated")
+}
+
+func TestFileChecksum(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "dump.bin")
+	encoding := []int64{64}
+
+	writeDump(t, fileName, "snappy", encoding, testRegisters(64, len(encoding)))
+
+	if err := WriteChecksum(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	if hasChecksum, err := VerifyChecksum(fileName); !hasChecksum || err != nil {
+		t.Fatalf("checksum of an intact file: %v %v", hasChecksum, err)
+	}
+
+	corruptFile(t, fileName, func(data []byte) {
+		data[MULTI_ARRAY_HEADER_SIZE+10] ^= 0x01
+	})
+
+	_, err := VerifyChecksum(fileName)
+
+	expectCorrupt(t, err, "checksum error")
+}
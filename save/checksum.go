@@ -0,0 +1,86 @@
+package save
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//
+// Checksums
+//
+
+// Database files are saved with a CRC32C checksum file next to them,
+// in the format of the usual checksum tools: "<checksum>  <file name>".
+
+const CHECKSUM_EXTENSION = "crc32c"
+
+func ChecksumFileName(fileName string) string {
+	return fileName + "." + CHECKSUM_EXTENSION
+}
+
+// FileChecksum returns the CRC32C of a file
+func FileChecksum(fileName string) (uint32, error) {
+	file, err := os.Open(fileName)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+
+	hash := crc32.New(crc32cTable)
+
+	if _, err := io.Copy(hash, file); err != nil {
+		return 0, err
+	}
+
+	return hash.Sum32(), nil
+}
+
+// WriteChecksum writes the checksum file of fileName
+func WriteChecksum(fileName string) error {
+	checksum, err := FileChecksum(fileName)
+
+	if err != nil {
+		return err
+	}
+
+	line := fmt.Sprintf("%08x  %s\n", checksum, filepath.Base(fileName))
+
+	return ioutil.WriteFile(ChecksumFileName(fileName), []byte(line), 0644)
+}
+
+// VerifyChecksum compares fileName to its checksum file.
+// hasChecksum is false for files saved without checksum.
+func VerifyChecksum(fileName string) (hasChecksum bool, err error) {
+	line, err := ioutil.ReadFile(ChecksumFileName(fileName))
+
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	expected := uint32(0)
+
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(line)), "%08x", &expected); err != nil {
+		return true, fmt.Errorf("%w: invalid checksum file %s: %s", ErrCorrupt, ChecksumFileName(fileName), err)
+	}
+
+	checksum, err := FileChecksum(fileName)
+
+	if err != nil {
+		return true, err
+	}
+
+	if checksum != expected {
+		return true, fmt.Errorf("%w: %s: checksum error %08x != %08x", ErrCorrupt, fileName, checksum, expected)
+	}
+
+	return true, nil
+}
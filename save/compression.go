@@ -16,6 +16,7 @@ type GenericWriter interface {
 }
 
 type GenericReader interface {
+	Close() error
 	Read([]byte) (int, error)
 }
 
@@ -41,6 +42,11 @@ var gzipInterface = CompressorInterface{
 	NewReader: NewGzipReader,
 }
 
+var zstdInterface = CompressorInterface{
+	NewWriter: NewZstdWriter,
+	NewReader: NewZstdReader,
+}
+
 var emptyInterface = CompressorInterface{
 	NewWriter: nil,
 	NewReader: nil,
@@ -63,6 +63,10 @@ type GzipReaderI struct {
 	sn *gzip.Reader
 }
 
+func (s *GzipReaderI) Close() error {
+	return s.sn.Close()
+}
+
 func (s *GzipReaderI) Read(b []byte) (int, error) {
 	return s.sn.Read(b)
 }
@@ -59,6 +59,10 @@ type SnappyReaderI struct {
 	sn *snappy.Reader
 }
 
+func (s *SnappyReaderI) Close() error {
+	return nil
+}
+
 func (s *SnappyReaderI) Read(b []byte) (int, error) {
 	return s.sn.Read(b)
 }
@@ -0,0 +1,73 @@
+package save
+
+import (
+	"github.com/klauspost/compress/zstd"
+	"io"
+)
+
+//
+//
+// Zstandard wrapper
+//
+//
+
+//
+// Zstandard Writer
+//
+
+func NewZstdWriter(s io.Writer) GenericWriter {
+	r, _ := zstd.NewWriter(s, zstd.WithEncoderLevel(zstd.SpeedDefault))
+
+	n := &ZstdWriterI{
+		sn: r,
+	}
+
+	return n
+}
+
+type ZstdWriterI struct {
+	sn *zstd.Encoder
+}
+
+func (s *ZstdWriterI) Close() error {
+	return s.sn.Close()
+}
+
+func (s *ZstdWriterI) Flush() error {
+	return s.sn.Flush()
+}
+
+func (s *ZstdWriterI) Reset(w io.Writer) {
+	s.sn.Reset(w)
+}
+
+func (s *ZstdWriterI) Write(b []byte) (int, error) {
+	return s.sn.Write(b)
+}
+
+//
+// Zstandard Reader
+//
+
+func NewZstdReader(s io.Reader) GenericReader {
+	r, _ := zstd.NewReader(s)
+
+	n := &ZstdReaderI{
+		sn: r,
+	}
+
+	return n
+}
+
+type ZstdReaderI struct {
+	sn *zstd.Decoder
+}
+
+func (s *ZstdReaderI) Close() error {
+	s.sn.Close()
+	return nil
+}
+
+func (s *ZstdReaderI) Read(b []byte) (int, error) {
+	return s.sn.Read(b)
+}
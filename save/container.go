@@ -0,0 +1,318 @@
+package save
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//
+// Container
+//
+
+// A container holds all files of a database in a single zip file. Members are
+// stored uncompressed, so dumps are read in place and can be memory mapped.
+// Members are named after the files they hold, without folder.
+
+const CONTAINER_EXTENSION = "ibdb"
+
+func ContainerFileName(outPrefix string) string {
+	return outPrefix + "." + CONTAINER_EXTENSION
+}
+
+func IsContainer(fileName string) bool {
+	return strings.HasSuffix(fileName, "."+CONTAINER_EXTENSION)
+}
+
+// ContainerPrefix returns the prefix of the database in a container
+func ContainerPrefix(fileName string) string {
+	return strings.TrimSuffix(fileName, "."+CONTAINER_EXTENSION)
+}
+
+// WriteContainer writes fileNames to a container. Members follow the order of fileNames.
+func WriteContainer(containerFileName string, fileNames []string) (err error) {
+	file, err := os.Create(containerFileName)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	writer := zip.NewWriter(file)
+
+	for _, fileName := range fileNames {
+		if err := writeContainerMember(writer, fileName); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+func writeContainerMember(writer *zip.Writer, fileName string) error {
+	member, err := os.Open(fileName)
+
+	if err != nil {
+		return err
+	}
+
+	defer member.Close()
+
+	fi, err := member.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(fi)
+
+	if err != nil {
+		return err
+	}
+
+	header.Name = filepath.Base(fileName)
+	header.Method = zip.Store
+
+	w, err := writer.CreateHeader(header)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, member)
+
+	return err
+}
+
+// CommitTempContainer writes fileNames, the files of the database saved to the
+// temporary prefix of outPrefix, to its container. fileName, the database file,
+// is the first member. The temporary folder is removed afterwards.
+func CommitTempContainer(outPrefix string, fileName string, fileNames []string) error {
+	dir := TempDir(outPrefix)
+
+	entries, err := tempFiles(dir, fileNames)
+
+	if err != nil {
+		return err
+	}
+
+	members := []string{fileName}
+
+	for _, entry := range entries {
+		if entry != filepath.Base(fileName) {
+			members = append(members, filepath.Join(dir, entry))
+		}
+	}
+
+	sort.Strings(members[1:])
+
+	tempFileName := filepath.Join(dir, filepath.Base(ContainerFileName(outPrefix)))
+
+	if err := WriteContainer(tempFileName, members); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempFileName, ContainerFileName(outPrefix)); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+//
+// Container :: Reader
+//
+
+type Container struct {
+	fileName string
+	file     *os.File
+	size     uint64
+	data     []byte
+	members  map[string]*zip.File
+}
+
+// OpenContainer reads the table of contents of a container.
+// Members are memory mapped by MmapArrayFile.
+func OpenContainer(fileName string) (*Container, error) {
+	file, err := os.Open(fileName)
+
+	if err != nil {
+		return nil, notFoundError(err)
+	}
+
+	fi, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	reader, err := zip.NewReader(file, fi.Size())
+
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%w: %s: %s", ErrCorrupt, fileName, err)
+	}
+
+	c := Container{
+		fileName: fileName,
+		file:     file,
+		size:     uint64(fi.Size()),
+		members:  make(map[string]*zip.File, len(reader.File)),
+	}
+
+	for _, member := range reader.File {
+		c.members[member.Name] = member
+	}
+
+	return &c, nil
+}
+
+func (c *Container) GetFileName() string {
+	return c.fileName
+}
+
+// Has tells whether the container holds fileName, with or without folder
+func (c *Container) Has(fileName string) bool {
+	_, ok := c.members[filepath.Base(fileName)]
+	return ok
+}
+
+// GuessPrefixFormat finds the database file of prefix in the container
+func (c *Container) GuessPrefixFormat(prefix string) (found bool, format string, compression string, filename string) {
+	for _, fn := range FormatNames {
+		fv := Formats[fn]
+		for cn, cv := range Compressors {
+			filename := prefix + "." + fv.Extension
+
+			if cv.Extension != "" {
+				filename += "." + cv.Extension
+			}
+
+			if c.Has(filename) {
+				return true, fn, cn, filename
+			}
+		}
+	}
+
+	return false, "", "", ""
+}
+
+// Open reads a member checking its checksum
+func (c *Container) Open(fileName string) (io.ReadCloser, error) {
+	member, ok := c.members[filepath.Base(fileName)]
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s in %s", ErrNotFound, filepath.Base(fileName), c.fileName)
+	}
+
+	return member.Open()
+}
+
+// Section returns the data of a member and its position in the container
+func (c *Container) Section(fileName string) (*io.SectionReader, uint64, error) {
+	name := filepath.Base(fileName)
+	member, ok := c.members[name]
+
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s in %s", ErrNotFound, name, c.fileName)
+	}
+
+	if member.Method != zip.Store {
+		return nil, 0, fmt.Errorf("%w: %s in %s is compressed", ErrCorrupt, name, c.fileName)
+	}
+
+	position, err := member.DataOffset()
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %s in %s: %s", ErrCorrupt, name, c.fileName, err)
+	}
+
+	if uint64(position)+member.UncompressedSize64 > c.size {
+		return nil, 0, fmt.Errorf("%w: %s in %s truncated", ErrCorrupt, name, c.fileName)
+	}
+
+	return io.NewSectionReader(c.file, position, int64(member.UncompressedSize64)), uint64(position), nil
+}
+
+// OpenMultiArrayFile reads a dump in the container
+func (c *Container) OpenMultiArrayFile(fileName string) (*MultiArrayFile, error) {
+	section, _, err := c.Section(fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenMultiArrayReader(c.fileName+":"+filepath.Base(fileName), section, uint64(section.Size()))
+}
+
+// MmapArrayFile maps a dump in the container. The container is mapped once
+// and unmapped on Close.
+func (c *Container) MmapArrayFile(fileName string) (*MmapArrayFile, error) {
+	section, position, err := c.Section(fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.data == nil {
+		data, err := mmapFile(c.file, int(c.size))
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.data = data
+	}
+
+	data := c.data[position : position+uint64(section.Size())]
+
+	return newMmapArrayFile(c.fileName+":"+filepath.Base(fileName), c.fileName, data, position)
+}
+
+// Verify reads every member checking its checksum
+func (c *Container) Verify() error {
+	for name, member := range c.members {
+		reader, err := member.Open()
+
+		if err != nil {
+			return fmt.Errorf("%w: %s in %s: %s", ErrCorrupt, name, c.fileName, err)
+		}
+
+		_, err = io.Copy(ioutil.Discard, reader)
+		reader.Close()
+
+		if err != nil {
+			return fmt.Errorf("%w: %s in %s: %s", ErrCorrupt, name, c.fileName, err)
+		}
+	}
+
+	return nil
+}
+
+// Close unmaps the container. Dumps mapped from it can not be read afterwards.
+func (c *Container) Close() error {
+	if c.data != nil {
+		if err := munmapFile(c.data); err != nil {
+			return err
+		}
+
+		c.data = nil
+	}
+
+	return c.file.Close()
+}
@@ -0,0 +1,25 @@
+package save
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+//
+// Errors
+//
+
+// Errors are wrapped with these so callers can test them with errors.Is
+var ErrCorrupt = errors.New("corrupt data")
+var ErrVersion = errors.New("unsupported version")
+var ErrNotFound = errors.New("not found")
+var ErrInvalid = errors.New("invalid argument")
+
+// notFoundError wraps os errors of missing files with ErrNotFound
+func notFoundError(err error) error {
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrNotFound, err)
+	}
+	return err
+}
@@ -2,7 +2,6 @@ package save
 
 import (
 	"fmt"
-	"os"
 )
 
 var Compressors = map[string]CompressFormat{
@@ -21,9 +20,14 @@ var Compressors = map[string]CompressFormat{
 		Extension:  "gz",
 		Interface:  gzipInterface,
 	},
+	"zstd": CompressFormat{
+		Compressor: "zstd",
+		Extension:  "zst",
+		Interface:  zstdInterface,
+	},
 }
 
-var CompressorNames = []string{"none", "snappy", "gzip"}
+var CompressorNames = []string{"none", "snappy", "gzip", "zstd"}
 var DefaultCompressor = "none"
 
 //
@@ -44,41 +48,47 @@ type CompressFormat struct {
 //
 //
 
-func GetCompressInformation(compressor string) *CompressFormat {
+// GetCompressInformation returns ErrInvalid for unknown compressors
+func GetCompressInformation(compressor string) (*CompressFormat, error) {
 	sf, ok := Compressors[compressor]
 
 	if !ok {
-		fmt.Println("Unknown compressor: ", compressor, ". valid compressor are:")
-		for k, _ := range Compressors {
-			fmt.Println(" ", k)
-		}
-		os.Exit(1)
+		return nil, fmt.Errorf("%w: unknown compressor '%s'. valid compressors are: %v", ErrInvalid, compressor, CompressorNames)
 	}
 
-	return &sf
+	return &sf, nil
 }
 
-func GetCompressExtension(compressor string) string {
-	sc := GetCompressInformation(compressor)
-	return sc.Extension
+func GetCompressExtension(compressor string) (string, error) {
+	sc, err := GetCompressInformation(compressor)
+	if err != nil {
+		return "", err
+	}
+	return sc.Extension, nil
 }
 
-func GetCompressInterface(compressor string) CompressorInterface {
-	sc := GetCompressInformation(compressor)
-	return sc.Interface
+func GetCompressInterface(compressor string) (CompressorInterface, error) {
+	sc, err := GetCompressInformation(compressor)
+	if err != nil {
+		return CompressorInterface{}, err
+	}
+	return sc.Interface, nil
 }
 
-func GetCompressInterfaceReader(compressor string) GenericNewReader {
-	sc := GetCompressInterface(compressor)
-	return sc.NewReader
+func GetCompressInterfaceReader(compressor string) (GenericNewReader, error) {
+	sc, err := GetCompressInterface(compressor)
+	return sc.NewReader, err
 }
 
-func GetCompressInterfaceWriter(compressor string) GenericNewWriter {
-	sc := GetCompressInterface(compressor)
-	return sc.NewWriter
+func GetCompressInterfaceWriter(compressor string) (GenericNewWriter, error) {
+	sc, err := GetCompressInterface(compressor)
+	return sc.NewWriter, err
 }
 
-func GetCompressIsCompressed(compressor string) bool {
-	sf := GetCompressInformation(compressor)
-	return sf.Compressor != "none"
+func GetCompressIsCompressed(compressor string) (bool, error) {
+	sf, err := GetCompressInformation(compressor)
+	if err != nil {
+		return false, err
+	}
+	return sf.Compressor != "none", nil
 }
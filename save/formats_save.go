@@ -1,12 +1,13 @@
 package save
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
-	"os"
 )
 
 import (
+	"github.com/vmihailenco/msgpack/v5"
 	"gopkg.in/yaml.v2"
 )
 
@@ -39,9 +40,32 @@ var Formats = map[string]SaveFormat{
 		MarshalerStreamerWriter:   gobMarshalerWriter,
 		UnMarshalerStreamerReader: gobUnMarshalerReader,
 	},
+	"json": SaveFormat{
+		Extension:                 "json",
+		HasMarshal:                true,
+		HasStreamer:               true,
+		Marshaler:                 json.Marshal,
+		UnMarshaler:               json.Unmarshal,
+		MarshalerStreamer:         jsonMarshaler,
+		UnMarshalerStreamer:       jsonUnMarshaler,
+		MarshalerStreamerWriter:   jsonMarshalerWriter,
+		UnMarshalerStreamerReader: jsonUnMarshalerReader,
+	},
+	"msgpack": SaveFormat{
+		Extension:                 "msgpack",
+		HasMarshal:                true,
+		HasStreamer:               true,
+		Marshaler:                 msgpack.Marshal,
+		UnMarshaler:               msgpack.Unmarshal,
+		MarshalerStreamer:         msgpackMarshaler,
+		UnMarshalerStreamer:       msgpackUnMarshaler,
+		MarshalerStreamerWriter:   msgpackMarshalerWriter,
+		UnMarshalerStreamerReader: msgpackUnMarshalerReader,
+	},
 }
 
-var FormatNames = []string{"yaml"}
+// FormatNames lists the formats in the order they are guessed
+var FormatNames = []string{"yaml", "gob", "json", "msgpack"}
 var DefaultFormat = "yaml"
 
 //
@@ -54,7 +78,7 @@ type Marshaler func(interface{}) ([]byte, error)
 type UnMarshaler func([]byte, interface{}) error
 type MarshalerStreamer func(string, interface{}) ([]byte, error)
 type UnMarshalerStreamer func(string, interface{}) error
-type MarshalerStreamerWriter func(io.Writer, interface{})
+type MarshalerStreamerWriter func(io.Writer, interface{}) error
 type UnMarshalerStreamerReader func(io.Reader, interface{}) error
 
 type SaveFormat struct {
@@ -91,61 +115,58 @@ func emptyUnMarshalerStreamer(filename string, val interface{}) error {
 //
 //
 
-func GetFormatInformation(format string) SaveFormat {
+// GetFormatInformation returns ErrInvalid for unknown formats
+func GetFormatInformation(format string) (SaveFormat, error) {
 	sf, ok := Formats[format]
 
 	if !ok {
-		fmt.Println("Unknown format: ", format, ". valid formats are:")
-		for k, _ := range Formats {
-			fmt.Println(" ", k)
-		}
-		os.Exit(1)
+		return sf, fmt.Errorf("%w: unknown format '%s'. valid formats are: %v", ErrInvalid, format, FormatNames)
 	}
 
-	return sf
+	return sf, nil
 }
 
-func GetFormatHasMarshal(format string) bool {
-	sf := GetFormatInformation(format)
-	return sf.HasMarshal
+func GetFormatHasMarshal(format string) (bool, error) {
+	sf, err := GetFormatInformation(format)
+	return sf.HasMarshal, err
 }
 
-func GetFormatHasStreamer(format string) bool {
-	sf := GetFormatInformation(format)
-	return sf.HasStreamer
+func GetFormatHasStreamer(format string) (bool, error) {
+	sf, err := GetFormatInformation(format)
+	return sf.HasStreamer, err
 }
 
-func GetFormatExtension(format string) string {
-	sf := GetFormatInformation(format)
-	return sf.Extension
+func GetFormatExtension(format string) (string, error) {
+	sf, err := GetFormatInformation(format)
+	return sf.Extension, err
 }
 
-func GetFormatMarshaler(format string) Marshaler {
-	sf := GetFormatInformation(format)
-	return sf.Marshaler
+func GetFormatMarshaler(format string) (Marshaler, error) {
+	sf, err := GetFormatInformation(format)
+	return sf.Marshaler, err
 }
 
-func GetFormatMarshalerStreamer(format string) MarshalerStreamer {
-	sf := GetFormatInformation(format)
-	return sf.MarshalerStreamer
+func GetFormatMarshalerStreamer(format string) (MarshalerStreamer, error) {
+	sf, err := GetFormatInformation(format)
+	return sf.MarshalerStreamer, err
 }
 
-func GetFormatMarshalerStreamerWriter(format string) MarshalerStreamerWriter {
-	sf := GetFormatInformation(format)
-	return sf.MarshalerStreamerWriter
+func GetFormatMarshalerStreamerWriter(format string) (MarshalerStreamerWriter, error) {
+	sf, err := GetFormatInformation(format)
+	return sf.MarshalerStreamerWriter, err
 }
 
-func GetFormatUnMarshaler(format string) UnMarshaler {
-	sf := GetFormatInformation(format)
-	return sf.UnMarshaler
+func GetFormatUnMarshaler(format string) (UnMarshaler, error) {
+	sf, err := GetFormatInformation(format)
+	return sf.UnMarshaler, err
 }
 
-func GetFormatUnMarshalerStreamer(format string) UnMarshalerStreamer {
-	sf := GetFormatInformation(format)
-	return sf.UnMarshalerStreamer
+func GetFormatUnMarshalerStreamer(format string) (UnMarshalerStreamer, error) {
+	sf, err := GetFormatInformation(format)
+	return sf.UnMarshalerStreamer, err
 }
 
-func GetFormatUnMarshalerStreamerReader(format string) UnMarshalerStreamerReader {
-	sf := GetFormatInformation(format)
-	return sf.UnMarshalerStreamerReader
+func GetFormatUnMarshalerStreamerReader(format string) (UnMarshalerStreamerReader, error) {
+	sf, err := GetFormatInformation(format)
+	return sf.UnMarshalerStreamerReader, err
 }
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package save
+
+import (
+	"os"
+	"syscall"
+)
+
+func mmapFile(file *os.File, size int) ([]byte, error) {
+	if size == 0 {
+		return []byte{}, nil
+	}
+
+	return syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmapFile(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return syscall.Munmap(data)
+}
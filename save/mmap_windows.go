@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package save
+
+import (
+	"io"
+	"os"
+)
+
+// no mmap on windows. the file is read into memory instead
+func mmapFile(file *os.File, size int) ([]byte, error) {
+	data := make([]byte, size, size)
+
+	_, err := io.ReadFull(file, data)
+
+	return data, err
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
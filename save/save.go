@@ -2,8 +2,10 @@ package save
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -14,14 +16,15 @@ type Saver struct {
 	FormatExtension     string
 	CompressorExtension string
 	Extension           string
+	container           *Container
 }
 
 func NewSaver(prefix string, format string) *Saver {
-	return newSaver(prefix, format, "none", GetFormatExtension(format), GetCompressExtension("none"))
+	return newSaver(prefix, format, "none", Formats[format].Extension, Compressors["none"].Extension)
 }
 
 func NewSaverCompressed(prefix string, format string, compressor string) *Saver {
-	return newSaver(prefix, format, compressor, GetFormatExtension(format), GetCompressExtension(compressor))
+	return newSaver(prefix, format, compressor, Formats[format].Extension, Compressors[compressor].Extension)
 }
 
 func newSaver(prefix string, format string, compressor string, formatExtension string, compressorExtension string) *Saver {
@@ -41,14 +44,30 @@ func newSaver(prefix string, format string, compressor string, formatExtension s
 // Setters
 //
 
-func (s *Saver) SetFormat(format string) {
+func (s *Saver) SetFormat(format string) error {
+	extension, err := GetFormatExtension(format)
+
+	if err != nil {
+		return err
+	}
+
 	s.Format = format
-	s.FormatExtension = GetFormatExtension(format)
+	s.FormatExtension = extension
+
+	return nil
 }
 
-func (s *Saver) SetCompressor(compressor string) {
+func (s *Saver) SetCompressor(compressor string) error {
+	extension, err := GetCompressExtension(compressor)
+
+	if err != nil {
+		return err
+	}
+
 	s.Compressor = compressor
-	s.CompressorExtension = GetFormatExtension(compressor)
+	s.CompressorExtension = extension
+
+	return nil
 }
 
 func (s *Saver) SetFormatExtension(extension string) {
@@ -63,6 +82,11 @@ func (s *Saver) SetExtension(extension string) {
 	s.Extension = extension
 }
 
+// SetContainer loads from a member of c instead of a file
+func (s *Saver) SetContainer(c *Container) {
+	s.container = c
+}
+
 //
 // Getters
 //
@@ -105,24 +129,25 @@ func (s *Saver) Exists() (bool, error) {
 
 		// Therefore, do *NOT* use !os.IsNotExist(err) to test for file existence
 	}
-
-	return false, err
 }
 
 //
 // Save
 //
 
-func (s *Saver) Save(val interface{}) {
-	format := s.Format
-	compress := s.Compressor
+func (s *Saver) Save(val interface{}) error {
+	sf, sc, err := s.formats()
+
+	if err != nil {
+		return err
+	}
 
-	// fmt.Println("format       ", format)
-	// fmt.Println("compress     ", compress)
+	// fmt.Println("format       ", s.Format)
+	// fmt.Println("compress     ", s.Compressor)
 
-	hasStreamer := GetFormatHasStreamer(format)
-	hasMarshal := GetFormatHasMarshal(format)
-	isCompressed := GetCompressIsCompressed(compress)
+	hasStreamer := sf.HasStreamer
+	hasMarshal := sf.HasMarshal
+	isCompressed := sc.Compressor != "none"
 
 	// fmt.Println("hasStreamer  ", hasStreamer)
 	// fmt.Println("hasMarshal   ", hasMarshal)
@@ -134,40 +159,60 @@ func (s *Saver) Save(val interface{}) {
 
 	if hasStreamer {
 		if isCompressed {
-			marshaler := GetFormatMarshalerStreamerWriter(format)
-			compressor := GetCompressInterfaceWriter(compress)
-			fmt.Println("marshaler ", marshaler)
-			fmt.Println("compressor", compressor)
-			saveDataStreamCompressed(outfile, marshaler, compressor, val)
+			err = saveDataStreamCompressed(outfile, sf.MarshalerStreamerWriter, sc.Interface.NewWriter, val)
 		} else {
-			marshaler := GetFormatMarshalerStreamer(format)
-			saveDataStream(outfile, marshaler, val)
+			err = saveDataStream(outfile, sf.MarshalerStreamer, val)
 		}
 
 	} else if hasMarshal {
-		marshaler := GetFormatMarshaler(format)
-		saveData(outfile, marshaler, val)
+		err = saveData(outfile, sf.Marshaler, val)
 	}
+
+	if err != nil {
+		return fmt.Errorf("error saving %s: %w", outfile, err)
+	}
+
+	if err := WriteChecksum(outfile); err != nil {
+		return fmt.Errorf("error writing checksum of %s: %w", outfile, err)
+	}
+
+	return nil
 }
 
-func saveData(outfile string, marshaler Marshaler, val interface{}) {
+// formats returns ErrInvalid for unknown formats and compressors
+func (s *Saver) formats() (SaveFormat, *CompressFormat, error) {
+	sf, err := GetFormatInformation(s.Format)
+
+	if err != nil {
+		return sf, nil, err
+	}
+
+	sc, err := GetCompressInformation(s.Compressor)
+
+	return sf, sc, err
+}
+
+func saveData(outfile string, marshaler Marshaler, val interface{}) error {
 	d, err := marshaler(val)
 
 	if err != nil {
-		fmt.Printf("error: %v", err)
-		os.Exit(1)
+		return err
 	}
 
 	fmt.Println("saving data to ", outfile)
 
 	err = ioutil.WriteFile(outfile, d, 0644)
 	fmt.Println("  done")
+
+	return err
 }
 
-func saveDataStream(outfile string, marshaler MarshalerStreamer, val interface{}) {
+func saveDataStream(outfile string, marshaler MarshalerStreamer, val interface{}) error {
 	// fmt.Println("saving stream to ", outfile)
 
-	marshaler(outfile, val)
+	_, err := marshaler(outfile, val)
+
+	return err
 }
 
 func saveDataStreamCompressed(outfile string, marshaler MarshalerStreamerWriter, compressor GenericNewWriter, val interface{}) error {
@@ -175,20 +220,24 @@ func saveDataStreamCompressed(outfile string, marshaler MarshalerStreamerWriter,
 	// fmt.Println("saveDataStreamCompressed :: marshaler  ", marshaler)
 	// fmt.Println("saveDataStreamCompressed :: compressor ", compressor)
 
-	file, err := os.OpenFile(outfile, os.O_CREATE|os.O_WRONLY, 0660) //|os.O_APPEND
+	file, err := os.OpenFile(outfile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0660) //|os.O_APPEND
+
+	if err != nil {
+		return err
+	}
+
 	defer file.Close()
 
-	if err == nil {
-		comp := compressor(file)
+	comp := compressor(file)
 
-		marshaler(comp, val)
+	err = marshaler(comp, val)
 
-		comp.Flush()
-		comp.Close()
+	if ferr := comp.Flush(); err == nil {
+		err = ferr
+	}
 
-	} else {
-		fmt.Println(err)
-		os.Exit(1)
+	if cerr := comp.Close(); err == nil {
+		err = cerr
 	}
 
 	return err
@@ -200,41 +249,134 @@ func saveDataStreamCompressed(outfile string, marshaler MarshalerStreamerWriter,
 //
 //
 
-func (s *Saver) Load(val interface{}) {
-	format := s.Format
+// Load returns ErrNotFound for missing files and ErrCorrupt for files which
+// do not match their checksum or can not be decoded
+func (s *Saver) Load(val interface{}) error {
+	sf, sc, err := s.formats()
+
+	if err != nil {
+		return err
+	}
 
 	outfile := s.GenFilename()
 
-	hasStreamer := GetFormatHasStreamer(format)
-	hasMarshal := GetFormatHasMarshal(format)
+	if s.container != nil {
+		return s.loadContainer(outfile, sf, sc, val)
+	}
+
+	if _, err := VerifyChecksum(outfile); err != nil {
+		return notFoundError(err)
+	}
+
+	hasStreamer := sf.HasStreamer
+	hasMarshal := sf.HasMarshal
+	isCompressed := sc.Compressor != "none"
 
 	if hasStreamer {
-		unmarshaler := GetFormatUnMarshalerStreamer(format)
-		loadDataStream(outfile, unmarshaler, val)
+		if isCompressed {
+			err = loadDataStreamCompressed(outfile, sf.UnMarshalerStreamerReader, sc.Interface.NewReader, val)
+		} else {
+			err = loadDataStream(outfile, sf.UnMarshalerStreamer, val)
+		}
 
 	} else if hasMarshal {
-		unmarshaler := GetFormatUnMarshaler(format)
-		loadData(outfile, unmarshaler, val)
+		err = loadData(outfile, sf.UnMarshaler, val)
 	}
+
+	return err
 }
 
-func loadData(outfile string, unmarshaler UnMarshaler, val interface{}) {
-	data, err := ioutil.ReadFile(outfile)
+// loadContainer decodes outfile from the container. The container checks
+// its members instead of checksum files.
+func (s *Saver) loadContainer(outfile string, sf SaveFormat, sc *CompressFormat, val interface{}) error {
+	fmt.Println("loading from ", s.container.GetFileName(), ":", filepath.Base(outfile))
+
+	reader, err := s.container.Open(outfile)
 
 	if err != nil {
-		fmt.Printf("dump file. Get err   #%v ", err)
+		return err
+	}
+
+	defer reader.Close()
+
+	var data io.Reader = reader
+
+	if sc.Compressor != "none" {
+		decomp := sc.Interface.NewReader(reader)
+		defer decomp.Close()
+		data = decomp
 	}
 
-	err = unmarshaler(data, val)
+	if sf.HasStreamer {
+		err = sf.UnMarshalerStreamerReader(data, val)
+	} else {
+		var buf []byte
+
+		if buf, err = ioutil.ReadAll(data); err == nil {
+			err = sf.UnMarshaler(buf, val)
+		}
+	}
 
 	if err != nil {
-		fmt.Printf("cannot unmarshal data: %v", err)
+		return fmt.Errorf("%w: cannot unmarshal %s in %s: %s", ErrCorrupt, filepath.Base(outfile), s.container.GetFileName(), err)
 	}
+
+	return nil
 }
 
-func loadDataStream(outfile string, unmarshaler UnMarshalerStreamer, val interface{}) {
+// Verify compares the saved file to its checksum file.
+// hasChecksum is false for files saved without checksum.
+func (s *Saver) Verify() (hasChecksum bool, err error) {
+	return VerifyChecksum(s.GenFilename())
+}
+
+func loadData(outfile string, unmarshaler UnMarshaler, val interface{}) error {
+	data, err := ioutil.ReadFile(outfile)
+
+	if err != nil {
+		return notFoundError(err)
+	}
+
+	if err = unmarshaler(data, val); err != nil {
+		return fmt.Errorf("%w: cannot unmarshal %s: %s", ErrCorrupt, outfile, err)
+	}
+
+	return nil
+}
+
+func loadDataStream(outfile string, unmarshaler UnMarshalerStreamer, val interface{}) error {
 	fmt.Println("loading from ", outfile)
-	unmarshaler(outfile, val)
+
+	if _, err := os.Stat(outfile); err != nil {
+		return notFoundError(err)
+	}
+
+	if err := unmarshaler(outfile, val); err != nil {
+		return fmt.Errorf("%w: cannot unmarshal %s: %s", ErrCorrupt, outfile, err)
+	}
+
+	return nil
+}
+
+func loadDataStreamCompressed(outfile string, unmarshaler UnMarshalerStreamerReader, decompressor GenericNewReader, val interface{}) error {
+	fmt.Println("loading from ", outfile)
+
+	file, err := os.Open(outfile)
+
+	if err != nil {
+		return notFoundError(err)
+	}
+
+	defer file.Close()
+
+	decomp := decompressor(file)
+	defer decomp.Close()
+
+	if err = unmarshaler(decomp, val); err != nil {
+		return fmt.Errorf("%w: cannot unmarshal %s: %s", ErrCorrupt, outfile, err)
+	}
+
+	return nil
 }
 
 func GuessFormat(filename string) (found bool, format string, compression string, prefix string) {
@@ -243,7 +385,8 @@ func GuessFormat(filename string) (found bool, format string, compression string
 	compression = ""
 	prefix = ""
 
-	for fn, fv := range Formats {
+	for _, fn := range FormatNames {
+		fv := Formats[fn]
 		fext := fv.Extension
 		for cn, cv := range Compressors {
 			cext := cv.Extension
@@ -271,7 +414,8 @@ func GuessPrefixFormat(prefix string) (found bool, format string, compression st
 	compression = ""
 	filename = ""
 
-	for fn, fv := range Formats {
+	for _, fn := range FormatNames {
+		fv := Formats[fn]
 		fext := fv.Extension
 		for cn, cv := range Compressors {
 			cext := cv.Extension
@@ -13,15 +13,15 @@ func gobMarshaler(filePath string, object interface{}) ([]byte, error) {
 	defer file.Close()
 
 	if err == nil {
-		gobMarshalerWriter(file, object)
+		err = gobMarshalerWriter(file, object)
 	}
 
 	return []byte{}, err
 }
 
-func gobMarshalerWriter(file io.Writer, object interface{}) {
+func gobMarshalerWriter(file io.Writer, object interface{}) error {
 	encoder := gob.NewEncoder(file)
-	encoder.Encode(object)
+	return encoder.Encode(object)
 }
 
 func gobUnMarshaler(filePath string, object interface{}) error {
@@ -0,0 +1,46 @@
+package save
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+//
+//
+// Json
+//
+//
+
+func jsonMarshaler(filePath string, object interface{}) ([]byte, error) {
+	file, err := os.Create(filePath)
+	defer file.Close()
+
+	if err == nil {
+		err = jsonMarshalerWriter(file, object)
+	}
+
+	return []byte{}, err
+}
+
+func jsonMarshalerWriter(file io.Writer, object interface{}) error {
+	encoder := json.NewEncoder(file)
+	return encoder.Encode(object)
+}
+
+func jsonUnMarshaler(filePath string, object interface{}) error {
+	file, err := os.Open(filePath)
+	defer file.Close()
+
+	if err == nil {
+		err = jsonUnMarshalerReader(file, object)
+	}
+
+	return err
+}
+
+func jsonUnMarshalerReader(file io.Reader, object interface{}) (err error) {
+	decoder := json.NewDecoder(file)
+	err = decoder.Decode(object)
+	return err
+}
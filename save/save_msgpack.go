@@ -0,0 +1,46 @@
+package save
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"os"
+)
+
+//
+//
+// MessagePack
+//
+//
+
+func msgpackMarshaler(filePath string, object interface{}) ([]byte, error) {
+	file, err := os.Create(filePath)
+	defer file.Close()
+
+	if err == nil {
+		err = msgpackMarshalerWriter(file, object)
+	}
+
+	return []byte{}, err
+}
+
+func msgpackMarshalerWriter(file io.Writer, object interface{}) error {
+	encoder := msgpack.NewEncoder(file)
+	return encoder.Encode(object)
+}
+
+func msgpackUnMarshaler(filePath string, object interface{}) error {
+	file, err := os.Open(filePath)
+	defer file.Close()
+
+	if err == nil {
+		err = msgpackUnMarshalerReader(file, object)
+	}
+
+	return err
+}
+
+func msgpackUnMarshalerReader(file io.Reader, object interface{}) (err error) {
+	decoder := msgpack.NewDecoder(file)
+	err = decoder.Decode(object)
+	return err
+}
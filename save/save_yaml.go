@@ -16,16 +16,20 @@ func yamlMarshaler(filePath string, object interface{}) ([]byte, error) {
 	defer file.Close()
 
 	if err == nil {
-		yamlMarshalerWriter(file, object)
+		err = yamlMarshalerWriter(file, object)
 	}
 
 	return []byte{}, err
 }
 
-func yamlMarshalerWriter(file io.Writer, object interface{}) {
+func yamlMarshalerWriter(file io.Writer, object interface{}) error {
 	encoder := yaml.NewEncoder(file)
-	encoder.Encode(object)
-	encoder.Close()
+
+	if err := encoder.Encode(object); err != nil {
+		return err
+	}
+
+	return encoder.Close()
 }
 
 func yamlUnMarshaler(filePath string, object interface{}) error {
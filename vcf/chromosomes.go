@@ -18,34 +18,38 @@ import (
 //
 //
 
-func GatherChromosomeNames(sourceFile string, isTar bool, isGz bool, callBackParameters interfaces.CallBackParameters) (chromosomeNames interfaces.ChromosomeNamesType) {
+func GatherChromosomeNames(sourceFile string, isTar bool, isGz bool, callBackParameters interfaces.CallBackParameters) (chromosomeNames interfaces.ChromosomeNamesType, err error) {
 	exists, _ := chromosomeNames.Exists(sourceFile)
 
 	if exists {
 		fmt.Println(" exists")
-		chromosomeNames.Load(sourceFile)
+		err = chromosomeNames.Load(sourceFile)
 
 	} else {
 		fmt.Println(" creating")
 
-		addToNames := func(SampleNames *VCFSamples, register *VCFRegister) {
+		addToNames := func(SampleNames *VCFSamples, register *VCFRegister) error {
 			fmt.Println("adding chromosome ", register.Chromosome)
 			chromosomeNames.Add(register.Chromosome, register.LineNumber)
+			return nil
 		}
 
-		getNames := func(r io.Reader, callBackParameters interfaces.CallBackParameters) {
-			ProcessVcfRaw(r,
+		getNames := func(r io.Reader, callBackParameters interfaces.CallBackParameters) error {
+			return ProcessVcfRaw(r,
 				callBackParameters,
 				addToNames,
+				nil,
 				[]string{""})
 		}
 
-		openfile.OpenFile(sourceFile, isTar, isGz, callBackParameters, getNames)
+		if err = openfile.OpenFile(sourceFile, isTar, isGz, callBackParameters, getNames); err != nil {
+			return chromosomeNames, err
+		}
 
-		chromosomeNames.Save(sourceFile)
+		err = chromosomeNames.Save(sourceFile)
 	}
 
-	return chromosomeNames
+	return chromosomeNames, err
 }
 
 func SpreadChromosomes(chromosomeNames interfaces.ChromosomeNamesType, numThreads int) [][]string {
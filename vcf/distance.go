@@ -2,7 +2,6 @@ package vcf
 
 import (
 	"fmt"
-	"os"
 )
 
 type GT struct {
@@ -90,7 +89,8 @@ func CalculateDistanceDiploid(a *VCFGTVal, b *VCFGTVal) uint64 {
 	return d
 }
 
-func GetValids(samples VCFSamplesGT) (valids []GT, numValids int) {
+// GetValids returns the called genotypes. Malformed genotypes return ErrCorrupt.
+func GetValids(samples VCFSamplesGT) (valids []GT, numValids int, err error) {
 	numSamples := uint64(len(samples))
 	numValids = 0
 	valids = make([]GT, numSamples, numSamples)
@@ -101,15 +101,13 @@ func GetValids(samples VCFSamplesGT) (valids []GT, numValids int) {
 		lgt := len(*gt)
 
 		if lgt == 0 { // wrong.
-			fmt.Print(" samplePos ", samplePos, " GT ", gt, " ", "WRONG 0")
-			os.Exit(1)
+			return nil, 0, fmt.Errorf("%w: sample %d has an empty genotype", ErrCorrupt, samplePos)
 		} else if lgt == 1 { // maybe no call
 			if (*gt)[0] == -1 { // is no call
 				// fmt.Print(" 1 samplePos ", samplePos, " GT ", gt, " ", "NC")
 				continue
 			} else {
-				fmt.Println(" samplePos ", samplePos, " GT ", gt, " ", "WRONG NOT -1")
-				os.Exit(1)
+				return nil, 0, fmt.Errorf("%w: sample %d has a haploid genotype %v which is not a no call", ErrCorrupt, samplePos, *gt)
 			}
 		} else if lgt == 2 { // alts
 			// fmt.Println(" samplePos ", samplePos, " GT ", gt, " ", "DIPLOID")
@@ -126,13 +124,62 @@ func GetValids(samples VCFSamplesGT) (valids []GT, numValids int) {
 		}
 	}
 
-	return valids, numValids
+	return valids, numValids, nil
 }
 
-func CalculateDistance(numSamples uint64, reg *VCFRegister) *DistanceMatrix {
+// CalculateDistanceExtension calculates the distances of the new samples against
+// all samples of the same site. The old vs old pairs are left empty.
+func CalculateDistanceExtension(samples VCFSamplesGT, newSamples VCFSamplesGT, distance *DistanceMatrix) (*DistanceMatrix, error) {
+	distance.Clean()
+
+	numOldSamples := uint64(len(samples))
+	allSamples := make(VCFSamplesGT, 0, len(samples)+len(newSamples))
+	allSamples = append(allSamples, samples...)
+	allSamples = append(allSamples, newSamples...)
+
+	valids, numValids, err := GetValids(allSamples)
+
+	if err != nil {
+		return nil, err
+	}
+
+	firstNewValid := numValids
+	for validPos := 0; validPos < numValids; validPos++ {
+		if valids[validPos].Position >= numOldSamples {
+			firstNewValid = validPos
+			break
+		}
+	}
+
+	for validPos1 := 0; validPos1 < numValids; validPos1++ {
+		valid1 := valids[validPos1]
+
+		validStart := validPos1 + 1
+		if validStart < firstNewValid {
+			validStart = firstNewValid
+		}
+
+		for validPos2 := validStart; validPos2 < numValids; validPos2++ {
+			valid2 := valids[validPos2]
+
+			if valid1.IsDiploid && valid2.IsDiploid {
+				dist := CalculateDistanceDiploid(valid1.Gt, valid2.Gt)
+				distance.Set(valid1.Position, valid2.Position, dist)
+			}
+		}
+	}
+
+	return distance, nil
+}
+
+func CalculateDistance(numSamples uint64, reg *VCFRegister) (*DistanceMatrix, error) {
 	reg.TempDistance.Clean()
 
-	valids, numValids := GetValids(reg.Samples)
+	valids, numValids, err := GetValids(reg.Samples)
+
+	if err != nil {
+		return nil, err
+	}
 
 	// fmt.Println("valids", numValids, valids, numSamples)
 
@@ -159,5 +206,5 @@ func CalculateDistance(numSamples uint64, reg *VCFRegister) *DistanceMatrix {
 		}
 	}
 
-	return reg.TempDistance
+	return reg.TempDistance, nil
 }
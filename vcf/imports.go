@@ -36,6 +36,10 @@ type SizedWaitGroup = sizedwaitgroup.SizedWaitGroup
 // openfile
 var OpenFile = openfile.OpenFile
 
+//
+// errors
+var ErrCorrupt = interfaces.ErrCorrupt
+
 //
 // VCF
 //
@@ -61,7 +65,8 @@ type VCFRegisterRaw struct {
 
 type VCFRegister = VCFRegisterRaw
 
-type VCFCallBack func(*VCFSamples, *VCFRegister)
+type VCFCallBack func(*VCFSamples, *VCFRegister) error
+type VCFChromosomeCallBack func(chromosomeName string) error
 type VCFReaderType func(io.Reader, VCFCallBack, bool, []string)
 type VCFMaskedReaderType = interfaces.VCFMaskedReaderType
 type VCFMaskedReaderChromosomeType = interfaces.VCFMaskedReaderChromosomeType
@@ -6,8 +6,8 @@ import (
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 	"io"
-	"os"
 	"strings"
+	"sync"
 )
 
 import (
@@ -21,24 +21,21 @@ import (
 //
 
 type ChromosomeCallbackRegister struct {
-	registerCallBack VCFCallBack
-	chromosomeNames  []string
-	wg               *SizedWaitGroup
-	// wg             *sync.WaitGroup
+	registerCallBack   VCFCallBack
+	chromosomeCallBack VCFChromosomeCallBack
+	chromosomeNames    []string
 }
 
-func (cc *ChromosomeCallbackRegister) ChromosomeCallback(r io.Reader, callBackParameters CallBackParameters) {
-	defer cc.wg.Done()
-
-	cc.ChromosomeCallbackSingleThreaded(r, callBackParameters)
-}
-
-func (cc *ChromosomeCallbackRegister) ChromosomeCallbackSingleThreaded(r io.Reader, callBackParameters CallBackParameters) {
+func (cc *ChromosomeCallbackRegister) ChromosomeCallback(r io.Reader, callBackParameters CallBackParameters) error {
 	bufreader := bufio.NewReader(r)
 
-	ProcessVcfRaw(bufreader, callBackParameters, cc.registerCallBack, cc.chromosomeNames)
+	if err := ProcessVcfRaw(bufreader, callBackParameters, cc.registerCallBack, cc.chromosomeCallBack, cc.chromosomeNames); err != nil {
+		return err
+	}
 
 	fmt.Println("Finished reading chromosomes   :", cc.chromosomeNames)
+
+	return nil
 }
 
 //
@@ -51,7 +48,7 @@ type VcfFormat struct {
 	isGz  bool
 }
 
-func CheckVcfFormat(sourceFile string) VcfFormat {
+func CheckVcfFormat(sourceFile string) (VcfFormat, error) {
 	vf := VcfFormat{
 		isTar: false,
 		isGz:  false,
@@ -72,11 +69,10 @@ func CheckVcfFormat(sourceFile string) VcfFormat {
 		vf.isTar = false
 		vf.isGz = false
 	} else {
-		fmt.Println("unknown file suffix!")
-		os.Exit(1)
+		return vf, fmt.Errorf("unknown file suffix: %s", sourceFile)
 	}
 
-	return vf
+	return vf, nil
 }
 
 //
@@ -86,16 +82,43 @@ func CheckVcfFormat(sourceFile string) VcfFormat {
 //
 
 // func OpenVcfFile(sourceFile string, continueOnError bool, numThreads int, registerCallBack interfaces.VCFMaskedReaderChromosomeType) {
-func OpenVcfFile(sourceFile string, callBackParameters CallBackParameters, registerCallBack VCFCallBack) {
+func OpenVcfFile(sourceFile string, callBackParameters CallBackParameters, registerCallBack VCFCallBack) error {
+	return OpenVcfFileChromosomes(sourceFile, callBackParameters, registerCallBack, nil)
+}
+
+// OpenVcfFileChromosomes also calls chromosomeCallBack once all registers of a
+// chromosome were sent. Chromosomes read by different threads finish concurrently.
+// The VCF file has to be sorted by chromosome.
+func OpenVcfFileChromosomes(sourceFile string, callBackParameters CallBackParameters, registerCallBack VCFCallBack, chromosomeCallBack VCFChromosomeCallBack) error {
 	fmt.Println("OpenVcfFile :: ",
 		"sourceFile", sourceFile,
 		"numBits", callBackParameters.NumBits,
 		"continueOnError", callBackParameters.ContinueOnError,
 		"numThreads", callBackParameters.NumThreads)
 
-	vcfFormat := CheckVcfFormat(sourceFile)
+	vcfFormat, err := CheckVcfFormat(sourceFile)
+
+	if err != nil {
+		return err
+	}
+
+	chromosomeNames, err := GatherChromosomeNames(sourceFile, vcfFormat.isTar, vcfFormat.isGz, callBackParameters)
+
+	if err != nil {
+		return err
+	}
 
-	chromosomeNames := GatherChromosomeNames(sourceFile, vcfFormat.isTar, vcfFormat.isGz, callBackParameters)
+	if chromosomeCallBack != nil {
+		seen := make(map[string]bool, len(chromosomeNames.Infos))
+
+		for _, chromosomeInfo := range chromosomeNames.Infos {
+			if seen[chromosomeInfo.ChromosomeName] {
+				return fmt.Errorf("chromosome %s is split in %s. the VCF file must be sorted by chromosome", chromosomeInfo.ChromosomeName, sourceFile)
+			}
+
+			seen[chromosomeInfo.ChromosomeName] = true
+		}
+	}
 
 	p := message.NewPrinter(language.English)
 	p.Print("Gathered Chromosome Names:\n")
@@ -114,11 +137,14 @@ func OpenVcfFile(sourceFile string, callBackParameters CallBackParameters, regis
 		}
 
 		ccr := ChromosomeCallbackRegister{
-			registerCallBack: registerCallBack,
-			chromosomeNames:  chromosomeGroup,
+			registerCallBack:   registerCallBack,
+			chromosomeCallBack: chromosomeCallBack,
+			chromosomeNames:    chromosomeGroup,
 		}
 
-		OpenFile(sourceFile, vcfFormat.isTar, vcfFormat.isGz, callBackParameters, ccr.ChromosomeCallbackSingleThreaded)
+		if err := OpenFile(sourceFile, vcfFormat.isTar, vcfFormat.isGz, callBackParameters, ccr.ChromosomeCallback); err != nil {
+			return err
+		}
 
 		fmt.Println("Finished reading file")
 
@@ -131,25 +157,38 @@ func OpenVcfFile(sourceFile string, callBackParameters CallBackParameters, regis
 
 		chromosomeGroups := SpreadChromosomes(chromosomeNames, threads)
 
+		// the first error of any thread is returned
+		errMutex := sync.Mutex{}
+
 		// wg := sync.WaitGroup
 		wg := sizedwaitgroup.New(threads)
 		for _, chromosomeGroup := range chromosomeGroups {
 			ccr := ChromosomeCallbackRegister{
-				registerCallBack: registerCallBack,
-				chromosomeNames:  chromosomeGroup,
-				wg:               &wg,
+				registerCallBack:   registerCallBack,
+				chromosomeCallBack: chromosomeCallBack,
+				chromosomeNames:    chromosomeGroup,
 			}
 
 			// wg.Add(1)
 			wg.Add()
 
-			go OpenFile(
-				sourceFile,
-				vcfFormat.isTar,
-				vcfFormat.isGz,
-				callBackParameters,
-				ccr.ChromosomeCallback,
-			)
+			go func() {
+				defer wg.Done()
+
+				threadErr := OpenFile(
+					sourceFile,
+					vcfFormat.isTar,
+					vcfFormat.isGz,
+					callBackParameters,
+					ccr.ChromosomeCallback,
+				)
+
+				errMutex.Lock()
+				if err == nil {
+					err = threadErr
+				}
+				errMutex.Unlock()
+			}()
 
 			if ONLYFIRST {
 				fmt.Println("Only sending first")
@@ -161,4 +200,6 @@ func OpenVcfFile(sourceFile string, callBackParameters CallBackParameters, regis
 		wg.Wait()
 		fmt.Println("All chromosomes completed")
 	}
+
+	return err
 }
@@ -0,0 +1,144 @@
+package vcf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+//
+//
+// Paired VCF reading
+//
+//
+
+type VCFPairCallBack func(*VCFSamples, *VCFRegister, *VCFSamples, *VCFRegister) error
+
+// errVcfPairStopped stops the readers once done is closed
+var errVcfPairStopped = errors.New("stopped reading")
+
+type vcfPairRegister struct {
+	samples  *VCFSamples
+	register VCFRegister
+}
+
+// OpenVcfFilePair reads two VCF files containing the same sites side by side
+// and calls registerCallBack once per site with the registers of both files.
+// Distances are not calculated. Reading stops at the first error returned by
+// registerCallBack.
+func OpenVcfFilePair(sourceFile string, otherFile string, callBackParameters CallBackParameters, registerCallBack VCFPairCallBack) error {
+	fmt.Println("OpenVcfFilePair :: ",
+		"sourceFile", sourceFile,
+		"otherFile", otherFile,
+		"continueOnError", callBackParameters.ContinueOnError)
+
+	callBackParameters.NoDistance = true
+
+	// stops the readers still running when returning early
+	done := make(chan struct{})
+	defer close(done)
+
+	sourceRegisters, sourceErr, err := readVcfFileAsync(sourceFile, callBackParameters, done)
+
+	if err != nil {
+		return err
+	}
+
+	otherRegisters, otherErr, err := readVcfFileAsync(otherFile, callBackParameters, done)
+
+	if err != nil {
+		return err
+	}
+
+	numRegisters := int64(0)
+
+	for {
+		source, hasSource := <-sourceRegisters
+		other, hasOther := <-otherRegisters
+
+		// a file stopping early may have failed reading
+		if !hasSource {
+			if err := <-sourceErr; err != nil {
+				return err
+			}
+		}
+
+		if !hasOther {
+			if err := <-otherErr; err != nil {
+				return err
+			}
+		}
+
+		if !hasSource && !hasOther {
+			break
+		}
+
+		if hasSource != hasOther {
+			return fmt.Errorf("files have a different number of sites. stopped at register %d", numRegisters)
+		}
+
+		if source.register.Chromosome != other.register.Chromosome || source.register.Position != other.register.Position {
+			return fmt.Errorf("files have different sites at register %d: %s %d != %s %d", numRegisters,
+				source.register.Chromosome, source.register.Position,
+				other.register.Chromosome, other.register.Position)
+		}
+
+		if err := registerCallBack(source.samples, &source.register, other.samples, &other.register); err != nil {
+			return err
+		}
+
+		numRegisters++
+	}
+
+	fmt.Println("Finished reading files. registers:", numRegisters)
+
+	return nil
+}
+
+// readVcfFileAsync sends the registers of sourceFile. Once they are closed,
+// errs receives the error reading the file, if any. Closing done stops the
+// reader.
+func readVcfFileAsync(sourceFile string, callBackParameters CallBackParameters, done chan struct{}) (registers chan vcfPairRegister, errs chan error, err error) {
+	vcfFormat, err := CheckVcfFormat(sourceFile)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chromosomeNames, err := GatherChromosomeNames(sourceFile, vcfFormat.isTar, vcfFormat.isGz, callBackParameters)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registers = make(chan vcfPairRegister, 1000)
+	errs = make(chan error, 1)
+
+	chromosomeGroup := make([]string, 0, chromosomeNames.NumChromosomes)
+
+	for _, chromosomeInfo := range chromosomeNames.Infos {
+		chromosomeGroup = append(chromosomeGroup, chromosomeInfo.ChromosomeName)
+	}
+
+	sendRegister := func(samples *VCFSamples, register *VCFRegister) error {
+		select {
+		case registers <- vcfPairRegister{samples, *register}:
+			return nil
+		case <-done:
+			return errVcfPairStopped
+		}
+	}
+
+	readRegisters := func(r io.Reader, callBackParameters CallBackParameters) error {
+		return ProcessVcfRaw(bufio.NewReader(r), callBackParameters, sendRegister, nil, chromosomeGroup)
+	}
+
+	go func() {
+		err := OpenFile(sourceFile, vcfFormat.isTar, vcfFormat.isGz, callBackParameters, readRegisters)
+		close(registers)
+		errs <- err
+	}()
+
+	return registers, errs, nil
+}
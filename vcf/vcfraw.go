@@ -4,12 +4,16 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"os"
 	"strconv"
 	"strings"
 )
 
-func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback VCFCallBack, chromosomeNames []string) {
+// ProcessVcfRaw calls callback for each register of chromosomeNames and, if
+// not nil, chromosomeCallback once all registers of a chromosome were sent.
+// Chromosomes in SkipChromosomes are not sent.
+// Malformed lines return ErrCorrupt unless ContinueOnError is set. Errors
+// returned by callback stop the reading.
+func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback VCFCallBack, chromosomeCallback VCFChromosomeCallBack, chromosomeNames []string) error {
 	fmt.Println("Opening file to read chromosome:", chromosomeNames)
 
 	contents := bufio.NewScanner(r)
@@ -39,6 +43,14 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 	registerNumberThread := int64(0)
 	registerNumberChrom := int64(0)
 	foundChromosome := false
+	skipChromosome := false
+
+	finishChromosome := func(chromosomeName string) error {
+		if chromosomeCallback == nil || sendOnlyChromosomeNames {
+			return nil
+		}
+		return chromosomeCallback(chromosomeName)
+	}
 
 	for contents.Scan() {
 		lineNumber++
@@ -70,16 +82,22 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 		cols := strings.Split(row, "\t")
 
 		if len(cols) < 9 {
-			fmt.Println("less than 9 columns. can't continue")
-			os.Exit(1)
+			return fmt.Errorf("%w: line %d: less than 9 columns. can't continue", ErrCorrupt, lineNumber)
 		}
 
 		chrom := cols[0]
 
 		if chrom != lastChrom {
+			if chromIndex != -1 && !skipChromosome {
+				if err := finishChromosome(lastChrom); err != nil {
+					return err
+				}
+			}
+
 			chromosomeNumber++
 			registerNumberChrom = 0
 			chromIndex, _ = SliceIndex(len(chromosomeNames), func(i int) bool { return chromosomeNames[i] == chrom })
+			_, skipChromosome = SliceIndex(len(callBackParameters.SkipChromosomes), func(i int) bool { return callBackParameters.SkipChromosomes[i] == chrom })
 			fmt.Println("  new chromosome ", chrom, " index ", chromIndex, " in ", chromosomeNames)
 		}
 
@@ -98,7 +116,9 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 					Samples:          nil,
 				}
 
-				callback(&SampleNames, &register)
+				if err := callback(&SampleNames, &register); err != nil {
+					return err
+				}
 			}
 			continue
 		}
@@ -106,7 +126,7 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 		if chromIndex == -1 {
 			if foundChromosome { // already found, therefore finished
 				fmt.Println("Finished reading chromosome", chromosomeNames, " now at ", chrom, registerNumberThread, " registers ")
-				return
+				return nil
 			} else { // not found yet, therefore continue
 				continue
 			}
@@ -117,6 +137,10 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 			}
 		}
 
+		if skipChromosome {
+			continue
+		}
+
 		registerNumberChrom++
 
 		if BREAKAT_CHROM > 0 && registerNumberChrom >= BREAKAT_CHROM {
@@ -128,7 +152,7 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 
 		if BREAKAT_THREAD > 0 && registerNumberThread >= BREAKAT_THREAD {
 			fmt.Println(" BREAKING ", chromosomeNames, " at register ", registerNumberThread)
-			return
+			return finishChromosome(chrom)
 		}
 
 		pos, pos_err := strconv.ParseUint(cols[1], 10, 64)
@@ -149,8 +173,7 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 			if callBackParameters.ContinueOnError {
 				continue
 			} else {
-				fmt.Println(pos_err)
-				os.Exit(1)
+				return fmt.Errorf("%w: line %d: %s", ErrCorrupt, lineNumber, pos_err)
 			}
 		}
 
@@ -158,8 +181,7 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 			if callBackParameters.ContinueOnError {
 				continue
 			} else {
-				fmt.Println("no genotype info field")
-				os.Exit(1)
+				return fmt.Errorf("%w: line %d: no genotype info field", ErrCorrupt, lineNumber)
 			}
 		}
 
@@ -171,8 +193,7 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 			if callBackParameters.ContinueOnError {
 				continue
 			} else {
-				fmt.Println("wrong number of columns: expected ", numSampleNames, " got ", numSamples)
-				os.Exit(1)
+				return fmt.Errorf("%w: line %d: wrong number of columns: expected %d got %d", ErrCorrupt, lineNumber, numSampleNames, numSamples)
 			}
 		}
 
@@ -194,8 +215,7 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 						if callBackParameters.ContinueOnError {
 							continue
 						} else {
-							fmt.Println(sampleGT0_err)
-							os.Exit(1)
+							return fmt.Errorf("%w: line %d: %s", ErrCorrupt, lineNumber, sampleGT0_err)
 						}
 					}
 
@@ -203,8 +223,7 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 						if callBackParameters.ContinueOnError {
 							continue
 						} else {
-							fmt.Println(sampleGT1_err)
-							os.Exit(1)
+							return fmt.Errorf("%w: line %d: %s", ErrCorrupt, lineNumber, sampleGT1_err)
 						}
 					}
 
@@ -242,9 +261,35 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 		register.Position = pos
 		register.Alt = altCols
 		register.Samples = samplesGT
-		register.Distance = CalculateDistance(numSampleNames, &register)
+		if callBackParameters.NoDistance {
+			register.Distance = nil
+		} else {
+			distance, err := CalculateDistance(numSampleNames, &register)
+
+			if err != nil {
+				if callBackParameters.ContinueOnError {
+					continue
+				} else {
+					return fmt.Errorf("line %d: %w", lineNumber, err)
+				}
+			}
+
+			register.Distance = distance
+		}
+
+		if err := callback(&SampleNames, &register); err != nil {
+			return err
+		}
+	}
+
+	if err := contents.Err(); err != nil {
+		return fmt.Errorf("%w: line %d: %s", ErrCorrupt, lineNumber, err)
+	}
 
-		callback(&SampleNames, &register)
+	if chromIndex != -1 && !skipChromosome {
+		if err := finishChromosome(lastChrom); err != nil {
+			return err
+		}
 	}
 
 	if sendOnlyChromosomeNames { // return only chromosome names
@@ -258,7 +303,10 @@ func ProcessVcfRaw(r io.Reader, callBackParameters CallBackParameters, callback
 			Samples:    nil,
 		}
 
-		callback(&SampleNames, &register)
+		if err := callback(&SampleNames, &register); err != nil {
+			return err
+		}
 	}
 
+	return nil
 }
@@ -14,14 +14,40 @@ import (
 // router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosome/{chromosome}/block/{blockNum:[0-9]+}/matrix", endpoints.BlockMatrix).Methods("GET")             //.HeadersRegexp("Content-Type", "application/json")
 // router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosome/{chromosome}/block/{blockNum:[0-9]+}/matrix/table", endpoints.BlocksMatrixTable).Methods("GET") //.HeadersRegexp("Content-Type", "application/json")
 
+// RANGE_MAX_BLOCKS is the default number of blocks returned by Range when no resolution is requested
+var RANGE_MAX_BLOCKS = uint64(100)
+
 func Blocks(w http.ResponseWriter, r *http.Request) {
 	log.Tracef("Blocks %#v", r)
 
+	params := mux.Vars(r)
+	database := params["database"]
+	chromosome := params["chromosome"]
+	resolution := r.FormValue("resolution")
+
+	blocks, ok := databases.GetBlocks(database, chromosome, resolution)
+
+	if !ok {
+		resp := Message(false, "fail")
+		resp["data"] = "No such chromosome: " + chromosome + " in database " + database + " or resolution: " + resolution
+		Respond(w, resp)
+		return
+	}
+
+	resp := Message(true, "success")
+	resp["data"] = blocks
+
+	Respond(w, resp)
+}
+
+func AffectedBlocks(w http.ResponseWriter, r *http.Request) {
+	log.Tracef("AffectedBlocks %#v", r)
+
 	params := mux.Vars(r)
 	database := params["database"]
 	chromosome := params["chromosome"]
 
-	blocks, ok := databases.GetBlocks(database, chromosome)
+	blocks, ok := databases.GetAffectedBlocks(database, chromosome)
 
 	if !ok {
 		resp := Message(false, "fail")
@@ -36,10 +62,90 @@ func Blocks(w http.ResponseWriter, r *http.Request) {
 	Respond(w, resp)
 }
 
-func blockParams(r *http.Request) (database string, chromosome string, blockNum uint64, msg string, ok bool) {
+func Levels(w http.ResponseWriter, r *http.Request) {
+	log.Tracef("Levels %#v", r)
+
+	params := mux.Vars(r)
+	database := params["database"]
+	chromosome := params["chromosome"]
+
+	levels, ok := databases.GetLevels(database, chromosome)
+
+	if !ok {
+		resp := Message(false, "fail")
+		resp["data"] = "No such chromosome: " + chromosome + " in database " + database
+		Respond(w, resp)
+		return
+	}
+
+	resp := Message(true, "success")
+	resp["data"] = levels
+
+	Respond(w, resp)
+}
+
+func Range(w http.ResponseWriter, r *http.Request) {
+	log.Tracef("Range %#v", r)
+
+	params := mux.Vars(r)
+	database := params["database"]
+	chromosome := params["chromosome"]
+	startS := params["start"]
+	endS := params["end"]
+	resolution := r.FormValue("resolution")
+	maxBlocksS := r.FormValue("maxBlocks")
+
+	start, err := strconv.ParseUint(startS, 10, 64)
+
+	if err != nil {
+		resp := Message(false, "fail")
+		resp["data"] = "Invalid start: " + startS + ". Not a number"
+		Respond(w, resp)
+		return
+	}
+
+	end, err := strconv.ParseUint(endS, 10, 64)
+
+	if err != nil || end < start {
+		resp := Message(false, "fail")
+		resp["data"] = "Invalid end: " + endS + ". Not a number or smaller than start"
+		Respond(w, resp)
+		return
+	}
+
+	maxBlocks := RANGE_MAX_BLOCKS
+
+	if maxBlocksS != "" {
+		maxBlocks, err = strconv.ParseUint(maxBlocksS, 10, 64)
+
+		if err != nil || maxBlocks == 0 {
+			resp := Message(false, "fail")
+			resp["data"] = "Invalid maxBlocks: " + maxBlocksS + ". Not a positive number"
+			Respond(w, resp)
+			return
+		}
+	}
+
+	rangeInfo, ok := databases.GetRange(database, chromosome, resolution, start, end, maxBlocks)
+
+	if !ok {
+		resp := Message(false, "fail")
+		resp["data"] = "No such chromosome: " + chromosome + " in database " + database + " or resolution: " + resolution
+		Respond(w, resp)
+		return
+	}
+
+	resp := Message(true, "success")
+	resp["data"] = rangeInfo
+
+	Respond(w, resp)
+}
+
+func blockParams(r *http.Request) (database string, chromosome string, resolution string, blockNum uint64, msg string, ok bool) {
 	params := mux.Vars(r)
 	database = params["database"]
 	chromosome = params["chromosome"]
+	resolution = r.FormValue("resolution")
 	blockNumS := params["blockNum"]
 	ok = true
 	err := errors.New("")
@@ -54,8 +160,8 @@ func blockParams(r *http.Request) (database string, chromosome string, blockNum
 	return
 }
 
-func getBlock(w http.ResponseWriter, r *http.Request) (database string, chromosome string, blockNum uint64, msg string, ok bool) {
-	database, chromosome, blockNum, msg, ok = blockParams(r)
+func getBlock(w http.ResponseWriter, r *http.Request) (database string, chromosome string, resolution string, blockNum uint64, msg string, ok bool) {
+	database, chromosome, resolution, blockNum, msg, ok = blockParams(r)
 
 	if !ok {
 		resp := Message(false, "fail")
@@ -70,16 +176,16 @@ func getBlock(w http.ResponseWriter, r *http.Request) (database string, chromoso
 func Block(w http.ResponseWriter, r *http.Request) {
 	log.Tracef("Block %#v", r)
 
-	database, chromosome, blockNum, msg, ok := getBlock(w, r)
+	database, chromosome, resolution, blockNum, msg, ok := getBlock(w, r)
 
 	if !ok {
 		return
 	}
 
-	block, b_ok := databases.GetBlock(database, chromosome, blockNum)
+	block, b_ok := databases.GetBlock(database, chromosome, resolution, blockNum)
 
 	if !b_ok {
-		msg = fmt.Sprintf("No such blockNum: %d in chromosome: %s in database %s", blockNum, chromosome, database)
+		msg = fmt.Sprintf("No such blockNum: %d in chromosome: %s in database %s resolution %s", blockNum, chromosome, database, resolution)
 
 		resp := Message(false, "fail")
 		resp["data"] = msg
@@ -96,16 +202,29 @@ func Block(w http.ResponseWriter, r *http.Request) {
 func BlockMatrix(w http.ResponseWriter, r *http.Request) {
 	log.Tracef("BlockMatrix %#v", r)
 
-	database, chromosome, blockNum, msg, ok := getBlock(w, r)
+	database, chromosome, resolution, blockNum, msg, ok := getBlock(w, r)
 
 	if !ok {
 		return
 	}
 
-	matrix, b_ok := databases.GetBlockMatrix(database, chromosome, blockNum)
+	if WantsArrow(r) {
+		ib, _, block, matrix, err := databases.GetBlockMatrixData(database, chromosome, resolution, blockNum)
+
+		if err != nil {
+			msg = fmt.Sprintf("No such blockNum: %d in chromosome: %s in database %s resolution %s", blockNum, chromosome, database, resolution)
+			RespondError(w, msg, err)
+			return
+		}
+
+		RespondArrow(w, ib, database, chromosome, block, matrix)
+		return
+	}
+
+	matrix, b_ok := databases.GetBlockMatrix(database, chromosome, resolution, blockNum)
 
 	if !b_ok {
-		msg = fmt.Sprintf("No such blockNum: %d in chromosome: %s in database %s", blockNum, chromosome, database)
+		msg = fmt.Sprintf("No such blockNum: %d in chromosome: %s in database %s resolution %s", blockNum, chromosome, database, resolution)
 
 		resp := Message(false, "fail")
 		resp["data"] = msg
@@ -122,16 +241,16 @@ func BlockMatrix(w http.ResponseWriter, r *http.Request) {
 func BlocksMatrixTable(w http.ResponseWriter, r *http.Request) {
 	log.Tracef("BlocksMatrixTable %#v", r)
 
-	database, chromosome, blockNum, msg, ok := getBlock(w, r)
+	database, chromosome, resolution, blockNum, msg, ok := getBlock(w, r)
 
 	if !ok {
 		return
 	}
 
-	table, b_ok := databases.GetBlockMatrixTable(database, chromosome, blockNum)
+	table, b_ok := databases.GetBlockMatrixTable(database, chromosome, resolution, blockNum)
 
 	if !b_ok {
-		msg = fmt.Sprintf("No such blockNum: %d in chromosome: %s in database %s", blockNum, chromosome, database)
+		msg = fmt.Sprintf("No such blockNum: %d in chromosome: %s in database %s resolution %s", blockNum, chromosome, database, resolution)
 
 		resp := Message(false, "fail")
 		resp["data"] = msg
@@ -0,0 +1,216 @@
+package endpoints
+
+import (
+	"container/list"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Cache
+//
+
+// Cache keeps account of the memory used by the loaded databases and
+// chromosomes and evicts the least recently used ones above MaxMemory.
+// A MaxMemory of 0 means no limit.
+type Cache struct {
+	MaxMemory uint64
+	used      uint64
+	hits      uint64
+	misses    uint64
+	loads     uint64
+	evictions uint64
+	entries   *list.List
+	index     map[string]*list.Element
+	mutex     sync.Mutex
+}
+
+type cacheEntry struct {
+	key      string
+	size     uint64
+	lastUsed time.Time
+	evict    func()
+}
+
+func NewCache(maxMemory uint64) (c *Cache) {
+	c = &Cache{
+		MaxMemory: maxMemory,
+		entries:   list.New(),
+		index:     make(map[string]*list.Element, 0),
+	}
+	return c
+}
+
+func (c *Cache) SetMaxMemory(maxMemory uint64) {
+	c.mutex.Lock()
+	c.MaxMemory = maxMemory
+	victims := c.collectVictims("")
+	c.mutex.Unlock()
+
+	c.evict(victims)
+}
+
+// Touch marks key as used. Returns false, and counts a miss, if it is not loaded
+func (c *Cache) Touch(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.index[key]
+
+	if !ok {
+		c.misses++
+		return false
+	}
+
+	c.hits++
+
+	elem.Value.(*cacheEntry).lastUsed = time.Now()
+	c.entries.MoveToFront(elem)
+
+	return true
+}
+
+// Add accounts size bytes for key and evicts the least recently used entries
+// above the budget. evict is called, without any lock held, to release the entry.
+// Callers must not hold locks which evict functions take.
+func (c *Cache) Add(key string, size uint64, evict func()) {
+	c.mutex.Lock()
+
+	if elem, ok := c.index[key]; ok {
+		c.removeElement(elem)
+	}
+
+	entry := &cacheEntry{
+		key:      key,
+		size:     size,
+		lastUsed: time.Now(),
+		evict:    evict,
+	}
+
+	c.index[key] = c.entries.PushFront(entry)
+	c.used += size
+	c.loads++
+
+	log.Debugf("Cache :: add '%s' size %d used %d max %d", key, size, c.used, c.MaxMemory)
+
+	victims := c.collectVictims(key)
+
+	c.mutex.Unlock()
+
+	c.evict(victims)
+}
+
+// Remove forgets key without calling its evict function
+func (c *Cache) Remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.index[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// RemovePrefix forgets all keys starting with prefix without calling their evict functions
+func (c *Cache) RemovePrefix(prefix string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, elem := range c.index {
+		if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
+			c.removeElement(elem)
+		}
+	}
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.entries.Remove(elem)
+	delete(c.index, entry.key)
+	c.used -= entry.size
+}
+
+// collectVictims removes the least recently used entries, except keep, until
+// the budget is met and returns them to be evicted once the lock is released
+func (c *Cache) collectVictims(keep string) (victims []*cacheEntry) {
+	victims = make([]*cacheEntry, 0, 0)
+
+	if c.MaxMemory == 0 {
+		return victims
+	}
+
+	for elem := c.entries.Back(); elem != nil && c.used > c.MaxMemory; {
+		prev := elem.Prev()
+		entry := elem.Value.(*cacheEntry)
+
+		// the database of a block being kept is kept as well
+		if entry.key != keep && !strings.HasPrefix(keep, entry.key+"::") {
+			c.removeElement(elem)
+			c.evictions++
+			victims = append(victims, entry)
+		}
+
+		elem = prev
+	}
+
+	return victims
+}
+
+func (c *Cache) evict(victims []*cacheEntry) {
+	for _, entry := range victims {
+		log.Infof("Cache :: evicting '%s' size %d", entry.key, entry.size)
+
+		if entry.evict != nil {
+			entry.evict()
+		}
+	}
+}
+
+func (c *Cache) GetStats() (s *CacheStats) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s = &CacheStats{
+		MaxMemory:  c.MaxMemory,
+		UsedMemory: c.used,
+		NumEntries: c.entries.Len(),
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Loads:      c.loads,
+		Evictions:  c.evictions,
+		Entries:    make([]CacheEntryInfo, 0, c.entries.Len()),
+	}
+
+	for elem := c.entries.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*cacheEntry)
+		s.Entries = append(s.Entries, CacheEntryInfo{
+			Key:      entry.key,
+			Size:     entry.size,
+			LastUsed: entry.lastUsed,
+		})
+	}
+
+	return s
+}
+
+//
+// CacheStats
+//
+
+type CacheStats struct {
+	MaxMemory  uint64
+	UsedMemory uint64
+	NumEntries int
+	Hits       uint64
+	Misses     uint64
+	Loads      uint64
+	Evictions  uint64
+	Entries    []CacheEntryInfo
+}
+
+type CacheEntryInfo struct {
+	Key      string
+	Size     uint64
+	LastUsed time.Time
+}
@@ -84,6 +84,18 @@ func ChromosomeSummaryMatrix(w http.ResponseWriter, r *http.Request) {
 	database := params["database"]
 	chromosome := params["chromosome"]
 
+	if WantsArrow(r) {
+		ib, _, block, matrix, err := databases.GetChromosomeSummaryMatrixData(database, chromosome)
+
+		if err != nil {
+			RespondError(w, "No such chromosome: "+chromosome+" in database "+database, err)
+			return
+		}
+
+		RespondArrow(w, ib, database, chromosome, block, matrix)
+		return
+	}
+
 	db, ok := databases.GetChromosomeSummaryBlockMatrix(database, chromosome)
 
 	if !ok {
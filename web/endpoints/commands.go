@@ -9,7 +9,7 @@ import (
 func Update(w http.ResponseWriter, r *http.Request) {
 	log.Tracef("Update %#v", r)
 
-	ListDatabases()
+	SyncDatabases()
 
 	params := mux.Vars(r)
 
@@ -18,3 +18,14 @@ func Update(w http.ResponseWriter, r *http.Request) {
 
 	Respond(w, resp)
 }
+
+func AdminCache(w http.ResponseWriter, r *http.Request) {
+	log.Tracef("AdminCache %#v", r)
+
+	stats := databases.GetCacheStats()
+
+	resp := Message(true, "success")
+	resp["data"] = stats
+
+	Respond(w, resp)
+}
@@ -74,6 +74,18 @@ func DatabaseSummaryMatrix(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	database := params["database"]
 
+	if WantsArrow(r) {
+		ib, block, matrix, err := databases.GetDatabaseSummaryMatrixData(database)
+
+		if err != nil {
+			RespondError(w, "No such database: "+database, err)
+			return
+		}
+
+		RespondArrow(w, ib, database, "", block, matrix)
+		return
+	}
+
 	db, ok := databases.GetDatabaseSummaryBlockMatrix(database)
 
 	if !ok {
@@ -6,13 +6,17 @@ import (
 )
 
 // var ROUTER *mux.Router
-var databases DbDb
+var databases *DbDb
 
 func init() {
 	log.Trace("web :: endpoints :: init()")
-	databases = *NewDbDb()
+	databases = NewDbDb()
 }
 
 // func SetRouter(router *mux.Router) {
 // 	ROUTER = router
 // }
+
+func SetMaxMemory(maxMemory uint64) {
+	databases.SetMaxMemory(maxMemory)
+}
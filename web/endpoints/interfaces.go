@@ -1,12 +1,14 @@
 package endpoints
 
 import (
-	"errors"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+	"unsafe"
 )
 
 import (
@@ -24,11 +26,19 @@ type Parameters = ibrowser.Parameters
 type IBrowser = ibrowser.IBrowser
 type IBChromosome = ibrowser.IBChromosome
 type IBBlock = ibrowser.IBBlock
+type IBLevel = ibrowser.IBLevel
 type IBMatrix = ibrowser.IBDistanceMatrix
 type IBDistanceTable = ibrowser.IBDistanceTable
 
+var ErrNotFound = save.ErrNotFound
+var ErrCorrupt = save.ErrCorrupt
+
 var GuessPrefixFormat = save.GuessPrefixFormat
 var GuessFormat = save.GuessFormat
+var IsComplete = save.IsComplete
+var IsTempDir = save.IsTempDir
+var IsContainer = save.IsContainer
+var ContainerPrefix = save.ContainerPrefix
 var NewIBrowser = ibrowser.NewIBrowser
 
 //
@@ -36,39 +46,62 @@ var NewIBrowser = ibrowser.NewIBrowser
 //
 type DbDb struct {
 	Databases map[string]*DatabaseInfo
+	cache     *Cache
+	mutex     sync.RWMutex
 }
 
 func NewDbDb() (db *DbDb) {
 	db = &DbDb{
 		Databases: make(map[string]*DatabaseInfo, 0),
+		cache:     NewCache(0),
 	}
 	return db
 }
 
+// SetMaxMemory sets the memory budget for loaded databases and block matrices. 0 means no limit
+func (d *DbDb) SetMaxMemory(maxMemory uint64) {
+	d.cache.SetMaxMemory(maxMemory)
+}
+
+func (d *DbDb) GetCacheStats() *CacheStats {
+	return d.cache.GetStats()
+}
+
 func (d *DbDb) Register(fileName string, path string) (err error) {
 	err = nil
 
-	if _, ok := d.Databases[fileName]; ok {
+	if _, ok := d.GetDatabase(fileName); ok {
 		log.Debugf("Registering db :: filename: '%s' path: '%s' - Exists", fileName, path)
 		return err
 
 	} else {
 		log.Infof("Registering db :: filename: '%s' path: '%s' - loading\n", fileName, path)
 
-		defer func() {
-			// recover from panic if one occured. Set err to nil otherwise.
-			if recover() != nil {
-				log.Warningf("Registering db :: filename: '%s' path: '%s' - Error registering", fileName, path)
-				err = errors.New(fmt.Sprintf("Error registering :: fileName '%s' path '%s'", fileName, path))
-				// return err
-				return
-			}
-		}()
-
 		ib := NewIBrowser(Parameters{})
-		ib.EasyLoadFile(path, true)
 
-		dbi := NewDatabaseInfo(fileName, path, ib)
+		if err := ib.EasyLoadFile(path, true); err != nil {
+			log.Warningf("Registering db :: filename: '%s' path: '%s' - Error registering: %s", fileName, path, err)
+			return fmt.Errorf("Error registering :: fileName '%s' path '%s': %w", fileName, path, err)
+		}
+
+		dbi := NewDatabaseInfo(fileName, path, ib, d.cache)
+
+		// only the metadata is kept. blocks are loaded on first request and
+		// matrices are read from the memory mapped dumps
+		dbi.ib = nil
+
+		if fi, err := os.Stat(path); err == nil {
+			dbi.modTime = fi.ModTime()
+			dbi.size = fi.Size()
+		}
+
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+
+		if _, ok := d.Databases[fileName]; ok {
+			log.Debugf("Registering db :: filename: '%s' path: '%s' - Exists", fileName, path)
+			return err
+		}
 
 		d.Databases[fileName] = dbi
 
@@ -76,22 +109,150 @@ func (d *DbDb) Register(fileName string, path string) (err error) {
 	}
 }
 
+func (d *DbDb) Unregister(fileName string) (ok bool) {
+	d.mutex.Lock()
+
+	dbi, ok := d.Databases[fileName]
+
+	if ok {
+		delete(d.Databases, fileName)
+	}
+
+	d.mutex.Unlock()
+
+	if !ok {
+		log.Debugf("Unregistering db :: filename: '%s' - Does not exist", fileName)
+		return ok
+	}
+
+	log.Infof("Unregistering db :: filename: '%s' path: '%s'", fileName, dbi.FilePath)
+
+	dbi.mutex.Lock()
+	dbi.removed = true
+	dbi.mutex.Unlock()
+
+	d.cache.Remove(fileName)
+	d.unloadDatabase(dbi)
+
+	return ok
+}
+
+// hasChanged returns true if the database file was modified since it was registered
+func (d *DbDb) hasChanged(fileName string, path string) bool {
+	dbi, ok := d.GetDatabase(fileName)
+
+	if !ok {
+		return false
+	}
+
+	if dbi.FilePath != path {
+		return true
+	}
+
+	fi, err := os.Stat(path)
+
+	if err != nil {
+		return true
+	}
+
+	return !fi.ModTime().Equal(dbi.modTime) || fi.Size() != dbi.size
+}
+
 //
 // Get database
 //
 
 func (d *DbDb) getDatabase(fileName string) (*DatabaseInfo, *IBrowser, bool) {
-	dbi, hasDb := d.Databases[fileName]
+	dbi, hasDb := d.GetDatabase(fileName)
 
 	if !hasDb {
 		return nil, nil, hasDb
 	}
 
-	ib := dbi.ib
+	ib, hasIb := d.loadDatabase(dbi)
+
+	if !hasIb {
+		return nil, nil, hasIb
+	}
 
 	return dbi, ib, true
 }
 
+// loadDatabase loads the blocks of a database on first request
+func (d *DbDb) loadDatabase(dbi *DatabaseInfo) (ib *IBrowser, ok bool) {
+	key := dbi.DatabaseName
+
+	dbi.mutex.Lock()
+
+	if dbi.removed {
+		dbi.mutex.Unlock()
+		return nil, false
+	}
+
+	if d.cache.Touch(key) && dbi.ib != nil {
+		ib = dbi.ib
+		dbi.mutex.Unlock()
+		return ib, true
+	}
+
+	log.Infof("Loading db :: filename: '%s' path: '%s'", dbi.DatabaseName, dbi.FilePath)
+
+	ib = NewIBrowser(Parameters{})
+
+	err := ib.EasyLoadFile(dbi.FilePath, true)
+	ok = err == nil
+
+	if !ok {
+		log.Warningf("Loading db :: filename: '%s' path: '%s' - Error loading: %s", dbi.DatabaseName, dbi.FilePath, err)
+	}
+
+	if ok {
+		dbi.ib = ib
+		dbi.store.SetIBrowser(ib)
+	}
+
+	dbi.mutex.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	// no lock held. adding may evict other databases
+	d.cache.Add(key, databaseMemory(ib), func() { d.unloadDatabase(dbi) })
+
+	return ib, true
+}
+
+func (d *DbDb) unloadDatabase(dbi *DatabaseInfo) {
+	log.Infof("Unloading db :: filename: '%s'", dbi.DatabaseName)
+
+	dbi.mutex.Lock()
+	defer dbi.mutex.Unlock()
+
+	dbi.ib = nil
+	dbi.store.SetIBrowser(nil)
+	dbi.store.Close()
+
+	d.cache.RemovePrefix(dbi.DatabaseName + "::")
+}
+
+// databaseMemory approximates the memory used by the blocks of a loaded database
+func databaseMemory(ib *IBrowser) uint64 {
+	numBlocks := uint64(1)
+
+	for _, chrom := range ib.Chromosomes {
+		numBlocks += 1 + uint64(len(chrom.Blocks))
+
+		for _, level := range chrom.Levels {
+			numBlocks += uint64(len(level.Blocks))
+		}
+	}
+
+	blockSize := uint64(unsafe.Sizeof(IBBlock{})) + uint64(unsafe.Sizeof(IBMatrix{}))
+
+	return numBlocks * blockSize
+}
+
 //
 // Get database summary
 //
@@ -237,70 +398,90 @@ func (d *DbDb) getChromosomeSummaryBlockMatrixTable(fileName string, chromosome
 }
 
 //
-// Get blocks
+// Get levels
 //
-func (d *DbDb) getChromosomeBlocks(fileName string, chromosome string) (*DatabaseInfo, *IBrowser, *IBChromosome, []*IBBlock, bool) {
+
+func (d *DbDb) getChromosomeLevel(fileName string, chromosome string, resolution string) (*DatabaseInfo, *IBrowser, *IBChromosome, *IBLevel, bool) {
 	dbi, ib, chrom, hasChrom := d.getChromosome(fileName, chromosome)
 
 	if !hasChrom {
 		return nil, nil, nil, nil, hasChrom
 	}
 
-	blocks, hasBlock := chrom.GetBlocks()
+	level, hasLevel := chrom.GetResolution(resolution)
 
-	if !hasBlock {
-		return nil, nil, nil, nil, hasBlock
+	if !hasLevel {
+		return nil, nil, nil, nil, hasLevel
 	}
 
-	return dbi, ib, chrom, blocks, true
+	return dbi, ib, chrom, level, true
 }
 
-func (d *DbDb) getChromosomeBlock(fileName string, chromosome string, blockNum uint64) (*DatabaseInfo, *IBrowser, *IBChromosome, *IBBlock, bool) {
-	dbi, ib, chrom, hasChrom := d.getChromosome(fileName, chromosome)
+//
+// Get blocks
+//
+func (d *DbDb) getChromosomeBlocks(fileName string, chromosome string, resolution string) (*DatabaseInfo, *IBrowser, *IBChromosome, *IBLevel, []*IBBlock, bool) {
+	dbi, ib, chrom, level, hasLevel := d.getChromosomeLevel(fileName, chromosome, resolution)
 
-	if !hasChrom {
-		return nil, nil, nil, nil, hasChrom
+	if !hasLevel {
+		return nil, nil, nil, nil, nil, hasLevel
 	}
 
-	block, hasBlock := chrom.GetBlock(blockNum)
+	blocks, hasBlock := level.GetBlocks()
 
 	if !hasBlock {
-		return nil, nil, nil, nil, hasBlock
+		return nil, nil, nil, nil, nil, hasBlock
 	}
 
-	return dbi, ib, chrom, block, true
+	return dbi, ib, chrom, level, blocks, true
 }
 
-func (d *DbDb) getChromosomeBlockMatrix(fileName string, chromosome string, blockNum uint64) (*DatabaseInfo, *IBrowser, *IBChromosome, *IBBlock, *IBMatrix, bool) {
-	dbi, ib, chrom, block, hasBlock := d.getChromosomeBlock(fileName, chromosome, blockNum)
+func (d *DbDb) getChromosomeBlock(fileName string, chromosome string, resolution string, blockNum uint64) (*DatabaseInfo, *IBrowser, *IBChromosome, *IBLevel, *IBBlock, bool) {
+	dbi, ib, chrom, level, hasLevel := d.getChromosomeLevel(fileName, chromosome, resolution)
+
+	if !hasLevel {
+		return nil, nil, nil, nil, nil, hasLevel
+	}
+
+	block, hasBlock := level.GetBlock(blockNum)
 
 	if !hasBlock {
 		return nil, nil, nil, nil, nil, hasBlock
 	}
 
+	return dbi, ib, chrom, level, block, true
+}
+
+func (d *DbDb) getChromosomeBlockMatrix(fileName string, chromosome string, resolution string, blockNum uint64) (*DatabaseInfo, *IBrowser, *IBChromosome, *IBLevel, *IBBlock, *IBMatrix, bool) {
+	dbi, ib, chrom, level, block, hasBlock := d.getChromosomeBlock(fileName, chromosome, resolution, blockNum)
+
+	if !hasBlock {
+		return nil, nil, nil, nil, nil, nil, hasBlock
+	}
+
 	matrix, hasMatrix := block.GetMatrix()
 
 	if !hasMatrix {
-		return nil, nil, nil, nil, nil, hasMatrix
+		return nil, nil, nil, nil, nil, nil, hasMatrix
 	}
 
-	return dbi, ib, chrom, block, matrix, true
+	return dbi, ib, chrom, level, block, matrix, true
 }
 
-func (d *DbDb) getChromosomeBlockMatrixTable(fileName string, chromosome string, blockNum uint64) (*DatabaseInfo, *IBrowser, *IBChromosome, *IBBlock, *IBMatrix, *IBDistanceTable, bool) {
-	dbi, ib, chrom, block, matrix, hasMatrix := d.getChromosomeBlockMatrix(fileName, chromosome, blockNum)
+func (d *DbDb) getChromosomeBlockMatrixTable(fileName string, chromosome string, resolution string, blockNum uint64) (*DatabaseInfo, *IBrowser, *IBChromosome, *IBLevel, *IBBlock, *IBMatrix, *IBDistanceTable, bool) {
+	dbi, ib, chrom, level, block, matrix, hasMatrix := d.getChromosomeBlockMatrix(fileName, chromosome, resolution, blockNum)
 
 	if !hasMatrix {
-		return nil, nil, nil, nil, nil, nil, hasMatrix
+		return nil, nil, nil, nil, nil, nil, nil, hasMatrix
 	}
 
 	table, hasTable := matrix.GetTable()
 
 	if !hasTable {
-		return nil, nil, nil, nil, nil, nil, hasTable
+		return nil, nil, nil, nil, nil, nil, nil, hasTable
 	}
 
-	return dbi, ib, chrom, block, matrix, table, true
+	return dbi, ib, chrom, level, block, matrix, table, true
 }
 
 //
@@ -310,6 +491,9 @@ func (d *DbDb) getChromosomeBlockMatrixTable(fileName string, chromosome string,
 //
 // Database
 func (d *DbDb) GetDatabases() (files []*DatabaseInfo) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
 	files = make([]*DatabaseInfo, 0, len(d.Databases))
 
 	for _, value := range d.Databases {
@@ -320,6 +504,9 @@ func (d *DbDb) GetDatabases() (files []*DatabaseInfo) {
 }
 
 func (d *DbDb) GetDatabase(fileName string) (*DatabaseInfo, bool) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
 	if dbi, ok := d.Databases[fileName]; ok {
 		return dbi, ok
 	} else {
@@ -433,10 +620,64 @@ func (d *DbDb) GetChromosomeSummaryBlockMatrixTable(fileName string, chromosome
 	return bl, true
 }
 
+//
+// Levels
+func (d *DbDb) GetLevels(fileName string, chromosome string) ([]*LevelInfo, bool) {
+	dbi, _, chrom, hasChrom := d.getChromosome(fileName, chromosome)
+
+	if !hasChrom {
+		return nil, hasChrom
+	}
+
+	levels := make([]*LevelInfo, 0, len(chrom.Levels)+1)
+
+	levels = append(levels, NewLevelInfo(dbi, chrom.GetBaseLevel()))
+
+	for _, level := range chrom.Levels {
+		levels = append(levels, NewLevelInfo(dbi, level))
+	}
+
+	return levels, true
+}
+
+func (d *DbDb) GetRange(fileName string, chromosome string, resolution string, start uint64, end uint64, maxBlocks uint64) (*RangeInfo, bool) {
+	dbi, ib, chrom, hasChrom := d.getChromosome(fileName, chromosome)
+
+	if !hasChrom {
+		return nil, hasChrom
+	}
+
+	var level *IBLevel
+	var blocks []*IBBlock
+
+	if resolution == "" {
+		level, blocks = chrom.GetBestResolution(start, end, maxBlocks)
+	} else {
+		hasLevel := false
+		level, hasLevel = chrom.GetResolution(resolution)
+
+		if !hasLevel {
+			return nil, hasLevel
+		}
+
+		blocks = chrom.GetLevelBlocksInRange(level, start, end)
+	}
+
+	blocksi := make([]*BlockInfo, len(blocks), len(blocks))
+
+	for bl, block := range blocks {
+		blocksi[bl] = NewLevelBlockInfo(dbi, ib, chrom, level, block)
+	}
+
+	ri := NewRangeInfo(dbi, level, start, end, blocksi)
+
+	return ri, true
+}
+
 //
 // Blocks
-func (d *DbDb) GetBlocks(fileName string, chromosome string) ([]*BlockInfo, bool) {
-	dbi, ib, chrom, blocks, hasChrom := d.getChromosomeBlocks(fileName, chromosome)
+func (d *DbDb) GetBlocks(fileName string, chromosome string, resolution string) ([]*BlockInfo, bool) {
+	dbi, ib, chrom, level, blocks, hasChrom := d.getChromosomeBlocks(fileName, chromosome, resolution)
 
 	if !hasChrom {
 		return nil, hasChrom
@@ -445,6 +686,23 @@ func (d *DbDb) GetBlocks(fileName string, chromosome string) ([]*BlockInfo, bool
 	numBlocks := len(blocks)
 	blocksi := make([]*BlockInfo, numBlocks, numBlocks)
 
+	for bl, block := range blocks {
+		blocksi[bl] = NewLevelBlockInfo(dbi, ib, chrom, level, block)
+	}
+
+	return blocksi, true
+}
+
+func (d *DbDb) GetAffectedBlocks(fileName string, chromosome string) ([]*BlockInfo, bool) {
+	dbi, ib, chrom, hasChrom := d.getChromosome(fileName, chromosome)
+
+	if !hasChrom {
+		return nil, hasChrom
+	}
+
+	blocks := chrom.GetAffectedBlocks()
+	blocksi := make([]*BlockInfo, len(blocks), len(blocks))
+
 	for bl, block := range blocks {
 		blocksi[bl] = NewBlockInfo(dbi, ib, chrom, block)
 	}
@@ -452,20 +710,20 @@ func (d *DbDb) GetBlocks(fileName string, chromosome string) ([]*BlockInfo, bool
 	return blocksi, true
 }
 
-func (d *DbDb) GetBlock(fileName string, chromosome string, blockNum uint64) (*BlockInfo, bool) {
-	dbi, ib, chrom, block, hasBlock := d.getChromosomeBlock(fileName, chromosome, blockNum)
+func (d *DbDb) GetBlock(fileName string, chromosome string, resolution string, blockNum uint64) (*BlockInfo, bool) {
+	dbi, ib, chrom, level, block, hasBlock := d.getChromosomeBlock(fileName, chromosome, resolution, blockNum)
 
 	if !hasBlock {
 		return nil, hasBlock
 	}
 
-	bi := NewBlockInfo(dbi, ib, chrom, block)
+	bi := NewLevelBlockInfo(dbi, ib, chrom, level, block)
 
 	return bi, true
 }
 
-func (d *DbDb) GetBlockMatrix(fileName string, chromosome string, blockNum uint64) (*MatrixInfo, bool) {
-	dbi, ib, chrom, block, matrix, ok := d.getChromosomeBlockMatrix(fileName, chromosome, blockNum)
+func (d *DbDb) GetBlockMatrix(fileName string, chromosome string, resolution string, blockNum uint64) (*MatrixInfo, bool) {
+	dbi, ib, chrom, _, block, matrix, ok := d.getChromosomeBlockMatrix(fileName, chromosome, resolution, blockNum)
 
 	if !ok {
 		return nil, ok
@@ -476,18 +734,72 @@ func (d *DbDb) GetBlockMatrix(fileName string, chromosome string, blockNum uint6
 	return mi, true
 }
 
-func (d *DbDb) GetBlockMatrixTable(fileName string, chromosome string, blockNum uint64) (*TableInfo, bool) {
-	dbi, ib, chrom, block, matrix, table, ok := d.getChromosomeBlockMatrixTable(fileName, chromosome, blockNum)
+func (d *DbDb) GetBlockMatrixTable(fileName string, chromosome string, resolution string, blockNum uint64) (*TableInfo, bool) {
+	dbi, ib, chrom, level, block, matrix, table, ok := d.getChromosomeBlockMatrixTable(fileName, chromosome, resolution, blockNum)
 
 	if !ok {
 		return nil, ok
 	}
 
-	ti := NewTableInfo(dbi, ib, chrom, block, matrix, table, false)
+	ti := NewLevelTableInfo(dbi, ib, chrom, level, block, matrix, table)
 
 	return ti, true
 }
 
+//
+// Matrix data, read from the memory mapped dumps.
+// Missing databases, chromosomes and blocks return ErrNotFound
+// and registers failing their checks ErrCorrupt.
+//
+
+func (d *DbDb) GetDatabaseSummaryMatrixData(fileName string) (*IBrowser, *IBBlock, *IBMatrix, error) {
+	dbi, ib, block, ok := d.getDatabaseSummaryBlock(fileName)
+
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("%w: database %s", ErrNotFound, fileName)
+	}
+
+	matrix, err := dbi.store.GetSummaryMatrix(block)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return ib, block, matrix, nil
+}
+
+func (d *DbDb) GetChromosomeSummaryMatrixData(fileName string, chromosome string) (*IBrowser, *IBChromosome, *IBBlock, *IBMatrix, error) {
+	dbi, ib, chrom, block, ok := d.getChromosomeSummaryBlock(fileName, chromosome)
+
+	if !ok {
+		return nil, nil, nil, nil, fmt.Errorf("%w: chromosome %s in database %s", ErrNotFound, chromosome, fileName)
+	}
+
+	matrix, err := dbi.store.GetSummaryMatrix(block)
+
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return ib, chrom, block, matrix, nil
+}
+
+func (d *DbDb) GetBlockMatrixData(fileName string, chromosome string, resolution string, blockNum uint64) (*IBrowser, *IBChromosome, *IBBlock, *IBMatrix, error) {
+	dbi, ib, chrom, level, block, ok := d.getChromosomeBlock(fileName, chromosome, resolution, blockNum)
+
+	if !ok {
+		return nil, nil, nil, nil, fmt.Errorf("%w: block %d in chromosome %s in database %s", ErrNotFound, blockNum, chromosome, fileName)
+	}
+
+	matrix, err := dbi.store.GetBlockMatrix(chrom, level.Name, block)
+
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return ib, chrom, block, matrix, nil
+}
+
 //
 // Plots
 //
@@ -524,7 +836,7 @@ func (d *DbDb) referenceNumber2referenceName(fileName string, referenceNumber in
 	return
 }
 
-func (d *DbDb) GetPlotTable(fileName string, chromosome string, referenceName string) (*PlotInfo, bool) {
+func (d *DbDb) GetPlotTable(fileName string, chromosome string, referenceName string) (*PlotInfo, error) {
 	referenceNumber, has_rfn := d.referenceName2referenceNumber(fileName, referenceName)
 
 	fmt.Printf("GetPlotTable :: fileName %s chromosome %s referenceName %s referenceNumber %d\n",
@@ -534,24 +846,25 @@ func (d *DbDb) GetPlotTable(fileName string, chromosome string, referenceName st
 		referenceNumber)
 
 	if !has_rfn {
-		return nil, false
+		return nil, fmt.Errorf("%w: reference %s in database %s", ErrNotFound, referenceName, fileName)
 	}
 
-	_, _, chrom, hasChrom := d.getChromosome(fileName, chromosome)
+	dbi, _, chrom, hasChrom := d.getChromosome(fileName, chromosome)
 
 	if !hasChrom {
-		return nil, false
+		return nil, fmt.Errorf("%w: chromosome %s in database %s", ErrNotFound, chromosome, fileName)
 	}
 
-	distanceTable, hasTable := chrom.GetColumn(referenceNumber)
+	// matrices are not loaded. read them from the memory mapped dumps
+	distanceTable, err := dbi.store.GetColumn(chrom, referenceNumber)
 
-	if !hasTable {
-		return nil, false
+	if err != nil {
+		return nil, err
 	}
 
 	pi := NewPlotInfo(distanceTable)
 
-	return pi, true
+	return pi, nil
 }
 
 type PlotInfo struct {
@@ -583,6 +896,7 @@ type DatabaseInfo struct {
 	Parameters       Parameters
 	Samples          []string
 	NumSamples       uint64
+	BlockMode        string
 	BlockSize        uint64
 	KeepEmptyBlock   bool
 	NumRegisters     uint64
@@ -590,23 +904,33 @@ type DatabaseInfo struct {
 	NumBlocks        uint64
 	CounterBits      int
 	ChromosomesNames []string
+	LevelNames       []string
 	ib               *IBrowser
+	store            *MatrixStore
+	mutex            *sync.Mutex
+	removed          bool
+	modTime          time.Time
+	size             int64
 }
 
-func NewDatabaseInfo(databaseName string, filePath string, ib *IBrowser) (di *DatabaseInfo) {
+func NewDatabaseInfo(databaseName string, filePath string, ib *IBrowser, cache *Cache) (di *DatabaseInfo) {
 	di = &DatabaseInfo{
 		DatabaseName:   databaseName,
 		FilePath:       filePath,
 		Parameters:     ib.Parameters,
 		Samples:        ib.Samples,
 		NumSamples:     ib.NumSamples,
+		BlockMode:      ib.BlockMode,
 		BlockSize:      ib.BlockSize,
 		KeepEmptyBlock: ib.KeepEmptyBlock,
 		NumRegisters:   ib.NumRegisters,
 		NumSNPS:        ib.NumSNPS,
 		NumBlocks:      ib.NumBlocks,
 		CounterBits:    ib.CounterBits,
+		LevelNames:     ib.LevelNames,
 		ib:             ib,
+		store:          NewMatrixStore(databaseName, filePath, ib, cache),
+		mutex:          &sync.Mutex{},
 	}
 
 	chromosomesNames := ib.ChromosomesNames
@@ -623,6 +947,7 @@ func (d DatabaseInfo) String() (res string) {
 	res += fmt.Sprintf(" DatabaseName     %s\n", d.DatabaseName)
 	res += fmt.Sprintf(" FilePath         %s\n", d.FilePath)
 	res += fmt.Sprintf(" NumSamples       %d\n", d.NumSamples)
+	res += fmt.Sprintf(" BlockMode        %s\n", d.BlockMode)
 	res += fmt.Sprintf(" BlockSize        %d\n", d.BlockSize)
 	res += fmt.Sprintf(" KeepEmptyBlock   %#v\n", d.KeepEmptyBlock)
 	res += fmt.Sprintf(" NumRegisters     %d\n", d.NumRegisters)
@@ -630,6 +955,7 @@ func (d DatabaseInfo) String() (res string) {
 	res += fmt.Sprintf(" NumBlocks        %d\n", d.NumBlocks)
 	res += fmt.Sprintf(" CounterBits      %d\n", d.CounterBits)
 	res += fmt.Sprintf(" ChromosomesNames %s\n", strings.Join(d.ChromosomesNames, ", "))
+	res += fmt.Sprintf(" LevelNames       %s\n", strings.Join(d.LevelNames, ", "))
 	res += fmt.Sprintf(" Samples          %s\n", strings.Join(d.Samples, ", "))
 	res += fmt.Sprintf("%s\n", d.Parameters)
 	return
@@ -684,34 +1010,46 @@ func (c ChromosomeInfo) String() (res string) {
 //
 
 type BlockInfo struct {
-	DatabaseName  string
-	MinPosition   uint64
-	MaxPosition   uint64
-	NumSNPS       uint64
-	NumSamples    uint64
-	BlockPosition uint64
-	BlockNumber   uint64
-	Serial        int64
-	block         *IBBlock
-	chromosome    *IBChromosome
-	ib            *IBrowser
-	dbi           *DatabaseInfo
+	DatabaseName   string
+	MinPosition    uint64
+	MaxPosition    uint64
+	NumSNPS        uint64
+	NumSamples     uint64
+	BlockPosition  uint64
+	BlockNumber    uint64
+	Serial         int64
+	BelowMinSnps   bool
+	AboveMaxSnps   bool
+	Masked         bool
+	NumDroppedSNPS uint64
+	MergedBlocks   []uint64
+	LevelName      string
+	block          *IBBlock
+	chromosome     *IBChromosome
+	ib             *IBrowser
+	dbi            *DatabaseInfo
 }
 
 func NewBlockInfo(dbi *DatabaseInfo, ib *IBrowser, chromosome *IBChromosome, block *IBBlock) (m *BlockInfo) {
 	m = &BlockInfo{
-		DatabaseName:  dbi.DatabaseName,
-		MinPosition:   block.MinPosition,
-		MaxPosition:   block.MaxPosition,
-		NumSNPS:       block.NumSNPS,
-		NumSamples:    block.NumSamples,
-		BlockPosition: block.BlockPosition,
-		BlockNumber:   block.BlockNumber,
-		Serial:        block.Serial,
-		block:         block,
-		chromosome:    chromosome,
-		ib:            ib,
-		dbi:           dbi,
+		DatabaseName:   dbi.DatabaseName,
+		MinPosition:    block.MinPosition,
+		MaxPosition:    block.MaxPosition,
+		NumSNPS:        block.NumSNPS,
+		NumSamples:     block.NumSamples,
+		BlockPosition:  block.BlockPosition,
+		BlockNumber:    block.BlockNumber,
+		Serial:         block.Serial,
+		BelowMinSnps:   block.BelowMinSnps,
+		AboveMaxSnps:   block.AboveMaxSnps,
+		Masked:         block.Masked,
+		NumDroppedSNPS: block.NumDroppedSNPS,
+		MergedBlocks:   block.MergedBlocks,
+		LevelName:      ibrowser.BASE_LEVEL_NAME,
+		block:          block,
+		chromosome:     chromosome,
+		ib:             ib,
+		dbi:            dbi,
 	}
 
 	// output_360_merged_2.50.vcf.gz_chromosomes_SL2.50ch00.bin
@@ -719,6 +1057,12 @@ func NewBlockInfo(dbi *DatabaseInfo, ib *IBrowser, chromosome *IBChromosome, blo
 	return
 }
 
+func NewLevelBlockInfo(dbi *DatabaseInfo, ib *IBrowser, chromosome *IBChromosome, level *IBLevel, block *IBBlock) (m *BlockInfo) {
+	m = NewBlockInfo(dbi, ib, chromosome, block)
+	m.LevelName = level.Name
+	return
+}
+
 func (b BlockInfo) String() (res string) {
 	res += fmt.Sprintf(" MinPosition    %d\n", b.MinPosition)
 	res += fmt.Sprintf(" MaxPosition    %d\n", b.MaxPosition)
@@ -726,6 +1070,12 @@ func (b BlockInfo) String() (res string) {
 	res += fmt.Sprintf(" BlockPosition  %d\n", b.BlockPosition)
 	res += fmt.Sprintf(" BlockNumber    %d\n", b.BlockNumber)
 	res += fmt.Sprintf(" Serial         %d\n", b.Serial)
+	res += fmt.Sprintf(" BelowMinSnps   %#v\n", b.BelowMinSnps)
+	res += fmt.Sprintf(" AboveMaxSnps   %#v\n", b.AboveMaxSnps)
+	res += fmt.Sprintf(" Masked         %#v\n", b.Masked)
+	res += fmt.Sprintf(" NumDroppedSNPS %d\n", b.NumDroppedSNPS)
+	res += fmt.Sprintf(" MergedBlocks   %v\n", b.MergedBlocks)
+	res += fmt.Sprintf(" LevelName      %s\n", b.LevelName)
 	return res
 }
 
@@ -777,46 +1127,58 @@ func (m MatrixInfo) String() (res string) {
 // TableInfo
 //
 
+// TableInfo locates the register of a matrix in the dump served by the data endpoint.
+// In compressed dumps the register is at ChunkRegisterPosition of the chunk
+// of ChunkSize bytes at ChunkPosition once decompressed and RegisterPosition
+// is its offset in the uncompressed registers.
 type TableInfo struct {
-	DatabaseName     string
-	FileName         string
-	RegisterPosition uint64
-	RegisterSize     uint64
-	Serial           uint64
-	matrix           *IBMatrix
-	block            *IBBlock
-	chromosome       *IBChromosome
-	ib               *IBrowser
-	dbi              *DatabaseInfo
+	DatabaseName          string
+	FileName              string
+	RegisterPosition      uint64
+	RegisterSize          uint64
+	CounterBits           int
+	Varint                bool
+	Serial                uint64
+	Compressor            string
+	ChunkPosition         uint64
+	ChunkSize             uint64
+	ChunkRegisterPosition uint64
+	offset                uint64
+	matrix                *IBMatrix
+	block                 *IBBlock
+	chromosome            *IBChromosome
+	ib                    *IBrowser
+	dbi                   *DatabaseInfo
 }
 
 func NewTableInfo(dbi *DatabaseInfo, ib *IBrowser, chromosome *IBChromosome, block *IBBlock, matrix *IBMatrix, table *IBDistanceTable, isSummary bool) (m *TableInfo) {
-	isChromosomes := false
 	chromosomeName := ""
 
-	if chromosome == nil {
-		isChromosomes = false
-	} else {
+	if chromosome != nil {
 		chromosomeName = chromosome.ChromosomeName
-		isChromosomes = true
 	}
 
 	RegisterPosition := ib.RegisterSize * uint64(matrix.Serial)
-
-	fileName := ib.GenMatrixDumpFileName(dbi.FilePath, chromosomeName, isSummary, isChromosomes)
-	if DATABASE_DIR[len(DATABASE_DIR)-1] == '/' {
-		fileName = strings.TrimPrefix(fileName, DATABASE_DIR)
-	} else {
-		fileName = strings.TrimPrefix(fileName, DATABASE_DIR+"/")
+	RegisterSize := ib.RegisterSize
+	CounterBits := matrix.CounterBits
+
+	// registers have the size of their own encoding. older databases have a single width
+	if block != nil && block.RegisterSize != 0 {
+		RegisterPosition = block.RegisterOffset
+		RegisterSize = block.RegisterSize
+		CounterBits = int(block.RegisterBits)
 	}
-	fileName = strings.Join([]string{strings.TrimSuffix(DATA_ENDPOINT, "/"), fileName}, "/")
+
+	Varint := int64(CounterBits) == save.VARINT_COUNTER_BITS
 
 	m = &TableInfo{
 		DatabaseName:     dbi.DatabaseName,
-		FileName:         fileName,
 		RegisterPosition: RegisterPosition,
-		RegisterSize:     ib.RegisterSize,
+		RegisterSize:     RegisterSize,
+		CounterBits:      CounterBits,
+		Varint:           Varint,
 		Serial:           uint64(matrix.Serial),
+		offset:           RegisterPosition,
 		matrix:           matrix,
 		block:            block,
 		chromosome:       chromosome,
@@ -824,14 +1186,170 @@ func NewTableInfo(dbi *DatabaseInfo, ib *IBrowser, chromosome *IBChromosome, blo
 		dbi:              dbi,
 	}
 
+	// chromosome summaries are dumped with the database summary
+	prefix := databasePrefix(dbi.FilePath)
+
+	if isSummary {
+		m.setFileName(ib.GenMatrixDumpFileName(prefix, "", true, false))
+	} else {
+		m.setFileName(ib.GenMatrixDumpFileName(prefix, chromosomeName, false, false))
+	}
+
+	return
+}
+
+func NewLevelTableInfo(dbi *DatabaseInfo, ib *IBrowser, chromosome *IBChromosome, level *IBLevel, block *IBBlock, matrix *IBMatrix, table *IBDistanceTable) (m *TableInfo) {
+	m = NewTableInfo(dbi, ib, chromosome, block, matrix, table, false)
+
+	if level.Name != ibrowser.BASE_LEVEL_NAME {
+		prefix := databasePrefix(m.dbi.FilePath)
+		m.setFileName(ib.GenLevelMatrixDumpFileName(prefix, chromosome.ChromosomeName, level.Name))
+	}
+
 	return
 }
 
+// setFileName sets the dump holding the register and its compressed chunk, if any
+func (t *TableInfo) setFileName(fileName string) {
+	t.FileName = dataFileName(fileName)
+	t.RegisterPosition = t.offset
+	t.Compressor = "none"
+	t.ChunkPosition = 0
+	t.ChunkSize = 0
+	t.ChunkRegisterPosition = 0
+
+	if t.dbi.store == nil {
+		return
+	}
+
+	path, position, compressor, chunk, isChunked := t.dbi.store.Locate(fileName, t.offset)
+
+	t.FileName = dataFileName(path)
+	t.RegisterPosition = position
+
+	if isChunked {
+		t.Compressor = compressor
+		t.ChunkPosition = chunk.Position
+		t.ChunkSize = chunk.Size
+		t.ChunkRegisterPosition = t.offset - chunk.DataPosition
+	}
+}
+
+// databasePrefix returns the prefix of the database file or container at path
+func databasePrefix(path string) string {
+	if IsContainer(path) {
+		return ContainerPrefix(path)
+	}
+
+	_, _, _, prefix := GuessFormat(path)
+
+	return prefix
+}
+
+// dataFileName converts a database file path into its address in the data endpoint
+func dataFileName(fileName string) string {
+	if DATABASE_DIR[len(DATABASE_DIR)-1] == '/' {
+		fileName = strings.TrimPrefix(fileName, DATABASE_DIR)
+	} else {
+		fileName = strings.TrimPrefix(fileName, DATABASE_DIR+"/")
+	}
+	fileName = strings.Join([]string{strings.TrimSuffix(DATA_ENDPOINT, "/"), fileName}, "/")
+	return fileName
+}
+
 func (t TableInfo) String() (res string) {
 	res += fmt.Sprintf(" FileName         %s\n", t.FileName)
 	res += fmt.Sprintf(" RegisterPosition %d\n", t.RegisterPosition)
 	res += fmt.Sprintf(" RegisterSize     %d\n", t.RegisterSize)
+	res += fmt.Sprintf(" CounterBits      %d\n", t.CounterBits)
+	res += fmt.Sprintf(" Varint           %t\n", t.Varint)
 	res += fmt.Sprintf(" Serial           %d\n", t.Serial)
+	res += fmt.Sprintf(" Compressor       %s\n", t.Compressor)
+	res += fmt.Sprintf(" ChunkPosition    %d\n", t.ChunkPosition)
+	res += fmt.Sprintf(" ChunkSize        %d\n", t.ChunkSize)
+	res += fmt.Sprintf(" ChunkRegister    %d\n", t.ChunkRegisterPosition)
+	return res
+}
+
+//
+// LevelInfo
+//
+
+type LevelInfo struct {
+	DatabaseName string
+	Name         string
+	WindowSize   uint64
+	StepSize     uint64
+	IsSliding    bool
+	NumBlocks    uint64
+	NumSNPS      uint64
+	level        *IBLevel
+	dbi          *DatabaseInfo
+}
+
+func NewLevelInfo(dbi *DatabaseInfo, level *IBLevel) (l *LevelInfo) {
+	l = &LevelInfo{
+		DatabaseName: dbi.DatabaseName,
+		Name:         level.Name,
+		WindowSize:   level.WindowSize,
+		StepSize:     level.StepSize,
+		IsSliding:    level.IsSliding(),
+		NumBlocks:    level.NumBlocks,
+		NumSNPS:      level.NumSNPS,
+		level:        level,
+		dbi:          dbi,
+	}
+	return
+}
+
+func (l LevelInfo) String() (res string) {
+	res += fmt.Sprintf(" Name       %s\n", l.Name)
+	res += fmt.Sprintf(" WindowSize %d\n", l.WindowSize)
+	res += fmt.Sprintf(" StepSize   %d\n", l.StepSize)
+	res += fmt.Sprintf(" IsSliding  %#v\n", l.IsSliding)
+	res += fmt.Sprintf(" NumBlocks  %d\n", l.NumBlocks)
+	res += fmt.Sprintf(" NumSNPS    %d\n", l.NumSNPS)
+	return res
+}
+
+//
+// RangeInfo
+//
+
+type RangeInfo struct {
+	DatabaseName string
+	Start        uint64
+	End          uint64
+	LevelName    string
+	WindowSize   uint64
+	StepSize     uint64
+	NumBlocks    uint64
+	Blocks       []*BlockInfo
+	dbi          *DatabaseInfo
+}
+
+func NewRangeInfo(dbi *DatabaseInfo, level *IBLevel, start uint64, end uint64, blocks []*BlockInfo) (r *RangeInfo) {
+	r = &RangeInfo{
+		DatabaseName: dbi.DatabaseName,
+		Start:        start,
+		End:          end,
+		LevelName:    level.Name,
+		WindowSize:   level.WindowSize,
+		StepSize:     level.StepSize,
+		NumBlocks:    uint64(len(blocks)),
+		Blocks:       blocks,
+		dbi:          dbi,
+	}
+	return
+}
+
+func (r RangeInfo) String() (res string) {
+	res += fmt.Sprintf(" Start      %d\n", r.Start)
+	res += fmt.Sprintf(" End        %d\n", r.End)
+	res += fmt.Sprintf(" LevelName  %s\n", r.LevelName)
+	res += fmt.Sprintf(" WindowSize %d\n", r.WindowSize)
+	res += fmt.Sprintf(" StepSize   %d\n", r.StepSize)
+	res += fmt.Sprintf(" NumBlocks  %d\n", r.NumBlocks)
 	return res
 }
 
@@ -842,16 +1360,46 @@ func (t TableInfo) String() (res string) {
 func ListDatabases() {
 	log.Tracef("ListDatabases")
 
+	for fn, path := range findDatabases() {
+		reg_err := databases.Register(fn, path)
+
+		if reg_err == nil {
+			log.Tracef("ListDatabases :: path '%s' prefix '%s' - success registering", path, fn)
+		} else {
+			log.Tracef("ListDatabases :: path '%s' prefix '%s' - failed registering", path, fn)
+		}
+	}
+}
+
+// findDatabases returns the path of all databases in DATABASE_DIR indexed by name
+func findDatabases() (databasesFound map[string]string) {
+	databasesFound = make(map[string]string, 0)
+
 	err := filepath.Walk(DATABASE_DIR, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && IsTempDir(path) {
+			log.Tracef("ListDatabases :: path '%s' database being saved", path)
+			return filepath.SkipDir
+		}
+
 		found, _, _, prefix := GuessFormat(path)
+		isContainer := IsContainer(path)
 
-		if found {
+		if isContainer {
+			// containers are moved into place once complete
+			found, prefix = true, ContainerPrefix(path)
+		}
+
+		if found && !isContainer && !IsComplete(path, prefix) {
+			log.Debugf("ListDatabases :: path '%s' incomplete database", path)
+		} else if found {
 			log.Tracef("ListDatabases :: path '%s' valid database", path)
 
 			fi, err := os.Stat(path)
 
 			if err != nil {
-				log.Fatal(err)
+				// deleted while walking
+				log.Warn(err)
+				return nil
 			}
 
 			if fi.Mode().IsRegular() {
@@ -863,13 +1411,7 @@ func ListDatabases() {
 
 				log.Tracef("ListDatabases :: path '%s' prefix '%s'", path, fn)
 
-				reg_err := databases.Register(fn, path)
-
-				if reg_err == nil {
-					log.Tracef("ListDatabases :: path '%s' prefix '%s' - success registering", path, fn)
-				} else {
-					log.Tracef("ListDatabases :: path '%s' prefix '%s' - failed registering", path, fn)
-				}
+				databasesFound[fn] = path
 			} else {
 				log.Tracef("ListDatabases :: path '%s' is folder", path)
 			}
@@ -882,4 +1424,6 @@ func ListDatabases() {
 	if err != nil {
 		log.Panic(err)
 	}
+
+	return databasesFound
 }
@@ -39,13 +39,11 @@ func Plots(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	table, p_ok := databases.GetPlotTable(database, chromosome, referenceName)
+	table, err := databases.GetPlotTable(database, chromosome, referenceName)
 
-	if !p_ok {
+	if err != nil {
 		msg = fmt.Sprintf("error getting plot for database %s chromosome %s reference %s", database, chromosome, referenceName)
-		resp := Message(false, "fail")
-		resp["data"] = msg
-		Respond(w, resp)
+		RespondError(w, msg, err)
 		return
 	}
 
@@ -0,0 +1,298 @@
+package endpoints
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"sync"
+	"unsafe"
+)
+
+import (
+	"github.com/sauloalgolang/introgressionbrowser/ibrowser"
+	"github.com/sauloalgolang/introgressionbrowser/save"
+)
+
+//
+// MatrixStore
+//
+
+// MatrixStore reads block matrices on request from the memory mapped
+// binary dumps of a database instead of keeping them in memory.
+// Each block matrix is read by serial from the mapped dump on first request
+// and kept until evicted by the cache. Columns are read without being kept.
+// Dumps of containers are mapped from the container file.
+type MatrixStore struct {
+	name      string
+	path      string
+	prefix    string
+	container *save.Container
+	ib        *IBrowser
+	cache     *Cache
+	files     map[string]*save.MmapArrayFile
+	blocks    map[string]*IBMatrix
+	mutex     sync.Mutex
+	mapLock   sync.RWMutex // held for reading while reading a mapped file
+}
+
+func NewMatrixStore(name string, path string, ib *IBrowser, cache *Cache) (s *MatrixStore) {
+	s = &MatrixStore{
+		name:   name,
+		path:   path,
+		prefix: databasePrefix(path),
+		ib:     ib,
+		cache:  cache,
+		files:  make(map[string]*save.MmapArrayFile, 0),
+		blocks: make(map[string]*IBMatrix, 0),
+	}
+
+	return s
+}
+
+func (s *MatrixStore) SetIBrowser(ib *IBrowser) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ib = ib
+}
+
+func (s *MatrixStore) getIBrowser() *IBrowser {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.ib
+}
+
+func (s *MatrixStore) getFile(fileName string) (*save.MmapArrayFile, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if mm, ok := s.files[fileName]; ok {
+		return mm, nil
+	}
+
+	log.Debugf("MatrixStore :: mapping '%s'", fileName)
+
+	mm, err := s.mapFile(fileName)
+
+	if err != nil {
+		log.Warningf("MatrixStore :: error mapping '%s': %s", fileName, err)
+		return nil, err
+	}
+
+	s.files[fileName] = mm
+
+	return mm, nil
+}
+
+// getLoadedIBrowser returns ErrNotFound once the database is unloaded
+func (s *MatrixStore) getLoadedIBrowser() (*IBrowser, error) {
+	ib := s.getIBrowser()
+
+	if ib == nil {
+		return nil, fmt.Errorf("%w: database %s is not loaded", save.ErrNotFound, s.name)
+	}
+
+	return ib, nil
+}
+
+// mapFile maps a dump, opening the container of the database on first request
+func (s *MatrixStore) mapFile(fileName string) (*save.MmapArrayFile, error) {
+	if !IsContainer(s.path) {
+		return save.NewMmapArrayFile(fileName)
+	}
+
+	if s.container == nil {
+		container, err := save.OpenContainer(s.path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		s.container = container
+	}
+
+	return s.container.MmapArrayFile(fileName)
+}
+
+func (s *MatrixStore) getMatrix(ib *IBrowser, fileName string, block *IBBlock) (*IBMatrix, error) {
+	s.mapLock.RLock()
+	defer s.mapLock.RUnlock()
+
+	mm, err := s.getFile(fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matrix, err := block.ReadMatrix(mm, ib.RegisterSize)
+
+	if err != nil {
+		log.Warningf("MatrixStore :: error reading '%s': %s", fileName, err)
+		return nil, err
+	}
+
+	return matrix, nil
+}
+
+func (s *MatrixStore) chromosomeFileName(ib *IBrowser, chromosomeName string, levelName string) string {
+	if levelName == "" || levelName == ibrowser.BASE_LEVEL_NAME {
+		return ib.GenMatrixDumpFileName(s.prefix, chromosomeName, false, false)
+	}
+	return ib.GenLevelMatrixDumpFileName(s.prefix, chromosomeName, levelName)
+}
+
+func (s *MatrixStore) blockKey(chromosomeName string, levelName string, serial int64) string {
+	if levelName == "" {
+		levelName = ibrowser.BASE_LEVEL_NAME
+	}
+	return s.name + "::" + chromosomeName + "::" + levelName + "::" + strconv.FormatInt(serial, 10)
+}
+
+// Locate returns the file holding the dump fileName, the position in it of
+// the register at offset or, in compressed dumps, the compressor and the
+// chunk holding it. Dumps without header have their registers at offset.
+// Dumps in containers are held by the container.
+func (s *MatrixStore) Locate(fileName string, offset uint64) (path string, position uint64, compressor string, chunk save.ChunkInfo, isChunked bool) {
+	s.mapLock.RLock()
+	defer s.mapLock.RUnlock()
+
+	mm, err := s.getFile(fileName)
+
+	if err != nil {
+		return fileName, offset, "none", chunk, false
+	}
+
+	if !mm.IsChunked() {
+		var ok bool
+
+		if position, ok = mm.GetPosition(offset); !ok {
+			position = offset
+		}
+		return mm.GetPath(), position, "none", chunk, false
+	}
+
+	chunk, isChunked = mm.GetChunk(offset)
+
+	return mm.GetPath(), offset, mm.GetCompressor(), chunk, isChunked
+}
+
+// GetSummaryMatrix reads the genome or chromosome summary matrix
+func (s *MatrixStore) GetSummaryMatrix(block *IBBlock) (*IBMatrix, error) {
+	ib, err := s.getLoadedIBrowser()
+
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := ib.GenMatrixDumpFileName(s.prefix, "", true, false)
+
+	return s.getMatrix(ib, fileName, block)
+}
+
+// GetBlockMatrix returns the matrix of a block of a chromosome level,
+// reading it on first request. Corrupted registers return ErrCorrupt.
+func (s *MatrixStore) GetBlockMatrix(chrom *IBChromosome, levelName string, block *IBBlock) (*IBMatrix, error) {
+	key := s.blockKey(chrom.ChromosomeName, levelName, block.Serial)
+
+	s.mutex.Lock()
+	matrix, ok := s.blocks[key]
+	s.mutex.Unlock()
+
+	if ok && s.cache.Touch(key) {
+		return matrix, nil
+	}
+
+	ib, err := s.getLoadedIBrowser()
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("MatrixStore :: reading '%s'", key)
+
+	matrix, err = s.getMatrix(ib, s.chromosomeFileName(ib, chrom.ChromosomeName, levelName), block)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	s.blocks[key] = matrix
+	s.mutex.Unlock()
+
+	// no lock held. adding may evict this database
+	s.cache.Add(key, matrixMemory(matrix), func() { s.dropBlock(key) })
+
+	return matrix, nil
+}
+
+// GetColumn returns the distances of every block of a chromosome to a reference sample.
+// Blocks are read from the mapped dump and not kept.
+func (s *MatrixStore) GetColumn(chrom *IBChromosome, referenceNumber int) (*[]*IBDistanceTable, error) {
+	ib, err := s.getLoadedIBrowser()
+
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := s.chromosomeFileName(ib, chrom.ChromosomeName, ibrowser.BASE_LEVEL_NAME)
+
+	cols := make([]*IBDistanceTable, len(chrom.Blocks), len(chrom.Blocks))
+
+	for bc, block := range chrom.Blocks {
+		matrix, err := s.getMatrix(ib, fileName, block)
+
+		if err != nil {
+			return nil, err
+		}
+
+		col, ok := matrix.GetColumn(referenceNumber)
+
+		if !ok {
+			return nil, fmt.Errorf("%w: no sample %d in block %s #%d", save.ErrInvalid, referenceNumber, chrom.ChromosomeName, block.BlockNumber)
+		}
+
+		cols[bc] = col
+	}
+
+	return &cols, nil
+}
+
+func (s *MatrixStore) dropBlock(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.blocks, key)
+}
+
+// Close drops all loaded matrices and unmaps the files
+func (s *MatrixStore) Close() {
+	s.mapLock.Lock()
+	defer s.mapLock.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key := range s.blocks {
+		delete(s.blocks, key)
+	}
+
+	for fileName, mm := range s.files {
+		mm.Close()
+		delete(s.files, fileName)
+	}
+
+	if s.container != nil {
+		if err := s.container.Close(); err != nil {
+			log.Warningf("MatrixStore :: error closing '%s': %s", s.path, err)
+		}
+
+		s.container = nil
+	}
+}
+
+// matrixMemory is the memory used by the counters of a matrix
+func matrixMemory(matrix *IBMatrix) uint64 {
+	return uint64(unsafe.Sizeof(*matrix)) + matrix.Size*uint64(matrix.CounterBits/8)
+}
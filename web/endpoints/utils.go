@@ -1,14 +1,24 @@
 package endpoints
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	// "go-contacts/models"
 	// u "go-contacts/utils"
 	// "github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
 	"net/http"
+	"strings"
 	// "strconv"
 )
 
+import (
+	"github.com/sauloalgolang/introgressionbrowser/export"
+)
+
+const ARROW_STREAM_CONTENT_TYPE = "application/vnd.apache.arrow.stream"
+
 // https://github.com/adigunhammedolalekan/go-contacts/blob/master/utils/util.go
 
 func Message(status bool, message string) map[string]interface{} {
@@ -19,3 +29,51 @@ func Respond(w http.ResponseWriter, data map[string]interface{}) {
 	w.Header().Add("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 }
+
+// RespondError sends the failure of a request reading the database.
+// Missing data is answered with msg as before. Other errors, as
+// corrupted registers, are answered with 500 Internal Server Error.
+func RespondError(w http.ResponseWriter, msg string, err error) {
+	if errors.Is(err, ErrNotFound) {
+		resp := Message(false, "fail")
+		resp["data"] = msg
+		Respond(w, resp)
+		return
+	}
+
+	log.Warningf("RespondError :: %s: %s", msg, err)
+
+	message := "error"
+	if errors.Is(err, ErrCorrupt) {
+		message = "corrupt"
+	}
+
+	resp := Message(false, message)
+	resp["data"] = err.Error()
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// WantsArrow returns true if the request asks for an Arrow stream,
+// either with ?format=arrow or in the Accept header
+func WantsArrow(r *http.Request) bool {
+	return r.FormValue("format") == "arrow" || strings.Contains(r.Header.Get("Accept"), ARROW_STREAM_CONTENT_TYPE)
+}
+
+// RespondArrow sends the matrix of a block as an Arrow stream
+func RespondArrow(w http.ResponseWriter, ib *IBrowser, database string, chromosome string, block *IBBlock, matrix *IBMatrix) {
+	var buf bytes.Buffer
+
+	if err := export.WriteArrowStream(&buf, ib, database, chromosome, block, matrix); err != nil {
+		log.Warningf("RespondArrow :: database '%s' chromosome '%s' block %d: %s", database, chromosome, block.BlockNumber, err)
+		resp := Message(false, "fail")
+		resp["data"] = "Error writing arrow stream: " + err.Error()
+		Respond(w, resp)
+		return
+	}
+
+	w.Header().Add("Content-Type", ARROW_STREAM_CONTENT_TYPE)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,59 @@
+package endpoints
+
+import (
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+//
+// Watcher
+//
+
+// SyncDatabases registers new databases in DATABASE_DIR, reloads the changed
+// ones and unregisters the deleted ones
+func SyncDatabases() {
+	log.Tracef("SyncDatabases")
+
+	found := findDatabases()
+
+	for _, dbi := range databases.GetDatabases() {
+		fn := dbi.DatabaseName
+		path, ok := found[fn]
+
+		if !ok {
+			log.Infof("Watcher :: database '%s' deleted", fn)
+			databases.Unregister(fn)
+
+		} else if databases.hasChanged(fn, path) {
+			log.Infof("Watcher :: database '%s' changed. reloading", fn)
+			databases.Unregister(fn)
+		}
+	}
+
+	for fn, path := range found {
+		if _, ok := databases.GetDatabase(fn); ok {
+			continue
+		}
+
+		log.Infof("Watcher :: database '%s' found. registering", fn)
+
+		if err := databases.Register(fn, path); err != nil {
+			// probably still being written. retried on the next poll
+			log.Warningf("Watcher :: database '%s' path '%s' - failed registering: %s", fn, path, err)
+		}
+	}
+}
+
+// WatchDatabases polls DATABASE_DIR every interval for new, changed and deleted databases
+func WatchDatabases(interval time.Duration) {
+	log.Infof("Watcher :: polling '%s' every %s", DATABASE_DIR, interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			SyncDatabases()
+		}
+	}()
+}
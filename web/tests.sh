@@ -15,12 +15,15 @@ curl http://127.0.0.1:8000/api/
 
 curl -X POST http://127.0.0.1:8000/api/update
 
+curl http://127.0.0.1:8000/api/admin/cache
+
 curl http://127.0.0.1:8000/api/databases
 
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/summary
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/summary/matrix
+curl -o /dev/null http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/summary/matrix?format=arrow
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/summary/matrix/table
 
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes
@@ -28,11 +31,19 @@ curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosom
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/summary
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/summary/matrix
+curl -o /dev/null http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/summary/matrix?format=arrow
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/summary/matrix/table
 
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/blocks
+curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/affected
+curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/levels
+curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/range/0/10000000
+curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/range/0/10000000?maxBlocks=5
+curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/block?resolution=resolution_1000000
+curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/blocks/0/matrix/table?resolution=resolution_1000000
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/blocks/0
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/blocks/0/matrix
+curl -o /dev/null http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/blocks/0/matrix?format=arrow
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/blocks/0/matrix/table
 curl http://127.0.0.1:8000/api/databases/output_360_merged_2.50.vcf.gz/chromosomes/SL2.50ch02/blocks/1/matrix/table
 
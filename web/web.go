@@ -20,7 +20,7 @@ const DATA_ENDPOINT = "/data"
 const DATABASE_ENDPOINT = "/databases"
 const PLOTS_ENDPOINT = "/plots"
 
-func NewWeb(databaseDir string, httpDir string, host string, port int, verbosityLevel log.Level) {
+func NewWeb(databaseDir string, httpDir string, host string, port int, maxMemory uint64, watch time.Duration, verbosityLevel log.Level) {
 	router := mux.NewRouter()
 
 	router.StrictSlash(false)
@@ -33,9 +33,13 @@ func NewWeb(databaseDir string, httpDir string, host string, port int, verbosity
 	router.HandleFunc(API_ENDPOINT+"/", Template).Methods("GET").Name("apiSlash")
 
 	newData(databaseDir, router)
-	newApi(databaseDir, api, verbosityLevel)
+	newApi(databaseDir, api, maxMemory, verbosityLevel)
 	newRoot(httpDir, router)
 
+	if watch > 0 {
+		endpoints.WatchDatabases(watch)
+	}
+
 	srv := &http.Server{
 		Handler: router,
 		Addr:    host + ":" + strconv.Itoa(port),
@@ -60,10 +64,11 @@ func newData(dir string, router *mux.Router) {
 	router.PathPrefix(DATA_ENDPOINT).Handler(http.StripPrefix(DATA_ENDPOINT, http.FileServer(http.Dir(dir)))).Name("data")
 }
 
-func newApi(dir string, router *mux.Router, verbosityLevel log.Level) {
+func newApi(dir string, router *mux.Router, maxMemory uint64, verbosityLevel log.Level) {
 	//.HeadersRegexp("Content-Type", "application/json")
 	router.HandleFunc("", Template).Methods("GET").Name("api")
 	router.HandleFunc("/update", endpoints.Update).Methods("POST").Name("update")
+	router.HandleFunc("/admin/cache", endpoints.AdminCache).Methods("GET").Name("adminCache")
 	router.HandleFunc(DATABASE_ENDPOINT, endpoints.Databases).Methods("GET").Name("databases")
 	router.HandleFunc(DATABASE_ENDPOINT+"/{database}", endpoints.Database).Methods("GET").Name("database")
 	router.HandleFunc(DATABASE_ENDPOINT+"/{database}/summary", endpoints.DatabaseSummary).Methods("GET").Name("databaseSummary")
@@ -75,6 +80,9 @@ func newApi(dir string, router *mux.Router, verbosityLevel log.Level) {
 	router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosomes/{chromosome}/summary/matrix", endpoints.ChromosomeSummaryMatrix).Methods("GET").Name("databaseChromosomeSummaryMatrix")
 	router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosomes/{chromosome}/summary/matrix/table", endpoints.ChromosomeSummaryMatrixTable).Methods("GET").Name("databaseChromosomeSummaryTable")
 	router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosomes/{chromosome}/block", endpoints.Blocks).Methods("GET").Name("databaseChromosomeBlocks")
+	router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosomes/{chromosome}/affected", endpoints.AffectedBlocks).Methods("GET").Name("databaseChromosomeAffectedBlocks")
+	router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosomes/{chromosome}/levels", endpoints.Levels).Methods("GET").Name("databaseChromosomeLevels")
+	router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosomes/{chromosome}/range/{start:[0-9]+}/{end:[0-9]+}", endpoints.Range).Methods("GET").Name("databaseChromosomeRange")
 	router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosomes/{chromosome}/blocks/{blockNum:[0-9]+}", endpoints.Block).Methods("GET").Name("databaseChromosomeBlock")
 	router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosomes/{chromosome}/blocks/{blockNum:[0-9]+}/matrix", endpoints.BlockMatrix).Methods("GET").Name("databaseChromosomeBlockMatrix")
 	router.HandleFunc(DATABASE_ENDPOINT+"/{database}/chromosomes/{chromosome}/blocks/{blockNum:[0-9]+}/matrix/table", endpoints.BlocksMatrixTable).Methods("GET").Name("databaseChromosomeBlockMatrixTable")
@@ -97,6 +105,7 @@ func newApi(dir string, router *mux.Router, verbosityLevel log.Level) {
 	endpoints.DATA_ENDPOINT = tmpl
 	endpoints.DATABASE_DIR = strings.TrimSuffix(dir, "/")
 	endpoints.VERBOSITY = verbosityLevel
+	endpoints.SetMaxMemory(maxMemory)
 
 	endpoints.ListDatabases()
 }
@@ -107,6 +116,7 @@ func Template(w http.ResponseWriter, r *http.Request) {
 	resp := endpoints.Message(true, "success")
 
 	tmp := map[string]interface{}{
+		API_ENDPOINT + "/admin/cache":                                                                                   endpoints.CacheStats{},
 		API_ENDPOINT + DATABASE_ENDPOINT + "":                                                                           []string{""},
 		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}":                                                                endpoints.DatabaseInfo{},
 		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}/summary":                                                        endpoints.BlockInfo{},
@@ -118,6 +128,9 @@ func Template(w http.ResponseWriter, r *http.Request) {
 		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}/chromosomes/{chromosome}/summary/matrix":                        endpoints.MatrixInfo{},
 		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}/chromosomes/{chromosome}/summary/matrix/table":                  endpoints.TableInfo{},
 		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}/chromosomes/{chromosome}/block":                                 []endpoints.BlockInfo{endpoints.BlockInfo{}},
+		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}/chromosomes/{chromosome}/affected":                              []endpoints.BlockInfo{endpoints.BlockInfo{}},
+		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}/chromosomes/{chromosome}/levels":                                []endpoints.LevelInfo{endpoints.LevelInfo{}},
+		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}/chromosomes/{chromosome}/range/{start:[0-9]+}/{end:[0-9]+}":     endpoints.RangeInfo{},
 		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}/chromosomes/{chromosome}/blocks/{blockNum:[0-9]+}":              endpoints.BlockInfo{},
 		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}/chromosomes/{chromosome}/blocks/{blockNum:[0-9]+}/matrix":       endpoints.MatrixInfo{},
 		API_ENDPOINT + DATABASE_ENDPOINT + "/{database}/chromosomes/{chromosome}/blocks/{blockNum:[0-9]+}/matrix/table": endpoints.TableInfo{},